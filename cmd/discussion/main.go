@@ -1,28 +1,74 @@
 package main
 
 import (
+	"RESTAPI/db/migrations"
+	loader "RESTAPI/internal/config"
 	"RESTAPI/internal/discussion/api"
+	"RESTAPI/internal/discussion/cache"
 	"RESTAPI/internal/discussion/config"
 	"RESTAPI/internal/discussion/kafka"
+	"RESTAPI/internal/discussion/moderation"
 	"RESTAPI/internal/discussion/repository"
 	"RESTAPI/internal/discussion/service"
+	"RESTAPI/internal/health"
+	"RESTAPI/internal/idgen"
+	"RESTAPI/internal/lifecycle"
+	"RESTAPI/internal/logging"
+	"RESTAPI/internal/metrics"
+	"RESTAPI/internal/tracing"
+	"context"
+	"errors"
 	"fmt"
 	"github.com/gocql/gocql"
 	"github.com/gorilla/mux"
-	"log"
+	"log/slog"
 	"net/http"
+	"os"
 	"time"
 )
 
+// readinessTimeout bounds the checks behind /readyz
+const readinessTimeout = 2 * time.Second
+
 func main() {
-	// Load configuration
-	cfg := config.NewConfig()
+	if err := run(); err != nil {
+		slog.Error("discussion stopped", slog.Any("error", err))
+		os.Exit(1)
+	}
+}
+
+// run starts the service and returns once it has shut down
+func run() error {
+	// Load configuration: defaults, config file (-config), environment, flags
+	cfg, args, err := config.Load(os.Args[1:])
+	if loader.IsHelp(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+	// Structured JSON logging; message content is redacted unless enabled
+	if err := logging.Setup(cfg.Logging.Options()); err != nil {
+		return fmt.Errorf("failed to set up logging: %w", err)
+	}
+	slog.Info("configuration loaded", slog.String("config", loader.Describe(cfg)))
+
+	// The repository sets its levels per statement; the default covers the rest
+	consistency, err := gocql.ParseConsistencyWrapper(cfg.DB.Consistency)
+	if err != nil {
+		return fmt.Errorf("invalid Cassandra consistency: %w", err)
+	}
+	consistencies, err := cfg.DB.Consistencies()
+	if err != nil {
+		return fmt.Errorf("invalid Cassandra consistency: %w", err)
+	}
 
 	// Initialize Cassandra connection
 	cluster := gocql.NewCluster(cfg.DB.Hosts...)
-	cluster.Port = 9042
+	cluster.Port = cfg.DB.Port
 	cluster.Keyspace = cfg.DB.Keyspace
-	cluster.Consistency = gocql.ParseConsistency(cfg.DB.Consistency)
+	cluster.Consistency = consistency
+	cluster.SerialConsistency = consistencies.Serial
 	cluster.Timeout = cfg.DB.Timeout
 	cluster.ConnectTimeout = cfg.DB.Timeout
 	cluster.RetryPolicy = &gocql.ExponentialBackoffRetryPolicy{
@@ -30,73 +76,162 @@ func main() {
 		Min:        100 * time.Millisecond,
 		Max:        10 * time.Second,
 	}
+	cluster.QueryObserver = tracing.CassandraObserver{Next: metrics.CassandraObserver{}}
 
-	// Create session without keyspace
-	session, err := cluster.CreateSession()
+	// Everything started below is stopped in reverse order on SIGINT/SIGTERM
+	// or when run returns early
+	lc := lifecycle.New(cfg.Server.ShutdownTimeout)
+	defer lc.Shutdown()
+
+	// The exporter is stopped last so spans of the shutdown are still sent
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing.Options("discussion"))
 	if err != nil {
-		log.Fatalf("Failed to connect to Cassandra: %v", err)
+		return fmt.Errorf("failed to set up tracing: %w", err)
 	}
-	defer session.Close()
+	lc.OnShutdown("tracing", shutdownTracing)
 
-	// Create keyspace if not exists with replication settings
-	err = session.Query(`
-		CREATE KEYSPACE IF NOT EXISTS distcomp
-		WITH replication = {
-			'class': 'SimpleStrategy',
-			'replication_factor': 1
-		}`).Exec()
-	if err != nil {
-		log.Fatalf("Failed to create keyspace: %v", err)
-	}
-
-	// Create the message table
-	err = session.Query(`
-		CREATE TABLE IF NOT EXISTS distcomp.tbl_message (
-			id bigint,
-			newsid bigint,
-			country text,
-			content text,
-			state text,
-			PRIMARY KEY (id)
-		)`).Exec()
+	// The keyspace has to exist before a session bound to it can be created
+	if err := migrations.CreateKeyspace(cluster, cfg.DB.Keyspace); err != nil {
+		return fmt.Errorf("failed to create keyspace: %w", err)
+	}
+
+	session, err := cluster.CreateSession()
 	if err != nil {
-		log.Fatalf("Failed to create table: %v", err)
+		return fmt.Errorf("failed to connect to Cassandra: %w", err)
 	}
+	lc.OnShutdown("cassandra", func(context.Context) error {
+		session.Close()
+		return nil
+	})
 
-	// Create index on newsid
-	err = session.Query(`
-		CREATE INDEX IF NOT EXISTS idx_newsid ON distcomp.tbl_message (newsid)
-	`).Exec()
+	// Readiness: the instance takes traffic only while its dependencies are up
+	checker := health.NewChecker(readinessTimeout)
+	checker.Add("cassandra", func(ctx context.Context) error {
+		return session.Query("SELECT now() FROM system.local").WithContext(ctx).Exec()
+	})
+
+	migrator, err := migrations.NewCassandra(session)
 	if err != nil {
-		log.Printf("Warning: Failed to create index: %v", err)
+		return fmt.Errorf("failed to load migrations: %w", err)
+	}
+
+	// "migrate up|down [N]|status" manages the schema and exits
+	if len(args) > 0 && args[0] == "migrate" {
+		if err := migrations.RunCommand(context.Background(), migrator, args[1:], os.Stdout); err != nil {
+			return fmt.Errorf("migration failed: %w", err)
+		}
+		return nil
+	}
+
+	// Apply pending migrations on startup, within CASSANDRA_MIGRATE_TIMEOUT
+	if _, err := migrations.UpOnStartup(migrator, cfg.DB.MigrateTimeout); err != nil {
+		return fmt.Errorf("failed to migrate keyspace: %w", err)
 	}
 
 	// Initialize Kafka
 	if err := cfg.Kafka.CreateTopics(); err != nil {
-		log.Printf("Warning: Failed to create topics: %v", err)
+		slog.Warn("failed to create topics", slog.Any("error", err))
 	}
 
 	// Create Kafka producer
 	producer, err := kafka.NewProducer(cfg.Kafka)
 	if err != nil {
-		log.Fatalf("Failed to create Kafka producer: %v", err)
+		return fmt.Errorf("failed to create Kafka producer: %w", err)
+	}
+	// Close flushes messages that are still in flight
+	lc.OnShutdown("kafka producer", func(context.Context) error {
+		return producer.Close()
+	})
+
+	kafkaHealth, err := kafka.NewHealth(cfg.Kafka, cfg.Kafka.MaxLag)
+	if err != nil {
+		return fmt.Errorf("failed to create Kafka health check: %w", err)
+	}
+	lc.OnShutdown("kafka health", func(context.Context) error {
+		return kafkaHealth.Close()
+	})
+	checker.Add("kafka", kafkaHealth.CheckBrokers)
+	checker.Add("kafka consumer lag", kafkaHealth.CheckLag)
+
+	// IDs of messages created through the API; instances need distinct node IDs.
+	// NodeID 0 is valid, so a missing one is told apart by the -1 default.
+	if cfg.IDs.NodeID < 0 {
+		return errors.New("NODE_ID is required: set a node ID 0-1023 unique to this instance")
+	}
+	ids, err := idgen.New(cfg.IDs.NodeID)
+	if err != nil {
+		return fmt.Errorf("failed to create ID generator: %w", err)
 	}
-	defer producer.Close()
 
 	// Initialize components
-	messageRepo := repository.NewCassandraMessageRepository(session)
-	messageService := service.NewMessageService(messageRepo)
+	var messageRepo repository.MessageRepository = repository.NewCassandraMessageRepository(session, ids, consistencies)
+
+	// Messages are read through the cache; results on message-out invalidate it
+	store, err := cfg.Cache.NewCache()
+	if err != nil {
+		return fmt.Errorf("failed to create cache: %w", err)
+	}
+	if redisCache, ok := store.(*cache.Redis); ok {
+		lc.OnShutdown("redis", func(context.Context) error {
+			return redisCache.Close()
+		})
+		checker.Add("redis", redisCache.Ping)
+	}
+	if store != nil {
+		cachedRepo := repository.NewCachedMessageRepository(messageRepo, store, cfg.Cache.TTL)
+		invalidator, err := kafka.NewCacheInvalidator(cfg.Kafka, cachedRepo)
+		if err != nil {
+			return fmt.Errorf("failed to create cache invalidator: %w", err)
+		}
+		lc.OnShutdown("cache invalidator", func(context.Context) error {
+			return invalidator.Close()
+		})
+		messageRepo = cachedRepo
+	}
+	messageService := service.NewMessageService(messageRepo, cfg.Publisher.URL, cfg.Publisher.Token.Value())
+
+	// Moderation rules; their lists are reloaded while the service runs
+	source, err := cfg.Moderation.NewSource(session)
+	if err != nil {
+		return fmt.Errorf("failed to configure moderation: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.DB.Timeout)
+	moderator, err := moderation.NewPipeline(ctx, source, cfg.Moderation.Settings())
+	cancel()
+	if err != nil {
+		return fmt.Errorf("failed to load moderation lists: %w", err)
+	}
+	if cfg.Moderation.ReloadInterval > 0 {
+		moderator.Start(cfg.Moderation.ReloadInterval)
+	}
+	lc.OnShutdown("moderation reload", moderator.Stop)
 
 	// Create Kafka consumer
-	consumer, err := kafka.NewConsumer(cfg.Kafka, messageService, producer)
+	consumer, err := kafka.NewConsumer(cfg.Kafka, messageService, producer, moderator)
 	if err != nil {
-		log.Fatalf("Failed to create Kafka consumer: %v", err)
+		return fmt.Errorf("failed to create Kafka consumer: %w", err)
 	}
-	defer consumer.Stop()
+	// Stop finishes the current message and commits the consumed offsets
+	lc.OnShutdown("kafka consumer", consumer.Stop)
 
 	// Start consuming messages
 	if err := consumer.Start(); err != nil {
-		log.Fatalf("Failed to start consumer: %v", err)
+		return fmt.Errorf("failed to start consumer: %w", err)
+	}
+
+	// Dead letters are listed and replayed through the admin API
+	deadLetters, err := kafka.NewDeadLetters(cfg.Kafka, producer)
+	if err != nil {
+		return fmt.Errorf("failed to create dead-letter reader: %w", err)
+	}
+	lc.OnShutdown("kafka dead letters", func(context.Context) error {
+		return deadLetters.Close()
+	})
+	if cfg.Publisher.Token == "" {
+		slog.Warn("PUBLISHER_TOKEN is not set, checking that news exist will fail")
+	}
+	if cfg.Admin.Token == "" {
+		slog.Warn("admin API is disabled, set ADMIN_TOKEN to enable it")
 	}
 
 	// Create API handler
@@ -105,10 +240,24 @@ func main() {
 	// Set up router
 	router := mux.NewRouter()
 	handler.RegisterRoutes(router)
+	api.NewDLQHandler(deadLetters, cfg.Admin.Token.Value()).RegisterRoutes(router)
+	router.Handle("/healthz", health.LivenessHandler()).Methods(http.MethodGet)
+	router.Handle("/readyz", checker.ReadinessHandler()).Methods(http.MethodGet)
+	router.Handle("/metrics", metrics.Handler()).Methods(http.MethodGet)
+	router.Use(tracing.MuxMiddleware, logging.MuxMiddleware, metrics.MuxMiddleware)
 
-	// Start server
-	fmt.Printf("Discussion service starting on %s\n", cfg.Server.Port)
-	if err := http.ListenAndServe(cfg.Server.Port, router); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
-	}
+	// Shutdown stops accepting connections and waits for in-flight requests
+	server := &http.Server{Addr: cfg.Server.Addr, Handler: router}
+	lc.OnShutdown("http server", server.Shutdown)
+	lc.Go("http server", func() error {
+		slog.Info("discussion service starting", slog.String("addr", cfg.Server.Addr))
+		if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		return nil
+	})
+	// Report not ready before the server stops
+	lc.OnShutdown("readiness", checker.DrainFor(cfg.Server.DrainPeriod))
+
+	return lc.Wait()
 }
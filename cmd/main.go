@@ -2,23 +2,139 @@ package main
 
 import (
 	"RESTAPI/db"
-	"RESTAPI/internal/entity"
+	"RESTAPI/db/migrations"
+	"RESTAPI/internal/access"
+	"RESTAPI/internal/auth"
+	"RESTAPI/internal/config"
+	"RESTAPI/internal/discussion/client"
+	discussionconfig "RESTAPI/internal/discussion/config"
+	"RESTAPI/internal/discussion/kafka"
 	"RESTAPI/internal/handler"
+	"RESTAPI/internal/health"
+	"RESTAPI/internal/lifecycle"
+	"RESTAPI/internal/logging"
+	"RESTAPI/internal/metrics"
+	"RESTAPI/internal/outbox"
+	"RESTAPI/internal/password"
 	"RESTAPI/internal/repository"
 	"RESTAPI/internal/service"
-	"log"
+	"RESTAPI/internal/tracing"
+	"RESTAPI/internal/validator"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
 
 	"github.com/labstack/echo/v4"
 )
 
+// readinessTimeout ограничивает время проверок /readyz
+const readinessTimeout = 2 * time.Second
+
+// resultsGroup — группа потребителей message-out; экземпляры сервиса делят результаты модерации
+const resultsGroup = "publisher-group"
+
 func main() {
+	if err := run(); err != nil {
+		slog.Error("publisher stopped", slog.Any("error", err))
+		os.Exit(1)
+	}
+}
+
+// run запускает сервис и возвращается после его остановки
+func run() error {
+	// Конфигурация: значения по умолчанию, файл (-config), переменные окружения, флаги
+	cfg, args, err := config.LoadPublisher(os.Args[1:])
+	if config.IsHelp(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+	// Логирование: JSON через slog, содержимое сообщений скрыто по умолчанию
+	if err := logging.Setup(cfg.Logging.Options()); err != nil {
+		return fmt.Errorf("failed to set up logging: %w", err)
+	}
+	slog.Info("configuration loaded", slog.String("config", config.Describe(cfg)))
+
+	// Ресурсы закрываются в обратном порядке при SIGINT/SIGTERM или при выходе из run
+	lc := lifecycle.New(cfg.HTTP.ShutdownTimeout)
+	defer lc.Shutdown()
+
+	// Трассировка: экспортёр закрывается последним, чтобы успеть отправить все спаны
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing.Options("publisher"))
+	if err != nil {
+		return fmt.Errorf("failed to set up tracing: %w", err)
+	}
+	lc.OnShutdown("tracing", shutdownTracing)
+
+	db, err := db.Connect(cfg.DB)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	// Запросы gorm пишутся через slog; SQL с подставленными значениями — только вместе с содержимым сообщений
+	db.Logger = logging.GormLogger{LogStatements: cfg.Logging.MessageContent}
+	if err := db.Use(metrics.GormPlugin{}); err != nil {
+		return fmt.Errorf("failed to register gorm metrics: %w", err)
+	}
+	if err := db.Use(tracing.GormPlugin{}); err != nil {
+		return fmt.Errorf("failed to register gorm tracing: %w", err)
+	}
+	lc.OnShutdown("postgres", func(context.Context) error {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return err
+		}
+		return sqlDB.Close()
+	})
 
-	db, err := db.Connect()
+	// Готовность: сервис принимает трафик, только пока доступны база и брокеры Kafka
+	checker := health.NewChecker(readinessTimeout)
+	checker.Add("postgres", func(ctx context.Context) error {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return err
+		}
+		return sqlDB.PingContext(ctx)
+	})
+
+	migrator, err := migrations.NewPostgres(db)
 	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+		return fmt.Errorf("failed to load migrations: %w", err)
+	}
+
+	// Подкоманда "migrate up|down [N]|status" управляет схемой и завершает работу
+	if len(args) > 0 && args[0] == "migrate" {
+		if err := migrations.RunCommand(context.Background(), migrator, args[1:], os.Stdout); err != nil {
+			return fmt.Errorf("migration failed: %w", err)
+		}
+		return nil
+	}
+
+	// Применение недостающих миграций при старте, не дольше DB_MIGRATE_TIMEOUT
+	if _, err := migrations.UpOnStartup(migrator, cfg.DB.MigrateTimeout); err != nil {
+		return fmt.Errorf("failed to migrate database: %w", err)
 	}
 
 	e := echo.New()
+	e.HTTPErrorHandler = handler.ErrorHandler
+	e.Validator = validator.NewValidator()
+
+	e.HideBanner = true
+	e.HidePort = true
+
+	// Идентификатор запроса и журнал доступа — внутри трассировки, чтобы строки журнала несли trace_id
+	e.Use(tracing.EchoMiddleware())
+	e.Use(logging.EchoMiddleware())
+	e.Use(metrics.EchoMiddleware())
+
+	// Пробы и метрики для оркестратора, без аутентификации
+	e.GET("/healthz", echo.WrapHandler(health.LivenessHandler()))
+	e.GET("/readyz", echo.WrapHandler(checker.ReadinessHandler()))
+	e.GET("/metrics", echo.WrapHandler(metrics.Handler()))
 
 	// Инициализация хранилищ
 	writerRepo := repository.NewWriterRepository(db)
@@ -27,47 +143,147 @@ func main() {
 	markRepo := repository.NewMarkRepository(db)
 	messageRepo := repository.NewMessageRepository(db)
 
+	hasher, err := password.NewHasher(cfg.Password.HasherConfig())
+	if err != nil {
+		return fmt.Errorf("failed to configure password hashing: %w", err)
+	}
+
+	// Изменения новостей, писателей и меток записываются в tbl_outbox в той же транзакции
+	tx := repository.NewTransactor(db)
+	outboxRepo := repository.NewOutboxRepository(db)
+	events := outbox.NewRecorder(outboxRepo)
+
 	// Создание сервисов
-	writerService := service.NewWriterService(writerRepo)
-	newsService := service.NewNewsService(newsRepo, markRepo)
-	markService := service.NewMarkService(markRepo)
-	messageService := service.NewMessageService(messageRepo)
+	writerService := service.NewWriterService(writerRepo, newsRepo, hasher, tx, events)
+	newsService := service.NewNewsService(newsRepo, markRepo, writerRepo, tx, events)
+	markService := service.NewMarkService(markRepo, tx, events)
+
+	// Первый администратор создаётся из ADMIN_LOGIN и ADMIN_PASSWORD, если его ещё нет
+	if cfg.Admin.Login != "" {
+		if err := writerService.EnsureAdmin(context.Background(), cfg.Admin.Login, cfg.Admin.Password.Value()); err != nil {
+			return fmt.Errorf("failed to create the administrator: %w", err)
+		}
+	}
+
+	kafkaConfig := discussionconfig.NewKafkaConfig(cfg.Kafka.Brokers)
+	if err := kafkaConfig.CreateTopics(outbox.Topics...); err != nil {
+		slog.Warn("failed to create topics", slog.Any("error", err))
+	}
+	// Без брокеров не уходят ни сообщения на модерацию, ни события outbox
+	kafkaHealth, err := kafka.NewHealth(kafkaConfig, 0)
+	if err != nil {
+		return fmt.Errorf("failed to create Kafka health check: %w", err)
+	}
+	lc.OnShutdown("kafka health", func(context.Context) error {
+		return kafkaHealth.Close()
+	})
+	checker.Add("kafka", kafkaHealth.CheckBrokers)
+
+	// Ретранслятор публикует события из tbl_outbox в news-events, writer-events и mark-events
+	relay, err := outbox.NewRelay(outboxRepo, kafkaConfig.Brokers, kafkaConfig.Config, cfg.Outbox.Options())
+	if err != nil {
+		return fmt.Errorf("failed to create outbox relay: %w", err)
+	}
+	lc.OnShutdown("outbox relay", relay.Stop)
+	relay.Start()
+
+	// Сообщения хранит и модерирует сервис обсуждений: новые уходят в message-in, чтение и изменение идут по HTTP
+	publisher, err := client.NewPublisher(kafkaConfig)
+	if err != nil {
+		return fmt.Errorf("failed to create Kafka producer: %w", err)
+	}
+	lc.OnShutdown("kafka producer", func(context.Context) error {
+		return publisher.Close()
+	})
+	// Ответы на запросы с ожиданием вердикта каждый экземпляр читает из message-out сам, без группы
+	requester, err := client.NewRequester(kafkaConfig, publisher, cfg.Discussion.ModerationTimeout)
+	if err != nil {
+		return fmt.Errorf("failed to create Kafka reply listener: %w", err)
+	}
+	lc.OnShutdown("kafka reply listener", func(context.Context) error {
+		return requester.Close()
+	})
+	discussion := client.New(cfg.Discussion.URL, cfg.Discussion.Timeout)
+	messageService := service.NewMessageService(messageRepo, newsRepo, discussion, publisher, requester)
+
+	// Решения модерации приходят из message-out и сохраняются в tbl_message
+	results, err := client.NewResults(kafkaConfig, resultsGroup, messageService.ApplyResult)
+	if err != nil {
+		return fmt.Errorf("failed to create Kafka consumer: %w", err)
+	}
+	lc.OnShutdown("kafka results consumer", results.Stop)
+	if err := results.Start(); err != nil {
+		return fmt.Errorf("failed to start Kafka consumer: %w", err)
+	}
 
 	// Создание обработчиков
-	writerHandler := handler.NewWriterHandler(writerService)
-	newsHandler := handler.NewNewsHandler(newsService)
-	markHandler := handler.NewMarkHandler(markService)
-	messageHandler := handler.NewMessageHandler(messageService)
-
-	log.Println("Tables created successfully", entity.Message{}, entity.Writer{})
-
-	// Маршруты для Writer
-	e.POST("/api/v1.0/writers", writerHandler.Create)
-	e.GET("/api/v1.0/writers/:id", writerHandler.GetById)
-	e.PUT("/api/v1.0/writers", writerHandler.Update)
-	e.DELETE("/api/v1.0/writers/:id", writerHandler.Delete)
-	e.GET("/api/v1.0/writers", writerHandler.GetAll)
-
-	// Маршруты для News
-	e.POST("/api/v1.0/news", newsHandler.Create)
-	e.GET("/api/v1.0/news/:id", newsHandler.GetById)
-	e.PUT("/api/v1.0/news", newsHandler.Update)
-	e.DELETE("/api/v1.0/news/:id", newsHandler.Delete)
-	e.GET("/api/v1.0/news", newsHandler.GetAll)
-
-	// Маршруты для Message
-	e.POST("/api/v1.0/messages", messageHandler.Create)
-	e.GET("/api/v1.0/messages/:id", messageHandler.GetById)
-	e.PUT("/api/v1.0/messages", messageHandler.Update)
-	e.DELETE("/api/v1.0/messages/:id", messageHandler.Delete)
-	e.GET("/api/v1.0/messages", messageHandler.GetAll)
-
-	// Маршруты для Mark
-	e.POST("/api/v1.0/marks", markHandler.Create)
-	e.GET("/api/v1.0/marks/:id", markHandler.GetById)
-	e.PUT("/api/v1.0/marks", markHandler.Update)
-	e.DELETE("/api/v1.0/marks/:id", markHandler.Delete)
-	e.GET("/api/v1.0/marks", markHandler.GetAll)
-
-	e.Logger.Fatal(e.Start(":24110"))
+	writerHandler := handler.NewWriterHandler(access.NewWriterService(writerService))
+	newsHandler := handler.NewNewsHandler(access.NewNewsService(newsService))
+	markHandler := handler.NewMarkHandler(access.NewMarkService(markService))
+	messageHandler := handler.NewMessageHandler(access.NewMessageService(messageService))
+
+	tokenConfig, err := cfg.JWT.TokenConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load JWT configuration: %w", err)
+	}
+	tokens, err := auth.NewTokenManager(tokenConfig)
+	if err != nil {
+		return fmt.Errorf("failed to configure JWT: %w", err)
+	}
+	authHandler := handler.NewAuthHandler(writerService, tokens)
+	requireAuth := auth.Middleware(tokens)
+	requireService := auth.ServiceMiddleware(tokens, cfg.Discussion.Token.Value())
+	if cfg.Discussion.Token == "" {
+		slog.Warn("DISCUSSION_TOKEN is not set, the discussion service cannot check that news exist")
+	}
+
+	for _, prefix := range []string{"/api/v1.0", "/api/v2.0"} {
+		api := e.Group(prefix)
+
+		// Аутентификация
+		api.POST("/login", authHandler.Login)
+		api.POST("/refresh", authHandler.Refresh)
+
+		// Маршруты для Writer (регистрация открыта)
+		api.POST("/writers", writerHandler.Create)
+		api.GET("/writers/:id", writerHandler.GetById, requireAuth)
+		api.PUT("/writers", writerHandler.Update, requireAuth)
+		api.DELETE("/writers/:id", writerHandler.Delete, requireAuth)
+		api.GET("/writers", writerHandler.GetAll, requireAuth)
+
+		// Маршруты для News; сервис обсуждений проверяет существование новости с токеном DISCUSSION_TOKEN
+		api.POST("/news", newsHandler.Create, requireAuth)
+		api.GET("/news/:id", newsHandler.GetById, requireService)
+		api.PUT("/news", newsHandler.Update, requireAuth)
+		api.DELETE("/news/:id", newsHandler.Delete, requireAuth)
+		api.GET("/news", newsHandler.GetAll, requireAuth)
+
+		// Маршруты для Message
+		api.POST("/messages", messageHandler.Create, requireAuth)
+		api.GET("/messages/:id", messageHandler.GetById, requireAuth)
+		api.PUT("/messages", messageHandler.Update, requireAuth)
+		api.DELETE("/messages/:id", messageHandler.Delete, requireAuth)
+		api.GET("/messages", messageHandler.GetAll, requireAuth)
+
+		// Маршруты для Mark
+		api.POST("/marks", markHandler.Create, requireAuth)
+		api.GET("/marks/:id", markHandler.GetById, requireAuth)
+		api.PUT("/marks", markHandler.Update, requireAuth)
+		api.DELETE("/marks/:id", markHandler.Delete, requireAuth)
+		api.GET("/marks", markHandler.GetAll, requireAuth)
+	}
+
+	// Shutdown перестаёт принимать соединения и дожидается текущих запросов
+	lc.OnShutdown("http server", e.Shutdown)
+	lc.Go("http server", func() error {
+		slog.Info("publisher service starting", slog.String("addr", cfg.HTTP.Addr))
+		if err := e.Start(cfg.HTTP.Addr); !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		return nil
+	})
+	// Перед остановкой сервер сообщает о неготовности и ждёт DRAIN_PERIOD, пока балансировщик уберёт его из ротации
+	lc.OnShutdown("readiness", checker.DrainFor(cfg.HTTP.DrainPeriod))
+
+	return lc.Wait()
 }
@@ -1,52 +1,29 @@
 package db
 
 import (
-	"context"
 	"fmt"
-	"log"
-	"time"
+	"log/slog"
+	"os"
 
+	"RESTAPI/internal/config"
 	"RESTAPI/internal/entity"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
-// Connect устанавливает соединение с PostgreSQL
-func Connect() (*gorm.DB, error) {
-	dsn := fmt.Sprintf(
-		"host=%s user=%s password=%s dbname=%s port=%d sslmode=disable",
-		"localhost", // Адрес базы данных
-		"postgres",  // Пользователь
-		"postgres",  // Пароль
-		"distcomp",  // Название схемы/базы данных
-		5432,        // Порт
-	)
-
+// Connect устанавливает соединение с PostgreSQL.
+// Схема не создаётся: её применяют миграции из пакета db/migrations.
+func Connect(cfg config.Postgres) (*gorm.DB, error) {
 	// Подключение к базе данных
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	// TranslateError превращает ошибки драйвера (нарушение внешнего ключа и т.п.) в ошибки gorm
+	db, err := gorm.Open(postgres.Open(cfg.DSN()), &gorm.Config{TranslateError: true})
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
 
 	// Логирование успешного подключения
-	log.Println("Successfully connected to PostgreSQL")
-
-	// Автоматическое создание таблиц
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	err = db.WithContext(ctx).AutoMigrate(
-		&entity.Writer{},
-		&entity.News{},
-		&entity.Message{},
-		&entity.Mark{},
-	)
-	if err != nil {
-		return nil, fmt.Errorf("failed to migrate models: %w", err)
-	}
-
-	log.Println("Tables created successfully", entity.Message{}, entity.Writer{})
+	slog.Info("connected to PostgreSQL", slog.String("host", cfg.Host), slog.Int("port", cfg.Port))
 
 	return db, nil
 
@@ -56,7 +33,8 @@ func TestDatabaseConnection(db *gorm.DB) {
 	var writer entity.Writer
 	result := db.First(&writer)
 	if result.Error != nil {
-		log.Fatalf("Failed to fetch data: %v", result.Error)
+		slog.Error("failed to fetch data", slog.Any("error", result.Error))
+		os.Exit(1)
 	}
-	log.Printf("Fetched writer: %+v", writer)
+	slog.Info("fetched writer", slog.Int64("writer_id", writer.ID))
 }
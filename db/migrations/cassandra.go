@@ -0,0 +1,366 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gocql/gocql"
+)
+
+const (
+	// cassandraLockTTL bounds how long a crashed instance can hold the lock;
+	// the owner renews it every cassandraLockRenew while migrating
+	cassandraLockTTL   = 5 * time.Minute
+	cassandraLockRenew = cassandraLockTTL / 5
+	// cassandraLockPoll is the delay between attempts to take a busy lock
+	cassandraLockPoll = time.Second
+)
+
+var keyspacePattern = regexp.MustCompile(`^\w+$`)
+
+// CreateKeyspace creates the keyspace if it does not exist yet.
+// It connects without a keyspace, so it must run before sessions bound to it are created.
+func CreateKeyspace(cluster *gocql.ClusterConfig, keyspace string) error {
+	if !keyspacePattern.MatchString(keyspace) {
+		return fmt.Errorf("invalid keyspace name %q", keyspace)
+	}
+
+	bootstrap := *cluster
+	bootstrap.Keyspace = ""
+	session, err := bootstrap.CreateSession()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	return session.Query(fmt.Sprintf(`
+		CREATE KEYSPACE IF NOT EXISTS %s
+		WITH replication = {
+			'class': 'SimpleStrategy',
+			'replication_factor': 1
+		}`, keyspace)).Exec()
+}
+
+// Cassandra applies the embedded CQL migrations to the session's keyspace.
+// Cassandra has no transactional DDL: a migration that fails halfway is not
+// recorded and is retried in full, so its statements must be idempotent
+// (IF NOT EXISTS / IF EXISTS); adding an existing column or dropping a
+// missing one is skipped, see columnChanged. A migration may also have a data
+// step, see cassandraData, which runs after its up statements.
+type Cassandra struct {
+	session    *gocql.Session
+	migrations []Migration
+	owner      string
+}
+
+// NewCassandra creates a migrator for the discussion keyspace
+func NewCassandra(session *gocql.Session) (*Cassandra, error) {
+	migrations, err := load(cassandraFiles, "cassandra")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load migrations: %w", err)
+	}
+	for version := range cassandraData {
+		if !hasVersion(migrations, version) {
+			return nil, fmt.Errorf("data step of unknown migration %d", version)
+		}
+	}
+
+	host, _ := os.Hostname()
+	owner := host + ":" + strconv.Itoa(os.Getpid())
+	return &Cassandra{session: session, migrations: migrations, owner: owner}, nil
+}
+
+// Up applies all pending migrations and returns how many were applied
+func (c *Cassandra) Up(ctx context.Context) (int, error) {
+	return c.up(ctx, false)
+}
+
+// upOnStartup applies the pending migrations up to the first one with data
+// to copy, which fails with ErrDataStep; see UpOnStartup
+func (c *Cassandra) upOnStartup(ctx context.Context) (int, error) {
+	return c.up(ctx, true)
+}
+
+func (c *Cassandra) up(ctx context.Context, startup bool) (int, error) {
+	count := 0
+	err := c.withLock(ctx, func(ctx context.Context) error {
+		applied, err := c.applied(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, m := range c.migrations {
+			if _, ok := applied[m.Version]; ok {
+				continue
+			}
+			step, hasData := cassandraData[m.Version]
+			if hasData && startup {
+				empty, err := step.empty(ctx, c.session)
+				if err != nil {
+					return fmt.Errorf("data step of migration %s failed: %w", m, err)
+				}
+				if !empty {
+					return fmt.Errorf("migration %s: %w", m, ErrDataStep)
+				}
+			}
+			if err := c.exec(ctx, m.Up); err != nil {
+				return fmt.Errorf("migration %s failed: %w", m, err)
+			}
+			if hasData {
+				cp := &checkpoint{session: c.session, version: m.Version}
+				if err := step.run(ctx, c.session, cp); err != nil {
+					return fmt.Errorf("data step of migration %s failed: %w", m, err)
+				}
+			}
+			err := c.session.Query(`INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`,
+				m.Version, m.Name, time.Now()).WithContext(ctx).Exec()
+			if err != nil {
+				return fmt.Errorf("failed to record migration %s: %w", m, err)
+			}
+			slog.InfoContext(ctx, "applied migration", slog.String("migration", m.String()))
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// Down rolls back the last steps applied migrations and returns how many were rolled back
+func (c *Cassandra) Down(ctx context.Context, steps int) (int, error) {
+	count := 0
+	err := c.withLock(ctx, func(ctx context.Context) error {
+		applied, err := c.applied(ctx)
+		if err != nil {
+			return err
+		}
+		rollback, err := lastApplied(c.migrations, applied, steps)
+		if err != nil {
+			return err
+		}
+
+		for _, m := range rollback {
+			if err := c.exec(ctx, m.Down); err != nil {
+				return fmt.Errorf("rollback of %s failed: %w", m, err)
+			}
+			err := c.session.Query(`DELETE FROM schema_migrations WHERE version = ?`, m.Version).
+				WithContext(ctx).Exec()
+			if err != nil {
+				return fmt.Errorf("failed to unrecord migration %s: %w", m, err)
+			}
+			slog.InfoContext(ctx, "rolled back migration", slog.String("migration", m.String()))
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// Status lists the known and applied migrations
+func (c *Cassandra) Status(ctx context.Context) ([]Status, error) {
+	if err := c.createTables(ctx); err != nil {
+		return nil, err
+	}
+	applied, err := c.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return status(c.migrations, applied), nil
+}
+
+// exec runs the statements of a script one by one
+func (c *Cassandra) exec(ctx context.Context, script string) error {
+	for _, stmt := range statements(script) {
+		err := c.session.Query(stmt).WithContext(ctx).Exec()
+		if err != nil && columnChanged(stmt, err) {
+			slog.InfoContext(ctx, "column change already applied", slog.String("statement", stmt))
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// alterColumnPattern matches the statements adding or dropping a column
+var alterColumnPattern = regexp.MustCompile(`(?is)^ALTER\s+TABLE\s+\S+\s+(ADD|DROP)\s`)
+
+// columnChanged reports whether a statement adding or dropping a column
+// failed because the column is already there or already gone. Cassandra
+// before 5.0 has no IF [NOT] EXISTS for columns, so this makes such
+// statements idempotent.
+func columnChanged(stmt string, err error) bool {
+	match := alterColumnPattern.FindStringSubmatch(stmt)
+	if match == nil {
+		return false
+	}
+	if strings.EqualFold(match[1], "ADD") {
+		return strings.Contains(err.Error(), "conflicts with an existing column")
+	}
+	return strings.Contains(err.Error(), "was not found in table")
+}
+
+func hasVersion(migrations []Migration, version int64) bool {
+	for _, m := range migrations {
+		if m.Version == version {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Cassandra) createTables(ctx context.Context) error {
+	err := c.session.Query(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version bigint PRIMARY KEY,
+			name text,
+			applied_at timestamp
+		)`).WithContext(ctx).Exec()
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	err = c.session.Query(`
+		CREATE TABLE IF NOT EXISTS schema_migrations_progress (
+			version bigint PRIMARY KEY,
+			position bigint,
+			done bigint
+		)`).WithContext(ctx).Exec()
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations_progress: %w", err)
+	}
+
+	err = c.session.Query(`
+		CREATE TABLE IF NOT EXISTS schema_migrations_lock (
+			name text PRIMARY KEY,
+			owner text
+		)`).WithContext(ctx).Exec()
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations_lock: %w", err)
+	}
+	return nil
+}
+
+// withLock runs fn while holding the migration lock row.
+// The lock is a lightweight transaction with a TTL, so it is released even
+// when the owner dies. It is renewed while fn runs; if it is lost, e.g.
+// because renewing failed for longer than the TTL, the context of fn is
+// canceled so that two instances do not migrate at once.
+func (c *Cassandra) withLock(ctx context.Context, fn func(ctx context.Context) error) error {
+	if err := c.createTables(ctx); err != nil {
+		return err
+	}
+
+	ttl := int(cassandraLockTTL / time.Second)
+	for {
+		var name, owner string
+		acquired, err := c.session.Query(
+			`INSERT INTO schema_migrations_lock (name, owner) VALUES ('migrations', ?) IF NOT EXISTS USING TTL ?`,
+			c.owner, ttl).WithContext(ctx).ScanCAS(&name, &owner)
+		if err != nil {
+			return fmt.Errorf("failed to acquire migration lock: %w", err)
+		}
+		if acquired {
+			break
+		}
+
+		slog.InfoContext(ctx, "waiting for migration lock", slog.String("owner", owner))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(cassandraLockPoll):
+		}
+	}
+
+	lockCtx, cancel := context.WithCancelCause(ctx)
+	renewed := make(chan struct{})
+	go func() {
+		defer close(renewed)
+		c.renewLock(lockCtx, cancel)
+	}()
+
+	defer func() {
+		cancel(nil)
+		<-renewed
+		var owner string
+		_, err := c.session.Query(`DELETE FROM schema_migrations_lock WHERE name = 'migrations' IF owner = ?`,
+			c.owner).ScanCAS(&owner)
+		if err != nil {
+			slog.Warn("failed to release migration lock", slog.Any("error", err))
+		}
+	}()
+
+	if err := fn(lockCtx); err != nil {
+		if cause := context.Cause(lockCtx); errors.Is(cause, errLockLost) {
+			return fmt.Errorf("%w: %w", cause, err)
+		}
+		return err
+	}
+	return nil
+}
+
+// errLockLost cancels the migrations when another instance may hold the lock
+var errLockLost = errors.New("migration lock lost")
+
+// renewLock extends the TTL of the lock every cassandraLockRenew until ctx
+// is done. A failed renewal is retried on the next tick; the lock is given
+// up when it has expired or has been taken by another instance.
+func (c *Cassandra) renewLock(ctx context.Context, cancel context.CancelCauseFunc) {
+	ttl := int(cassandraLockTTL / time.Second)
+	expires := time.Now().Add(cassandraLockTTL)
+
+	ticker := time.NewTicker(cassandraLockRenew)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		var owner string
+		renewed, err := c.session.Query(
+			`UPDATE schema_migrations_lock USING TTL ? SET owner = ? WHERE name = 'migrations' IF owner = ?`,
+			ttl, c.owner, c.owner).WithContext(ctx).ScanCAS(&owner)
+		switch {
+		case err != nil && time.Now().Before(expires):
+			slog.WarnContext(ctx, "failed to renew migration lock", slog.Any("error", err))
+		case err != nil:
+			cancel(fmt.Errorf("%w: %v", errLockLost, err))
+			return
+		case !renewed && owner == "":
+			cancel(fmt.Errorf("%w: it expired", errLockLost))
+			return
+		case !renewed:
+			cancel(fmt.Errorf("%w: it is held by %s", errLockLost, owner))
+			return
+		default:
+			expires = time.Now().Add(cassandraLockTTL)
+		}
+	}
+}
+
+// applied reads schema_migrations
+func (c *Cassandra) applied(ctx context.Context) (map[int64]appliedVersion, error) {
+	applied := make(map[int64]appliedVersion)
+
+	iter := c.session.Query(`SELECT version, name, applied_at FROM schema_migrations`).WithContext(ctx).Iter()
+	var (
+		version int64
+		a       appliedVersion
+	)
+	for iter.Scan(&version, &a.name, &a.at) {
+		applied[version] = a
+	}
+	if err := iter.Close(); err != nil {
+		return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+	return applied, nil
+}
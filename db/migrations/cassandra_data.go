@@ -0,0 +1,204 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+
+	"github.com/gocql/gocql"
+	"golang.org/x/sync/errgroup"
+)
+
+// cassandraDataStep changes data in a way CQL cannot express, e.g. copying rows
+// between tables. It runs after the statements of its migration and has to be
+// idempotent like them.
+type cassandraDataStep struct {
+	// run does the step. A step over many rows saves its position in the
+	// checkpoint, so that a run that was interrupted resumes there.
+	run func(ctx context.Context, session *gocql.Session, cp *checkpoint) error
+	// empty reports whether there is nothing to do. Only then the step runs
+	// on startup; otherwise it runs with "migrate up", which has no deadline.
+	empty func(ctx context.Context, session *gocql.Session) (bool, error)
+}
+
+// cassandraData are the data steps by migration version
+var cassandraData = map[int64]cassandraDataStep{
+	4: {run: backfillMessagesByNews, empty: noMessages},
+}
+
+// ErrDataStep is returned on startup when a pending migration has data to copy
+var ErrDataStep = errors.New(`pending migration has data to copy, run "migrate up" first`)
+
+// checkpoint is the saved position of a data step in schema_migrations_progress
+type checkpoint struct {
+	session *gocql.Session
+	version int64
+}
+
+// load returns the saved position and rows done, or found false when the step starts anew
+func (cp *checkpoint) load(ctx context.Context) (position, done int64, found bool, err error) {
+	err = cp.session.Query(`SELECT position, done FROM schema_migrations_progress WHERE version = ?`, cp.version).
+		WithContext(ctx).Scan(&position, &done)
+	if errors.Is(err, gocql.ErrNotFound) {
+		return 0, 0, false, nil
+	}
+	if err != nil {
+		return 0, 0, false, fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+	return position, done, true, nil
+}
+
+// save records that everything up to position is done
+func (cp *checkpoint) save(ctx context.Context, position, done int64) error {
+	err := cp.session.Query(`INSERT INTO schema_migrations_progress (version, position, done) VALUES (?, ?, ?)`,
+		cp.version, position, done).WithContext(ctx).Exec()
+	if err != nil {
+		return fmt.Errorf("failed to save checkpoint: %w", err)
+	}
+	return nil
+}
+
+// clear removes the checkpoint of a finished step
+func (cp *checkpoint) clear(ctx context.Context) error {
+	err := cp.session.Query(`DELETE FROM schema_migrations_progress WHERE version = ?`, cp.version).
+		WithContext(ctx).Exec()
+	if err != nil {
+		return fmt.Errorf("failed to clear checkpoint: %w", err)
+	}
+	return nil
+}
+
+const (
+	// backfillPageSize is the number of rows read per page while copying
+	backfillPageSize = 500
+	// backfillBatchSize bounds the rows of one unlogged batch, which holds
+	// rows of a single partition
+	backfillBatchSize = 50
+	// backfillWorkers bounds the batches written at once
+	backfillWorkers = 8
+)
+
+// noMessages reports whether tbl_message is empty
+func noMessages(ctx context.Context, session *gocql.Session) (bool, error) {
+	var id int64
+	err := session.Query(`SELECT id FROM tbl_message LIMIT 1`).WithContext(ctx).Scan(&id)
+	if errors.Is(err, gocql.ErrNotFound) {
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read messages: %w", err)
+	}
+	return false, nil
+}
+
+// backfillMessagesByNews copies tbl_message into messages_by_news.
+//
+// The table is read in token order a page at a time. The rows of a page are
+// written as unlogged batches per news, a few at once, and the token of the
+// last row is saved once the whole page is written. Reading resumes at that
+// token, including it, since rows may share a token; copying a row twice is
+// harmless.
+func backfillMessagesByNews(ctx context.Context, session *gocql.Session, cp *checkpoint) error {
+	from, copied, found, err := cp.load(ctx)
+	if err != nil {
+		return err
+	}
+	if found {
+		slog.InfoContext(ctx, "resuming copy to messages_by_news", slog.Int64("token", from), slog.Int64("copied", copied))
+	} else {
+		from = math.MinInt64
+	}
+
+	for {
+		rows, last, err := backfillPage(ctx, session, from)
+		if err != nil {
+			return err
+		}
+		if err := writeByNews(ctx, session, rows); err != nil {
+			return err
+		}
+		copied += int64(len(rows))
+
+		if len(rows) < backfillPageSize {
+			break
+		}
+		if last == from {
+			return fmt.Errorf("more than %d messages share token %d", backfillPageSize, from)
+		}
+		from = last
+		if err := cp.save(ctx, from, copied); err != nil {
+			return err
+		}
+	}
+
+	if err := cp.clear(ctx); err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "copied messages to messages_by_news", slog.Int64("count", copied))
+	return nil
+}
+
+// byNewsRow is a row of messages_by_news
+type byNewsRow struct {
+	id, newsID                           int64
+	country, content, state, declineRule string
+}
+
+// backfillPage reads a page of tbl_message starting at token from and
+// returns its rows and the token of the last one
+func backfillPage(ctx context.Context, session *gocql.Session, from int64) ([]byNewsRow, int64, error) {
+	iter := session.Query(`
+		SELECT token(id), id, newsid, country, content, state, decline_rule
+		FROM tbl_message
+		WHERE token(id) >= ?
+		LIMIT ?`, from, backfillPageSize).WithContext(ctx).Iter()
+
+	rows := make([]byNewsRow, 0, backfillPageSize)
+	var (
+		row   byNewsRow
+		token int64
+		last  = from
+	)
+	for iter.Scan(&token, &row.id, &row.newsID, &row.country, &row.content, &row.state, &row.declineRule) {
+		rows = append(rows, row)
+		last = token
+	}
+	if err := iter.Close(); err != nil {
+		return nil, 0, fmt.Errorf("failed to read messages: %w", err)
+	}
+	return rows, last, nil
+}
+
+// writeByNews writes rows to messages_by_news as unlogged batches of one
+// partition each, at most backfillWorkers at once
+func writeByNews(ctx context.Context, session *gocql.Session, rows []byNewsRow) error {
+	byNews := make(map[int64][]byNewsRow)
+	for _, row := range rows {
+		byNews[row.newsID] = append(byNews[row.newsID], row)
+	}
+
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(backfillWorkers)
+	for _, partition := range byNews {
+		for len(partition) > 0 {
+			chunk := partition[:min(len(partition), backfillBatchSize)]
+			partition = partition[len(chunk):]
+			g.Go(func() error {
+				batch := session.NewBatch(gocql.UnloggedBatch).WithContext(ctx)
+				for _, row := range chunk {
+					batch.Query(`
+						INSERT INTO messages_by_news (newsid, id, country, content, state, decline_rule)
+						VALUES (?, ?, ?, ?, ?, ?)`,
+						row.newsID, row.id, row.country, row.content, row.state, row.declineRule)
+				}
+				if err := session.ExecuteBatch(batch); err != nil {
+					return fmt.Errorf("failed to copy messages of news %d: %w", chunk[0].newsID, err)
+				}
+				return nil
+			})
+		}
+	}
+	return g.Wait()
+}
@@ -0,0 +1,105 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+// Migrator is implemented by the Postgres and Cassandra migrators
+type Migrator interface {
+	Up(ctx context.Context) (int, error)
+	Down(ctx context.Context, steps int) (int, error)
+	Status(ctx context.Context) ([]Status, error)
+}
+
+// UpOnStartup applies the pending migrations when a service starts. timeout
+// bounds how long they may take; zero means no limit. Copying data can take
+// longer than a start should, so a Cassandra migration with rows to copy
+// fails with ErrDataStep and is left to "migrate up".
+func UpOnStartup(m Migrator, timeout time.Duration) (int, error) {
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	if c, ok := m.(*Cassandra); ok {
+		return c.upOnStartup(ctx)
+	}
+	return m.Up(ctx)
+}
+
+// Usage describes the migrate subcommand
+const Usage = "usage: migrate up | down [N] | status"
+
+var ErrUsage = errors.New(Usage)
+
+// RunCommand executes "migrate up", "migrate down [N]" or "migrate status".
+// args are the arguments after "migrate".
+func RunCommand(ctx context.Context, m Migrator, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return ErrUsage
+	}
+
+	switch args[0] {
+	case "up":
+		if len(args) != 1 {
+			return ErrUsage
+		}
+		n, err := m.Up(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "applied %d migration(s)\n", n)
+		return nil
+
+	case "down":
+		steps := 1
+		if len(args) > 2 {
+			return ErrUsage
+		}
+		if len(args) == 2 {
+			var err error
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps <= 0 {
+				return ErrUsage
+			}
+		}
+		n, err := m.Down(ctx, steps)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "rolled back %d migration(s)\n", n)
+		return nil
+
+	case "status":
+		if len(args) != 1 {
+			return ErrUsage
+		}
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+		for _, s := range statuses {
+			state, at := "pending", ""
+			if s.Applied {
+				state, at = "applied", s.AppliedAt.Format(time.RFC3339)
+				if s.Up == "" {
+					state = "applied (unknown)"
+				}
+			}
+			fmt.Fprintf(w, "%04d\t%s\t%s\t%s\n", s.Version, s.Name, state, at)
+		}
+		return w.Flush()
+
+	default:
+		return ErrUsage
+	}
+}
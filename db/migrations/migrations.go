@@ -0,0 +1,184 @@
+// Package migrations applies the versioned schemas of the publisher (PostgreSQL)
+// and discussion (Cassandra) databases.
+//
+// Migrations are numbered up/down files embedded into the binaries:
+//
+//	postgres/0001_init.up.sql     postgres/0001_init.down.sql
+//	cassandra/0001_message_table.up.cql ...
+//
+// Applied versions are recorded in a schema_migrations table, and a lock
+// (a PostgreSQL advisory lock or a Cassandra LWT row) keeps concurrently
+// starting instances from applying the same migration twice.
+package migrations
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed postgres/*.sql
+var postgresFiles embed.FS
+
+//go:embed cassandra/*.cql
+var cassandraFiles embed.FS
+
+// Migration is a single schema version
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+func (m Migration) String() string {
+	return fmt.Sprintf("%04d_%s", m.Version, m.Name)
+}
+
+// Status describes a migration and whether it is applied.
+// Versions that are recorded in the database but unknown to the binary are
+// reported with an empty Up/Down script.
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// fileNamePattern matches "0001_create_tables.up.sql"
+var fileNamePattern = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.(sql|cql)$`)
+
+// load reads the migrations in dir and returns them ordered by version.
+// Every version must have both an up and a down file.
+func load(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		match := fileNamePattern.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			return nil, fmt.Errorf("unexpected file %s/%s", dir, entry.Name())
+		}
+
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("invalid migration version in %s", entry.Name())
+		}
+		body, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if strings.TrimSpace(m.Up) == "" || strings.TrimSpace(m.Down) == "" {
+			return nil, fmt.Errorf("migration %s needs both up and down files", m)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// statements splits a script into single statements for drivers that cannot
+// execute several at once. Statements end with ';', lines starting with "--"
+// are comments.
+func statements(script string) []string {
+	var b strings.Builder
+	for _, line := range strings.Split(script, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "--") {
+			continue
+		}
+		b.WriteString(line)
+		b.WriteByte('\n')
+	}
+
+	var result []string
+	for _, stmt := range strings.Split(b.String(), ";") {
+		if stmt = strings.TrimSpace(stmt); stmt != "" {
+			result = append(result, stmt)
+		}
+	}
+	return result
+}
+
+// status merges the known migrations with the applied versions
+func status(migrations []Migration, applied map[int64]appliedVersion) []Status {
+	result := make([]Status, 0, len(migrations))
+	known := make(map[int64]bool, len(migrations))
+	for _, m := range migrations {
+		known[m.Version] = true
+		a, ok := applied[m.Version]
+		result = append(result, Status{Migration: m, Applied: ok, AppliedAt: a.at})
+	}
+	for version, a := range applied {
+		if !known[version] {
+			result = append(result, Status{
+				Migration: Migration{Version: version, Name: a.name},
+				Applied:   true,
+				AppliedAt: a.at,
+			})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Version < result[j].Version
+	})
+	return result
+}
+
+// appliedVersion is a row of schema_migrations
+type appliedVersion struct {
+	name string
+	at   time.Time
+}
+
+// lastApplied returns up to steps applied migrations, newest first
+func lastApplied(migrations []Migration, applied map[int64]appliedVersion, steps int) ([]Migration, error) {
+	known := make(map[int64]Migration, len(migrations))
+	for _, m := range migrations {
+		known[m.Version] = m
+	}
+
+	versions := make([]int64, 0, len(applied))
+	for version := range applied {
+		versions = append(versions, version)
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i] > versions[j] })
+
+	if steps > len(versions) {
+		steps = len(versions)
+	}
+	result := make([]Migration, 0, steps)
+	for _, version := range versions[:steps] {
+		m, ok := known[version]
+		if !ok {
+			return nil, fmt.Errorf("applied migration %04d_%s is unknown to this binary", version, applied[version].name)
+		}
+		result = append(result, m)
+	}
+	return result, nil
+}
@@ -0,0 +1,150 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// postgresLockKey identifies the advisory lock held while migrating
+const postgresLockKey int64 = 0x64697374636f6d70 // "distcomp"
+
+// Postgres applies the embedded SQL migrations.
+// Every migration runs in its own transaction together with its
+// schema_migrations record, so a failed migration leaves no trace.
+type Postgres struct {
+	db         *gorm.DB
+	migrations []Migration
+}
+
+// NewPostgres creates a migrator for the publisher database
+func NewPostgres(db *gorm.DB) (*Postgres, error) {
+	migrations, err := load(postgresFiles, "postgres")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load migrations: %w", err)
+	}
+	return &Postgres{db: db, migrations: migrations}, nil
+}
+
+// Up applies all pending migrations and returns how many were applied
+func (p *Postgres) Up(ctx context.Context) (int, error) {
+	count := 0
+	err := p.withLock(ctx, func(conn *gorm.DB) error {
+		applied, err := p.applied(conn)
+		if err != nil {
+			return err
+		}
+
+		for _, m := range p.migrations {
+			if _, ok := applied[m.Version]; ok {
+				continue
+			}
+			err := conn.Transaction(func(tx *gorm.DB) error {
+				if err := tx.Exec(m.Up).Error; err != nil {
+					return err
+				}
+				return tx.Exec(`INSERT INTO schema_migrations (version, name) VALUES (?, ?)`, m.Version, m.Name).Error
+			})
+			if err != nil {
+				return fmt.Errorf("migration %s failed: %w", m, err)
+			}
+			slog.InfoContext(ctx, "applied migration", slog.String("migration", m.String()))
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// Down rolls back the last steps applied migrations and returns how many were rolled back
+func (p *Postgres) Down(ctx context.Context, steps int) (int, error) {
+	count := 0
+	err := p.withLock(ctx, func(conn *gorm.DB) error {
+		applied, err := p.applied(conn)
+		if err != nil {
+			return err
+		}
+		rollback, err := lastApplied(p.migrations, applied, steps)
+		if err != nil {
+			return err
+		}
+
+		for _, m := range rollback {
+			err := conn.Transaction(func(tx *gorm.DB) error {
+				if err := tx.Exec(m.Down).Error; err != nil {
+					return err
+				}
+				return tx.Exec(`DELETE FROM schema_migrations WHERE version = ?`, m.Version).Error
+			})
+			if err != nil {
+				return fmt.Errorf("rollback of %s failed: %w", m, err)
+			}
+			slog.InfoContext(ctx, "rolled back migration", slog.String("migration", m.String()))
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// Status lists the known and applied migrations
+func (p *Postgres) Status(ctx context.Context) ([]Status, error) {
+	var result []Status
+	err := p.withLock(ctx, func(conn *gorm.DB) error {
+		applied, err := p.applied(conn)
+		if err != nil {
+			return err
+		}
+		result = status(p.migrations, applied)
+		return nil
+	})
+	return result, err
+}
+
+// withLock runs fn on a single connection holding the advisory lock.
+// Advisory locks belong to a session, so the lock, the migrations and the
+// unlock must share one connection.
+func (p *Postgres) withLock(ctx context.Context, fn func(conn *gorm.DB) error) error {
+	return p.db.WithContext(ctx).Connection(func(conn *gorm.DB) error {
+		if err := conn.Exec(`SELECT pg_advisory_lock(?)`, postgresLockKey).Error; err != nil {
+			return fmt.Errorf("failed to acquire migration lock: %w", err)
+		}
+		defer func() {
+			if err := conn.Exec(`SELECT pg_advisory_unlock(?)`, postgresLockKey).Error; err != nil {
+				slog.Warn("failed to release migration lock", slog.Any("error", err))
+			}
+		}()
+
+		err := conn.Exec(`
+			CREATE TABLE IF NOT EXISTS schema_migrations (
+				version    BIGINT PRIMARY KEY,
+				name       TEXT NOT NULL,
+				applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+			)`).Error
+		if err != nil {
+			return fmt.Errorf("failed to create schema_migrations: %w", err)
+		}
+		return fn(conn)
+	})
+}
+
+// applied reads schema_migrations
+func (p *Postgres) applied(conn *gorm.DB) (map[int64]appliedVersion, error) {
+	var rows []struct {
+		Version   int64
+		Name      string
+		AppliedAt time.Time
+	}
+	if err := conn.Raw(`SELECT version, name, applied_at FROM schema_migrations`).Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+
+	applied := make(map[int64]appliedVersion, len(rows))
+	for _, row := range rows {
+		applied[row.Version] = appliedVersion{name: row.Name, at: row.AppliedAt}
+	}
+	return applied, nil
+}
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS news_mark;
+DROP TABLE IF EXISTS tbl_message;
+DROP TABLE IF EXISTS tbl_mark;
+DROP TABLE IF EXISTS tbl_news;
+DROP TABLE IF EXISTS tbl_writer;
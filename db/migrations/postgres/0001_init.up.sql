@@ -0,0 +1,34 @@
+-- Базовая схема (совпадает со схемой, которую раньше создавал AutoMigrate)
+CREATE TABLE IF NOT EXISTS tbl_writer (
+    id        BIGSERIAL PRIMARY KEY,
+    login     VARCHAR(64)  NOT NULL UNIQUE,
+    password  VARCHAR(128) NOT NULL,
+    firstname VARCHAR(64)  NOT NULL,
+    lastname  VARCHAR(64)  NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS tbl_news (
+    id        BIGSERIAL PRIMARY KEY,
+    writer_id BIGINT       NOT NULL,
+    title     VARCHAR(255) NOT NULL,
+    content   TEXT         NOT NULL,
+    created   TIMESTAMPTZ  DEFAULT CURRENT_TIMESTAMP,
+    modified  TIMESTAMPTZ  DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS tbl_mark (
+    id   BIGSERIAL PRIMARY KEY,
+    name VARCHAR(32) NOT NULL UNIQUE
+);
+
+CREATE TABLE IF NOT EXISTS tbl_message (
+    id      BIGSERIAL PRIMARY KEY,
+    news_id BIGINT NOT NULL,
+    content TEXT   NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS news_mark (
+    news_id BIGINT NOT NULL REFERENCES tbl_news (id),
+    mark_id BIGINT NOT NULL REFERENCES tbl_mark (id),
+    PRIMARY KEY (news_id, mark_id)
+);
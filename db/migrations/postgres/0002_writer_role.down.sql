@@ -0,0 +1 @@
+ALTER TABLE tbl_writer DROP COLUMN IF EXISTS role;
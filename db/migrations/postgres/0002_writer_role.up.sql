@@ -0,0 +1 @@
+ALTER TABLE tbl_writer ADD COLUMN IF NOT EXISTS role VARCHAR(16) NOT NULL DEFAULT 'CUSTOMER';
@@ -0,0 +1,7 @@
+-- Автор сообщения; у сообщений, созданных до появления авторства, остаётся 0
+ALTER TABLE tbl_message ADD COLUMN IF NOT EXISTS writer_id BIGINT NOT NULL DEFAULT 0;
+
+-- Базы, созданные через AutoMigrate, уже содержат nullable-колонку
+UPDATE tbl_message SET writer_id = 0 WHERE writer_id IS NULL;
+ALTER TABLE tbl_message ALTER COLUMN writer_id SET DEFAULT 0;
+ALTER TABLE tbl_message ALTER COLUMN writer_id SET NOT NULL;
@@ -0,0 +1,2 @@
+ALTER TABLE tbl_message DROP CONSTRAINT IF EXISTS fk_tbl_message_news;
+ALTER TABLE tbl_news DROP CONSTRAINT IF EXISTS fk_tbl_news_writer;
@@ -0,0 +1,32 @@
+-- Ограничения не создадутся, пока есть записи, ссылающиеся на несуществующих
+-- писателей и новости. Миграция их не удаляет: она завершается ошибкой со
+-- списком ID, а что с ними делать — удалить или привязать к существующим
+-- записям, — решает оператор, после чего миграция запускается снова.
+DO $$
+DECLARE
+    news     text;
+    messages text;
+BEGIN
+    SELECT string_agg(n.id::text, ', ' ORDER BY n.id) INTO news
+    FROM tbl_news n
+    WHERE NOT EXISTS (SELECT 1 FROM tbl_writer w WHERE w.id = n.writer_id);
+
+    SELECT string_agg(m.id::text, ', ' ORDER BY m.id) INTO messages
+    FROM tbl_message m
+    WHERE NOT EXISTS (SELECT 1 FROM tbl_news n WHERE n.id = m.news_id);
+
+    IF news IS NOT NULL OR messages IS NOT NULL THEN
+        RAISE EXCEPTION 'rows without a parent block the foreign keys: tbl_news without writer: %; tbl_message without news: %',
+            coalesce(news, 'none'), coalesce(messages, 'none')
+            USING HINT = 'delete these rows or point them at existing ones, then run the migration again';
+    END IF;
+END
+$$;
+
+ALTER TABLE tbl_news DROP CONSTRAINT IF EXISTS fk_tbl_news_writer;
+ALTER TABLE tbl_news ADD CONSTRAINT fk_tbl_news_writer
+    FOREIGN KEY (writer_id) REFERENCES tbl_writer (id) ON UPDATE CASCADE ON DELETE CASCADE;
+
+ALTER TABLE tbl_message DROP CONSTRAINT IF EXISTS fk_tbl_message_news;
+ALTER TABLE tbl_message ADD CONSTRAINT fk_tbl_message_news
+    FOREIGN KEY (news_id) REFERENCES tbl_news (id) ON UPDATE CASCADE ON DELETE CASCADE;
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_news_created_id;
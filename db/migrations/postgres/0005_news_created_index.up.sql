@@ -0,0 +1,2 @@
+-- Индекс для курсорной пагинации новостей по (created, id)
+CREATE INDEX IF NOT EXISTS idx_news_created_id ON tbl_news (created, id);
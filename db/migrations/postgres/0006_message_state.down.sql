@@ -0,0 +1 @@
+ALTER TABLE tbl_message DROP COLUMN IF EXISTS state;
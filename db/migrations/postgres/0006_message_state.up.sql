@@ -0,0 +1,2 @@
+-- Состояние модерации, которое сервис обсуждений возвращает через message-out
+ALTER TABLE tbl_message ADD COLUMN IF NOT EXISTS state VARCHAR(16) NOT NULL DEFAULT 'PENDING';
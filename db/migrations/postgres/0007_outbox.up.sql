@@ -0,0 +1,16 @@
+-- Исходящие события: пишутся в одной транзакции с изменением новости, писателя
+-- или метки и публикуются в Kafka фоновым ретранслятором (internal/outbox)
+CREATE TABLE IF NOT EXISTS tbl_outbox (
+    id             BIGSERIAL PRIMARY KEY,
+    aggregate_type VARCHAR(32)  NOT NULL,
+    aggregate_id   BIGINT       NOT NULL,
+    event_type     VARCHAR(64)  NOT NULL,
+    topic          VARCHAR(64)  NOT NULL,
+    payload        JSONB        NOT NULL,
+    request_id     VARCHAR(64)  NOT NULL DEFAULT '',
+    created        TIMESTAMPTZ  NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    sent_at        TIMESTAMPTZ
+);
+
+-- Неотправленные события выбираются по порядку id
+CREATE INDEX IF NOT EXISTS idx_outbox_unsent ON tbl_outbox (id) WHERE sent_at IS NULL;
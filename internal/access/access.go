@@ -0,0 +1,34 @@
+// Package access wraps the services with role and ownership rules.
+//
+// Reads are passed through unchanged; every mutating call takes the Principal
+// performing it and fails with a Forbidden error when the rules do not allow it:
+//   - ADMIN may do anything
+//   - CUSTOMER may update or delete only their own news and messages,
+//     may update or delete only their own writer account and cannot change roles
+//   - only ADMIN may manage marks
+package access
+
+import (
+	"RESTAPI/internal/apperr"
+	"RESTAPI/internal/entity"
+)
+
+// Principal is the authenticated writer performing a call
+type Principal struct {
+	WriterID int64
+	Role     entity.Role
+}
+
+// IsAdmin reports whether the principal has the ADMIN role
+func (p Principal) IsAdmin() bool {
+	return p.Role == entity.RoleAdmin
+}
+
+// owns reports whether the principal may modify a resource owned by writerID
+func (p Principal) owns(writerID int64) bool {
+	return p.IsAdmin() || (writerID != 0 && p.WriterID == writerID)
+}
+
+func forbidden(format string, args ...interface{}) error {
+	return apperr.Forbidden(format, args...)
+}
@@ -0,0 +1,155 @@
+package access
+
+import (
+	"RESTAPI/internal/dto"
+	"RESTAPI/internal/entity"
+	"RESTAPI/internal/service"
+	"context"
+)
+
+// WriterService guards writer management
+type WriterService struct {
+	*service.WriterService
+}
+
+func NewWriterService(s *service.WriterService) *WriterService {
+	return &WriterService{WriterService: s}
+}
+
+func (s *WriterService) Update(ctx context.Context, p Principal, req dto.WriterUpdateRequestTo) (*dto.WriterResponseTo, error) {
+	if !p.IsAdmin() {
+		if req.ID != p.WriterID {
+			return nil, forbidden("only ADMIN can manage other writers")
+		}
+		if req.Role != "" && entity.Role(req.Role) != p.Role {
+			return nil, forbidden("only ADMIN can change roles")
+		}
+	}
+	return s.WriterService.Update(ctx, req)
+}
+
+func (s *WriterService) Delete(ctx context.Context, p Principal, id int64) error {
+	if !p.IsAdmin() && id != p.WriterID {
+		return forbidden("only ADMIN can manage other writers")
+	}
+	return s.WriterService.Delete(ctx, id)
+}
+
+// NewsService guards news ownership
+type NewsService struct {
+	*service.NewsService
+}
+
+func NewNewsService(s *service.NewsService) *NewsService {
+	return &NewsService{NewsService: s}
+}
+
+func (s *NewsService) Create(ctx context.Context, p Principal, req dto.NewsRequestTo) (*dto.NewsResponseTo, error) {
+	if !p.owns(req.WriterID) {
+		return nil, forbidden("news can only be published under your own writer ID")
+	}
+	return s.NewsService.Create(ctx, req, p.IsAdmin())
+}
+
+func (s *NewsService) Update(ctx context.Context, p Principal, req dto.NewsUpdateRequestTo) (*dto.NewsResponseTo, error) {
+	if !p.IsAdmin() {
+		existing, err := s.NewsService.GetById(ctx, req.ID)
+		if err != nil {
+			return nil, err
+		}
+		if !p.owns(existing.WriterID) || !p.owns(req.WriterID) {
+			return nil, forbidden("you can only modify your own news")
+		}
+	}
+	return s.NewsService.Update(ctx, req)
+}
+
+func (s *NewsService) Delete(ctx context.Context, p Principal, id int64) error {
+	if !p.IsAdmin() {
+		existing, err := s.NewsService.GetById(ctx, id)
+		if err != nil {
+			return err
+		}
+		if !p.owns(existing.WriterID) {
+			return forbidden("you can only delete your own news")
+		}
+	}
+	return s.NewsService.Delete(ctx, id)
+}
+
+// MarkService restricts mark management to administrators
+type MarkService struct {
+	*service.MarkService
+}
+
+func NewMarkService(s *service.MarkService) *MarkService {
+	return &MarkService{MarkService: s}
+}
+
+func (s *MarkService) Create(ctx context.Context, p Principal, req dto.MarkRequestTo) (*dto.MarkResponseTo, error) {
+	if !p.IsAdmin() {
+		return nil, forbidden("only ADMIN can manage marks")
+	}
+	return s.MarkService.Create(ctx, req)
+}
+
+func (s *MarkService) Update(ctx context.Context, p Principal, req dto.MarkUpdateRequestTo) (*dto.MarkResponseTo, error) {
+	if !p.IsAdmin() {
+		return nil, forbidden("only ADMIN can manage marks")
+	}
+	return s.MarkService.Update(ctx, req)
+}
+
+func (s *MarkService) Delete(ctx context.Context, p Principal, id int64) error {
+	if !p.IsAdmin() {
+		return forbidden("only ADMIN can manage marks")
+	}
+	return s.MarkService.Delete(ctx, id)
+}
+
+// MessageService guards message ownership
+type MessageService struct {
+	*service.MessageService
+}
+
+func NewMessageService(s *service.MessageService) *MessageService {
+	return &MessageService{MessageService: s}
+}
+
+func (s *MessageService) Create(ctx context.Context, p Principal, req dto.MessageRequestTo) (*dto.MessageResponseTo, error) {
+	req.WriterID = p.WriterID
+	return s.MessageService.Create(ctx, req)
+}
+
+func (s *MessageService) CreateAndWait(ctx context.Context, p Principal, req dto.MessageRequestTo) (*dto.MessageResponseTo, error) {
+	req.WriterID = p.WriterID
+	return s.MessageService.CreateAndWait(ctx, req)
+}
+
+func (s *MessageService) Update(ctx context.Context, p Principal, req dto.MessageUpdateRequestTo) (*dto.MessageResponseTo, error) {
+	if err := s.checkOwner(ctx, p, req.ID); err != nil {
+		return nil, err
+	}
+	return s.MessageService.Update(ctx, req)
+}
+
+func (s *MessageService) Delete(ctx context.Context, p Principal, id int64) error {
+	if err := s.checkOwner(ctx, p, id); err != nil {
+		return err
+	}
+	return s.MessageService.Delete(ctx, id)
+}
+
+func (s *MessageService) checkOwner(ctx context.Context, p Principal, id int64) error {
+	if p.IsAdmin() {
+		return nil
+	}
+	writerID, err := s.MessageService.WriterOf(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !p.owns(writerID) {
+		return forbidden("you can only modify your own messages")
+	}
+	return nil
+}
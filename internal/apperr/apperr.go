@@ -0,0 +1,117 @@
+// Package apperr defines the typed errors returned by services and repositories.
+// The HTTP layer maps each Kind to a status code in one place.
+package apperr
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Kind classifies an error
+type Kind int
+
+const (
+	KindInternal Kind = iota
+	KindNotFound
+	KindConflict
+	KindValidation
+	KindForbidden
+	KindUnauthorized
+	KindUnavailable
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindNotFound:
+		return "not-found"
+	case KindConflict:
+		return "conflict"
+	case KindValidation:
+		return "validation"
+	case KindForbidden:
+		return "forbidden"
+	case KindUnauthorized:
+		return "unauthorized"
+	case KindUnavailable:
+		return "unavailable"
+	default:
+		return "internal"
+	}
+}
+
+// FieldError describes a single invalid field of a request
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// Error is a classified error
+type Error struct {
+	Kind    Kind
+	Message string
+	Fields  []FieldError
+	Err     error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Wrap attaches an underlying cause to a copy of e
+func (e *Error) Wrap(err error) *Error {
+	wrapped := *e
+	wrapped.Err = err
+	return &wrapped
+}
+
+func newError(kind Kind, format string, args []interface{}) *Error {
+	return &Error{Kind: kind, Message: fmt.Sprintf(format, args...)}
+}
+
+func NotFound(format string, args ...interface{}) *Error {
+	return newError(KindNotFound, format, args)
+}
+
+func Conflict(format string, args ...interface{}) *Error {
+	return newError(KindConflict, format, args)
+}
+
+func Forbidden(format string, args ...interface{}) *Error {
+	return newError(KindForbidden, format, args)
+}
+
+func Unauthorized(format string, args ...interface{}) *Error {
+	return newError(KindUnauthorized, format, args)
+}
+
+func Unavailable(format string, args ...interface{}) *Error {
+	return newError(KindUnavailable, format, args)
+}
+
+// Validation creates a validation error with optional per-field details
+func Validation(message string, fields ...FieldError) *Error {
+	return &Error{Kind: KindValidation, Message: message, Fields: fields}
+}
+
+// KindOf returns the kind of the first *Error in err's chain, or KindInternal
+func KindOf(err error) Kind {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Kind
+	}
+	return KindInternal
+}
+
+// IsNotFound reports whether err is a NotFound error
+func IsNotFound(err error) bool {
+	return KindOf(err) == KindNotFound
+}
@@ -0,0 +1,59 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"strings"
+
+	"RESTAPI/internal/apperr"
+
+	"github.com/labstack/echo/v4"
+)
+
+const claimsKey = "auth.claims"
+
+// Middleware rejects requests without a valid "Authorization: Bearer <access token>"
+// header and stores the token claims in the echo context
+func Middleware(tokens *TokenManager) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			header := c.Request().Header.Get(echo.HeaderAuthorization)
+			raw, ok := strings.CutPrefix(header, "Bearer ")
+			if !ok || raw == "" {
+				c.Response().Header().Set(echo.HeaderWWWAuthenticate, `Bearer`)
+				return apperr.Unauthorized("missing bearer token")
+			}
+
+			claims, err := tokens.Parse(raw, TokenTypeAccess)
+			if err != nil {
+				c.Response().Header().Set(echo.HeaderWWWAuthenticate, `Bearer error="invalid_token"`)
+				return apperr.Unauthorized("invalid or expired token")
+			}
+
+			c.Set(claimsKey, claims)
+			return next(c)
+		}
+	}
+}
+
+// ServiceMiddleware is Middleware that also accepts serviceToken, the bearer
+// token another service reads with; such requests carry no claims. An empty
+// serviceToken accepts writer tokens only.
+func ServiceMiddleware(tokens *TokenManager, serviceToken string) echo.MiddlewareFunc {
+	writers := Middleware(tokens)
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		writer := writers(next)
+		return func(c echo.Context) error {
+			raw, ok := strings.CutPrefix(c.Request().Header.Get(echo.HeaderAuthorization), "Bearer ")
+			if ok && serviceToken != "" && subtle.ConstantTimeCompare([]byte(raw), []byte(serviceToken)) == 1 {
+				return next(c)
+			}
+			return writer(c)
+		}
+	}
+}
+
+// ClaimsFrom returns the claims stored by Middleware, or nil for anonymous requests
+func ClaimsFrom(c echo.Context) *Claims {
+	claims, _ := c.Get(claimsKey).(*Claims)
+	return claims
+}
@@ -0,0 +1,184 @@
+// Package auth issues and verifies JWTs for writers
+package auth
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	TokenTypeAccess  = "access"
+	TokenTypeRefresh = "refresh"
+)
+
+// ErrInvalidToken is returned for tokens that are malformed, expired, signed
+// with an unknown key or of the wrong type
+var ErrInvalidToken = errors.New("invalid token")
+
+// Config holds the signing keys and token lifetimes.
+// Keys maps a key ID (kid) to its HMAC secret; new tokens are signed with
+// ActiveKeyID while tokens signed with any other listed key remain valid,
+// which allows keys to be rotated without logging everyone out.
+type Config struct {
+	Issuer      string
+	Keys        map[string][]byte
+	ActiveKeyID string
+	AccessTTL   time.Duration
+	RefreshTTL  time.Duration
+}
+
+// NewConfig builds a Config from a "kid1:secret1,kid2:secret2" key list,
+// the active key ID and the token lifetimes. Without keys a random key is
+// generated, so tokens do not survive a restart.
+func NewConfig(rawKeys, activeKeyID string, accessTTL, refreshTTL time.Duration) (Config, error) {
+	cfg := Config{
+		Issuer:      "distcomp-publisher",
+		Keys:        map[string][]byte{},
+		ActiveKeyID: activeKeyID,
+		AccessTTL:   accessTTL,
+		RefreshTTL:  refreshTTL,
+	}
+
+	if rawKeys != "" {
+		keys, err := ParseKeys(rawKeys)
+		if err != nil {
+			return cfg, err
+		}
+		cfg.Keys = keys
+		return cfg, nil
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return cfg, fmt.Errorf("failed to generate signing key: %w", err)
+	}
+	slog.Warn("no JWT signing keys configured, using an ephemeral key")
+	cfg.Keys["ephemeral"] = secret
+	cfg.ActiveKeyID = "ephemeral"
+	return cfg, nil
+}
+
+// ParseKeys parses a "kid1:secret1,kid2:secret2" key list
+func ParseKeys(raw string) (map[string][]byte, error) {
+	keys := map[string][]byte{}
+	for _, pair := range strings.Split(raw, ",") {
+		kid, secret, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		if !ok || kid == "" || secret == "" {
+			return nil, fmt.Errorf("malformed signing key entry %q", pair)
+		}
+		keys[kid] = []byte(secret)
+	}
+	return keys, nil
+}
+
+// Claims are the JWT claims issued to a writer
+type Claims struct {
+	Login string `json:"login"`
+	Role  string `json:"role"`
+	Type  string `json:"typ"`
+	jwt.RegisteredClaims
+}
+
+// WriterID returns the writer ID stored in the subject claim
+func (c *Claims) WriterID() int64 {
+	id, _ := strconv.ParseInt(c.Subject, 10, 64)
+	return id
+}
+
+// TokenPair is the result of a successful login or refresh
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    time.Duration
+}
+
+// TokenManager signs and verifies tokens
+type TokenManager struct {
+	cfg Config
+}
+
+// NewTokenManager validates cfg and creates a TokenManager
+func NewTokenManager(cfg Config) (*TokenManager, error) {
+	if len(cfg.Keys) == 0 {
+		return nil, fmt.Errorf("at least one signing key is required")
+	}
+	if cfg.ActiveKeyID == "" {
+		if len(cfg.Keys) > 1 {
+			return nil, fmt.Errorf("active key ID is required when several keys are configured")
+		}
+		for kid := range cfg.Keys {
+			cfg.ActiveKeyID = kid
+		}
+	}
+	if _, ok := cfg.Keys[cfg.ActiveKeyID]; !ok {
+		return nil, fmt.Errorf("active key %q is not among the configured keys", cfg.ActiveKeyID)
+	}
+	return &TokenManager{cfg: cfg}, nil
+}
+
+// Issue creates an access/refresh token pair for a writer
+func (m *TokenManager) Issue(writerID int64, login, role string) (*TokenPair, error) {
+	access, err := m.sign(writerID, login, role, TokenTypeAccess, m.cfg.AccessTTL)
+	if err != nil {
+		return nil, err
+	}
+	refresh, err := m.sign(writerID, login, role, TokenTypeRefresh, m.cfg.RefreshTTL)
+	if err != nil {
+		return nil, err
+	}
+	return &TokenPair{AccessToken: access, RefreshToken: refresh, ExpiresIn: m.cfg.AccessTTL}, nil
+}
+
+func (m *TokenManager) sign(writerID int64, login, role, tokenType string, ttl time.Duration) (string, error) {
+	now := time.Now()
+	claims := Claims{
+		Login: login,
+		Role:  role,
+		Type:  tokenType,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    m.cfg.Issuer,
+			Subject:   strconv.FormatInt(writerID, 10),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token.Header["kid"] = m.cfg.ActiveKeyID
+	signed, err := token.SignedString(m.cfg.Keys[m.cfg.ActiveKeyID])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign token: %w", err)
+	}
+	return signed, nil
+}
+
+// Parse verifies a token of the expected type and returns its claims
+func (m *TokenManager) Parse(raw, tokenType string) (*Claims, error) {
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(raw, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := m.cfg.Keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown key %q", kid)
+		}
+		return key, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(m.cfg.Issuer),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	if claims.Type != tokenType {
+		return nil, fmt.Errorf("%w: expected %s token", ErrInvalidToken, tokenType)
+	}
+	return claims, nil
+}
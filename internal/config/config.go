@@ -0,0 +1,283 @@
+// Package config loads the configuration of both services.
+//
+// Values are layered, later sources overriding earlier ones:
+//  1. defaults: the values already present in the struct passed to Load
+//  2. a YAML (.yaml, .yml) or TOML (.toml) file named by -config or CONFIG_FILE
+//  3. environment variables named by `env` tags
+//  4. command-line flags named by `flag` tags
+//
+// Sections and keys in the file follow the `yaml`/`toml` tags; a field tagged
+// yaml:"-" is not configurable. Fields tagged required:"true" must be non-zero
+// once everything is loaded. Values of type Secret are masked whenever they
+// are printed, so a configuration can be logged safely with Describe.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Secret is a string that is never printed in clear text
+type Secret string
+
+const secretMask = "******"
+
+// Value returns the secret itself
+func (s Secret) Value() string {
+	return string(s)
+}
+
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+	return secretMask
+}
+
+func (s Secret) GoString() string {
+	return strconv.Quote(s.String())
+}
+
+// MarshalText masks the secret in JSON, YAML and TOML output
+func (s Secret) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// field is a configurable leaf of the configuration struct
+type field struct {
+	path     string
+	value    reflect.Value
+	env      string
+	flag     string
+	usage    string
+	required bool
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// Load fills cfg, a pointer to a struct holding the defaults, from the config
+// file, the environment and args. It returns the arguments left after the flags.
+func Load(cfg interface{}, args []string) ([]string, error) {
+	root := reflect.ValueOf(cfg)
+	if root.Kind() != reflect.Ptr || root.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("config: Load needs a pointer to a struct, got %T", cfg)
+	}
+	fields := collect(root.Elem(), "")
+
+	// Flags are parsed first to find -config, but applied last
+	fs := flag.NewFlagSet(filepath.Base(os.Args[0]), flag.ContinueOnError)
+	configFile := fs.String("config", os.Getenv("CONFIG_FILE"), "path to a YAML or TOML configuration file")
+	flagValues := map[string]string{}
+	for _, f := range fields {
+		if f.flag == "" {
+			continue
+		}
+		name := f.flag
+		fs.Func(name, f.usage, func(raw string) error {
+			flagValues[name] = raw
+			return nil
+		})
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if *configFile != "" {
+		if err := loadFile(*configFile, cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, f := range fields {
+		if f.env == "" {
+			continue
+		}
+		if raw, ok := os.LookupEnv(f.env); ok {
+			if err := set(f.value, raw); err != nil {
+				return nil, fmt.Errorf("config: %s: %w", f.env, err)
+			}
+		}
+	}
+
+	for _, f := range fields {
+		if raw, ok := flagValues[f.flag]; ok && f.flag != "" {
+			if err := set(f.value, raw); err != nil {
+				return nil, fmt.Errorf("config: -%s: %w", f.flag, err)
+			}
+		}
+	}
+
+	var missing []string
+	for _, f := range fields {
+		if f.required && f.value.IsZero() {
+			name := f.path
+			if f.env != "" {
+				name += " (" + f.env + ")"
+			}
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("config: missing required values: %s", strings.Join(missing, ", "))
+	}
+
+	return fs.Args(), nil
+}
+
+// Describe lists every configurable value as "path=value" with secrets masked
+func Describe(cfg interface{}) string {
+	root := reflect.ValueOf(cfg)
+	if root.Kind() == reflect.Ptr {
+		root = root.Elem()
+	}
+
+	fields := collect(root, "")
+	parts := make([]string, len(fields))
+	for i, f := range fields {
+		parts[i] = fmt.Sprintf("%s=%v", f.path, f.value.Interface())
+	}
+	return strings.Join(parts, " ")
+}
+
+// collect walks the struct and returns its configurable leaves
+func collect(v reflect.Value, prefix string) []field {
+	var fields []field
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(sf.Tag.Get("yaml"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(sf.Name)
+		}
+
+		fv := v.Field(i)
+		if fv.Kind() == reflect.Ptr && fv.Type().Elem().Kind() == reflect.Struct {
+			if fv.IsNil() {
+				continue
+			}
+			fv = fv.Elem()
+		}
+		if fv.Kind() == reflect.Struct {
+			fields = append(fields, collect(fv, prefix+name+".")...)
+			continue
+		}
+
+		fields = append(fields, field{
+			path:     prefix + name,
+			value:    fv,
+			env:      sf.Tag.Get("env"),
+			flag:     sf.Tag.Get("flag"),
+			usage:    sf.Tag.Get("usage"),
+			required: sf.Tag.Get("required") == "true",
+		})
+	}
+	return fields
+}
+
+// set parses raw into v according to its type
+func set(v reflect.Value, raw string) error {
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(raw, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(raw, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported type %s", v.Type())
+		}
+		// Comma-separated list
+		items := reflect.MakeSlice(v.Type(), 0, 0)
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = reflect.Append(items, reflect.ValueOf(item).Convert(v.Type().Elem()))
+			}
+		}
+		v.Set(items)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// loadFile decodes a YAML or TOML file over cfg; keys missing from the file keep their defaults
+func loadFile(path string, cfg interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err = dec.Decode(cfg); errors.Is(err, io.EOF) {
+			err = nil // empty file
+		}
+	case ".toml":
+		var meta toml.MetaData
+		meta, err = toml.Decode(string(data), cfg)
+		if err == nil && len(meta.Undecoded()) > 0 {
+			err = fmt.Errorf("unknown keys %v", meta.Undecoded())
+		}
+	default:
+		return fmt.Errorf("config: %s: unsupported file format, use .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return fmt.Errorf("config: %s: %w", path, err)
+	}
+	return nil
+}
+
+// IsHelp reports whether err means the user asked for -help
+func IsHelp(err error) bool {
+	return errors.Is(err, flag.ErrHelp)
+}
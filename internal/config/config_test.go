@@ -0,0 +1,194 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testServer struct {
+	Addr    string        `yaml:"addr" toml:"addr" env:"TESTCFG_ADDR" flag:"addr" required:"true"`
+	Timeout time.Duration `yaml:"timeout" toml:"timeout" env:"TESTCFG_TIMEOUT" flag:"timeout"`
+}
+
+type testDB struct {
+	Hosts    []string `yaml:"hosts" toml:"hosts" env:"TESTCFG_HOSTS" flag:"hosts"`
+	Port     int      `yaml:"port" toml:"port" env:"TESTCFG_PORT" flag:"port"`
+	Password Secret   `yaml:"password" toml:"password" env:"TESTCFG_PASSWORD" flag:"password"`
+}
+
+type testConfig struct {
+	Server   testServer `yaml:"server" toml:"server"`
+	DB       *testDB    `yaml:"db" toml:"db"`
+	Debug    bool       `yaml:"debug" toml:"debug" env:"TESTCFG_DEBUG" flag:"debug"`
+	Internal string     `yaml:"-" toml:"-" env:"TESTCFG_INTERNAL"`
+}
+
+func defaultTestConfig() *testConfig {
+	return &testConfig{
+		Server: testServer{Addr: ":8080", Timeout: 5 * time.Second},
+		DB:     &testDB{Hosts: []string{"localhost"}, Port: 5432},
+	}
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadLayers(t *testing.T) {
+	yamlFile := "server:\n  addr: \":9000\"\n  timeout: 10s\ndb:\n  port: 6432\n"
+
+	tests := []struct {
+		name string
+		file string // name of the config file holding body
+		body string
+		env  map[string]string
+		args []string
+		want testConfig
+	}{
+		{
+			name: "defaults",
+			want: testConfig{
+				Server: testServer{Addr: ":8080", Timeout: 5 * time.Second},
+				DB:     &testDB{Hosts: []string{"localhost"}, Port: 5432},
+			},
+		},
+		{
+			name: "yaml file over defaults",
+			file: "config.yaml",
+			body: yamlFile,
+			want: testConfig{
+				Server: testServer{Addr: ":9000", Timeout: 10 * time.Second},
+				DB:     &testDB{Hosts: []string{"localhost"}, Port: 6432},
+			},
+		},
+		{
+			name: "environment over file",
+			file: "config.yml",
+			body: yamlFile,
+			env:  map[string]string{"TESTCFG_PORT": "7000", "TESTCFG_HOSTS": "a, b,,c", "TESTCFG_DEBUG": "true"},
+			want: testConfig{
+				Server: testServer{Addr: ":9000", Timeout: 10 * time.Second},
+				DB:     &testDB{Hosts: []string{"a", "b", "c"}, Port: 7000},
+				Debug:  true,
+			},
+		},
+		{
+			name: "flags over environment",
+			file: "config.yaml",
+			body: yamlFile,
+			env:  map[string]string{"TESTCFG_PORT": "7000", "TESTCFG_TIMEOUT": "1m"},
+			args: []string{"-port", "7100", "-addr", ":9100"},
+			want: testConfig{
+				Server: testServer{Addr: ":9100", Timeout: time.Minute},
+				DB:     &testDB{Hosts: []string{"localhost"}, Port: 7100},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CONFIG_FILE", "")
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			args := tt.args
+			if tt.file != "" {
+				args = append([]string{"-config", writeFile(t, tt.file, tt.body)}, args...)
+			}
+
+			cfg := defaultTestConfig()
+			if _, err := Load(cfg, args); err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if !reflect.DeepEqual(*cfg, tt.want) {
+				t.Fatalf("Load() = %+v %+v, want %+v %+v", cfg.Server, *cfg.DB, tt.want.Server, *tt.want.DB)
+			}
+		})
+	}
+}
+
+func TestLoadReturnsRemainingArgs(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	rest, err := Load(defaultTestConfig(), []string{"-port", "1", "migrate", "down", "2"})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if want := []string{"migrate", "down", "2"}; !reflect.DeepEqual(rest, want) {
+		t.Fatalf("Load() args = %v, want %v", rest, want)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		body    string
+		env     map[string]string
+		args    []string
+		wantErr string
+	}{
+		{name: "unknown yaml key", file: "c.yaml", body: "server:\n  port: 1\n", wantErr: "port"},
+		{name: "unsupported format", file: "c.json", body: "{}", wantErr: "unsupported file format"},
+		{name: "bad environment value", env: map[string]string{"TESTCFG_PORT": "x"}, wantErr: "TESTCFG_PORT"},
+		{name: "required value cleared", env: map[string]string{"TESTCFG_ADDR": ""}, wantErr: "server.addr (TESTCFG_ADDR)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CONFIG_FILE", "")
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			args := tt.args
+			if tt.file != "" {
+				args = append([]string{"-config", writeFile(t, tt.file, tt.body)}, args...)
+			}
+
+			_, err := Load(defaultTestConfig(), args)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Load() error = %v, want one mentioning %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDescribeMasksSecrets(t *testing.T) {
+	cfg := defaultTestConfig()
+	cfg.DB.Password = "hunter2"
+	got := Describe(cfg)
+	if strings.Contains(got, "hunter2") {
+		t.Fatalf("Describe() = %q, leaks the secret", got)
+	}
+	for _, want := range []string{"server.addr=:8080", "db.port=5432", "db.password="} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Describe() = %q, want it to contain %q", got, want)
+		}
+	}
+	if strings.Contains(got, "internal") {
+		t.Errorf("Describe() = %q, lists a field hidden from the file", got)
+	}
+}
+
+func TestLoadPublisherDrainPeriod(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	tests := []struct {
+		args    []string
+		wantErr bool
+	}{
+		{[]string{"-drain-period", "5s", "-shutdown-timeout", "15s"}, false},
+		{[]string{"-drain-period", "15s", "-shutdown-timeout", "15s"}, true},
+	}
+	for _, tt := range tests {
+		_, _, err := LoadPublisher(tt.args)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("LoadPublisher(%v) error = %v, want error %v", tt.args, err, tt.wantErr)
+		}
+	}
+}
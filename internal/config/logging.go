@@ -0,0 +1,24 @@
+package config
+
+import "RESTAPI/internal/logging"
+
+// Logging holds the slog settings shared by both services
+type Logging struct {
+	Level          string `yaml:"level" toml:"level" env:"LOG_LEVEL" flag:"log-level" usage:"minimum log level: debug, info, warn or error"`
+	Format         string `yaml:"format" toml:"format" env:"LOG_FORMAT" flag:"log-format" usage:"log output format: json or text"`
+	MessageContent bool   `yaml:"message_content" toml:"message_content" env:"LOG_MESSAGE_CONTENT" flag:"log-message-content" usage:"write message content to logs instead of redacting it"`
+}
+
+// DefaultLogging writes JSON at info level with message content redacted
+func DefaultLogging() Logging {
+	return Logging{Level: "info", Format: logging.FormatJSON}
+}
+
+// Options converts the settings into logging.Options
+func (l Logging) Options() logging.Options {
+	return logging.Options{
+		Level:      l.Level,
+		Format:     l.Format,
+		LogContent: l.MessageContent,
+	}
+}
@@ -0,0 +1,189 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"RESTAPI/internal/auth"
+	"RESTAPI/internal/outbox"
+	"RESTAPI/internal/password"
+)
+
+// Publisher is the configuration of the publisher service (cmd/main.go)
+type Publisher struct {
+	HTTP       HTTP       `yaml:"http" toml:"http"`
+	DB         Postgres   `yaml:"db" toml:"db"`
+	JWT        JWT        `yaml:"jwt" toml:"jwt"`
+	Password   Password   `yaml:"password" toml:"password"`
+	Kafka      Kafka      `yaml:"kafka" toml:"kafka"`
+	Discussion Discussion `yaml:"discussion" toml:"discussion"`
+	Admin      Admin      `yaml:"admin" toml:"admin"`
+	Outbox     Outbox     `yaml:"outbox" toml:"outbox"`
+	Tracing    Tracing    `yaml:"tracing" toml:"tracing"`
+	Logging    Logging    `yaml:"logging" toml:"logging"`
+}
+
+// HTTP holds the HTTP server settings
+type HTTP struct {
+	Addr            string        `yaml:"addr" toml:"addr" env:"HTTP_ADDR" flag:"http-addr" usage:"HTTP listen address" required:"true"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT" flag:"shutdown-timeout" usage:"time allowed for a graceful shutdown" required:"true"`
+	DrainPeriod     time.Duration `yaml:"drain_period" toml:"drain_period" env:"DRAIN_PERIOD" flag:"drain-period" usage:"how long /readyz reports draining before the server stops; part of the shutdown timeout"`
+}
+
+// Validate checks that the drain leaves time for the rest of the shutdown,
+// which shares ShutdownTimeout with it
+func (h HTTP) Validate() error {
+	return ValidateDrain(h.DrainPeriod, h.ShutdownTimeout)
+}
+
+// ValidateDrain checks that a drain period is shorter than the shutdown
+// timeout it is part of
+func ValidateDrain(drain, shutdown time.Duration) error {
+	if drain >= shutdown {
+		return fmt.Errorf("config: drain period %s (DRAIN_PERIOD) must be shorter than the shutdown timeout %s (SHUTDOWN_TIMEOUT)", drain, shutdown)
+	}
+	return nil
+}
+
+// Postgres holds the connection settings of the publisher database
+type Postgres struct {
+	Host     string `yaml:"host" toml:"host" env:"DB_HOST" flag:"db-host" usage:"PostgreSQL host" required:"true"`
+	Port     int    `yaml:"port" toml:"port" env:"DB_PORT" flag:"db-port" usage:"PostgreSQL port" required:"true"`
+	User     string `yaml:"user" toml:"user" env:"DB_USER" flag:"db-user" usage:"PostgreSQL user" required:"true"`
+	Password Secret `yaml:"password" toml:"password" env:"DB_PASSWORD" flag:"db-password" usage:"PostgreSQL password"`
+	Name     string `yaml:"name" toml:"name" env:"DB_NAME" flag:"db-name" usage:"PostgreSQL database" required:"true"`
+	SSLMode  string `yaml:"sslmode" toml:"sslmode" env:"DB_SSLMODE" flag:"db-sslmode" usage:"PostgreSQL sslmode"`
+
+	// MigrateTimeout bounds the migrations applied at startup, not "migrate up"
+	MigrateTimeout time.Duration `yaml:"migrate_timeout" toml:"migrate_timeout" env:"DB_MIGRATE_TIMEOUT" flag:"db-migrate-timeout" usage:"time allowed for the migrations applied at startup; 0 means no limit"`
+}
+
+// DSN returns the connection string for the postgres driver
+func (p Postgres) DSN() string {
+	return fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%d sslmode=%s",
+		dsnQuote(p.Host), dsnQuote(p.User), dsnQuote(p.Password.Value()), dsnQuote(p.Name), p.Port, dsnQuote(p.SSLMode))
+}
+
+// dsnQuote quotes a keyword/value connection string value
+func dsnQuote(v string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(v) + "'"
+}
+
+// Kafka holds the brokers messages are sent to for moderation
+type Kafka struct {
+	Brokers []string `yaml:"brokers" toml:"brokers" env:"KAFKA_BROKERS" flag:"kafka-brokers" usage:"Kafka brokers separated by commas" required:"true"`
+}
+
+// Outbox tunes the relay that publishes news, writer and mark events
+type Outbox struct {
+	Interval  time.Duration `yaml:"interval" toml:"interval" env:"OUTBOX_INTERVAL" flag:"outbox-interval" usage:"how often the outbox is polled" required:"true"`
+	BatchSize int           `yaml:"batch_size" toml:"batch_size" env:"OUTBOX_BATCH_SIZE" flag:"outbox-batch-size" usage:"events published per transaction" required:"true"`
+	Retention time.Duration `yaml:"retention" toml:"retention" env:"OUTBOX_RETENTION" flag:"outbox-retention" usage:"how long sent events are kept; 0 keeps them forever"`
+}
+
+// Options converts the settings into outbox.Options
+func (o Outbox) Options() outbox.Options {
+	return outbox.Options{
+		Interval:  o.Interval,
+		BatchSize: o.BatchSize,
+		Retention: o.Retention,
+	}
+}
+
+// Discussion points at the discussion service that stores and moderates messages
+type Discussion struct {
+	URL     string        `yaml:"url" toml:"url" env:"DISCUSSION_URL" flag:"discussion-url" usage:"base URL of the discussion service" required:"true"`
+	Timeout time.Duration `yaml:"timeout" toml:"timeout" env:"DISCUSSION_TIMEOUT" flag:"discussion-timeout" usage:"timeout of calls to the discussion service" required:"true"`
+	// ModerationTimeout bounds the wait for a verdict in POST /messages?wait=true
+	ModerationTimeout time.Duration `yaml:"moderation_timeout" toml:"moderation_timeout" env:"DISCUSSION_MODERATION_TIMEOUT" flag:"discussion-moderation-timeout" usage:"how long to wait for a moderation verdict" required:"true"`
+	// Token lets the discussion service read news to check that they exist
+	Token Secret `yaml:"token" toml:"token" env:"DISCUSSION_TOKEN" flag:"discussion-token" usage:"bearer token the discussion service reads news with; news need a writer token when empty"`
+}
+
+// Admin is the first ADMIN writer, created at startup unless it exists
+type Admin struct {
+	Login    string `yaml:"login" toml:"login" env:"ADMIN_LOGIN" flag:"admin-login" usage:"login of the ADMIN writer created at startup; none when empty"`
+	Password Secret `yaml:"password" toml:"password" env:"ADMIN_PASSWORD" flag:"admin-password" usage:"password of the ADMIN writer created at startup"`
+}
+
+// JWT holds the token signing settings, see auth.NewConfig
+type JWT struct {
+	Keys        Secret        `yaml:"keys" toml:"keys" env:"JWT_KEYS" flag:"jwt-keys" usage:"JWT signing keys as kid:secret pairs separated by commas"`
+	ActiveKeyID string        `yaml:"active_kid" toml:"active_kid" env:"JWT_ACTIVE_KID" flag:"jwt-active-kid" usage:"key ID new tokens are signed with"`
+	AccessTTL   time.Duration `yaml:"access_ttl" toml:"access_ttl" env:"JWT_ACCESS_TTL" flag:"jwt-access-ttl" usage:"access token lifetime"`
+	RefreshTTL  time.Duration `yaml:"refresh_ttl" toml:"refresh_ttl" env:"JWT_REFRESH_TTL" flag:"jwt-refresh-ttl" usage:"refresh token lifetime"`
+}
+
+// TokenConfig converts the settings into an auth.Config
+func (j JWT) TokenConfig() (auth.Config, error) {
+	return auth.NewConfig(j.Keys.Value(), j.ActiveKeyID, j.AccessTTL, j.RefreshTTL)
+}
+
+// Password holds the password hashing settings, see password.Config
+type Password struct {
+	Algorithm         string `yaml:"algorithm" toml:"algorithm" env:"PASSWORD_ALGORITHM" flag:"password-algorithm" usage:"hash algorithm for new passwords: argon2id or bcrypt" required:"true"`
+	BcryptCost        int    `yaml:"bcrypt_cost" toml:"bcrypt_cost" env:"PASSWORD_BCRYPT_COST" flag:"password-bcrypt-cost" usage:"bcrypt cost"`
+	Argon2Memory      uint32 `yaml:"argon2_memory" toml:"argon2_memory" env:"PASSWORD_ARGON2_MEMORY" flag:"password-argon2-memory" usage:"argon2id memory in KiB"`
+	Argon2Iterations  uint32 `yaml:"argon2_iterations" toml:"argon2_iterations" env:"PASSWORD_ARGON2_ITERATIONS" flag:"password-argon2-iterations" usage:"argon2id iterations"`
+	Argon2Parallelism uint8  `yaml:"argon2_parallelism" toml:"argon2_parallelism" env:"PASSWORD_ARGON2_PARALLELISM" flag:"password-argon2-parallelism" usage:"argon2id parallelism"`
+}
+
+// HasherConfig converts the settings into a password.Config
+func (p Password) HasherConfig() password.Config {
+	cfg := password.DefaultConfig()
+	cfg.Algorithm = p.Algorithm
+	cfg.BcryptCost = p.BcryptCost
+	cfg.Argon2.Memory = p.Argon2Memory
+	cfg.Argon2.Iterations = p.Argon2Iterations
+	cfg.Argon2.Parallelism = p.Argon2Parallelism
+	return cfg
+}
+
+// DefaultPublisher returns the settings used when nothing is overridden
+func DefaultPublisher() Publisher {
+	hashing := password.DefaultConfig()
+	return Publisher{
+		HTTP: HTTP{Addr: ":24110", ShutdownTimeout: 15 * time.Second, DrainPeriod: 5 * time.Second},
+		DB: Postgres{
+			Host:     "localhost",
+			Port:     5432,
+			User:     "postgres",
+			Password: "postgres",
+			Name:     "distcomp",
+			SSLMode:  "disable",
+
+			MigrateTimeout: time.Minute,
+		},
+		JWT: JWT{
+			AccessTTL:  15 * time.Minute,
+			RefreshTTL: 7 * 24 * time.Hour,
+		},
+		Password: Password{
+			Algorithm:         hashing.Algorithm,
+			BcryptCost:        hashing.BcryptCost,
+			Argon2Memory:      hashing.Argon2.Memory,
+			Argon2Iterations:  hashing.Argon2.Iterations,
+			Argon2Parallelism: hashing.Argon2.Parallelism,
+		},
+		Kafka:      Kafka{Brokers: []string{"localhost:9092"}},
+		Discussion: Discussion{URL: "http://localhost:24130", Timeout: 5 * time.Second, ModerationTimeout: 5 * time.Second},
+		Outbox:     Outbox{Interval: time.Second, BatchSize: 100, Retention: 7 * 24 * time.Hour},
+		Tracing:    DefaultTracing(),
+		Logging:    DefaultLogging(),
+	}
+}
+
+// LoadPublisher loads the publisher configuration over DefaultPublisher.
+// It returns the arguments left after the flags, e.g. a "migrate" subcommand.
+func LoadPublisher(args []string) (*Publisher, []string, error) {
+	cfg := DefaultPublisher()
+	rest, err := Load(&cfg, args)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := cfg.HTTP.Validate(); err != nil {
+		return nil, nil, err
+	}
+	return &cfg, rest, nil
+}
@@ -0,0 +1,25 @@
+package config
+
+import "RESTAPI/internal/tracing"
+
+// Tracing holds the OpenTelemetry export settings shared by both services
+type Tracing struct {
+	Exporter    string  `yaml:"exporter" toml:"exporter" env:"OTEL_TRACES_EXPORTER" flag:"trace-exporter" usage:"span exporter: otlp, stdout or none"`
+	Endpoint    string  `yaml:"endpoint" toml:"endpoint" env:"OTEL_EXPORTER_OTLP_TRACES_ENDPOINT" flag:"trace-endpoint" usage:"OTLP/HTTP traces URL, e.g. http://localhost:4318/v1/traces"`
+	SampleRatio float64 `yaml:"sample_ratio" toml:"sample_ratio" env:"OTEL_TRACES_SAMPLER_ARG" flag:"trace-sample-ratio" usage:"share of new traces to record, 0..1"`
+}
+
+// DefaultTracing disables export; trace context is still propagated
+func DefaultTracing() Tracing {
+	return Tracing{Exporter: tracing.ExporterNone, SampleRatio: 1}
+}
+
+// Options converts the settings into tracing.Options for the named service
+func (t Tracing) Options(serviceName string) tracing.Options {
+	return tracing.Options{
+		ServiceName: serviceName,
+		Exporter:    t.Exporter,
+		Endpoint:    t.Endpoint,
+		SampleRatio: t.SampleRatio,
+	}
+}
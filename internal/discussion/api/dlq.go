@@ -0,0 +1,106 @@
+package api
+
+import (
+	"RESTAPI/internal/discussion/kafka"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gorilla/mux"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// DLQHandler serves the admin API of the dead-letter topic
+type DLQHandler struct {
+	deadLetters *kafka.DeadLetters
+	token       string
+}
+
+// NewDLQHandler creates a DLQHandler; token is required as a bearer token
+// on every request. Without a token the API is disabled and answers 503.
+func NewDLQHandler(deadLetters *kafka.DeadLetters, token string) *DLQHandler {
+	return &DLQHandler{deadLetters: deadLetters, token: token}
+}
+
+// RegisterRoutes registers the admin routes
+func (h *DLQHandler) RegisterRoutes(r *mux.Router) {
+	admin := r.PathPrefix("/api/v1.0/admin").Subrouter()
+	admin.Use(h.authorize)
+	admin.HandleFunc("/dlq", h.ListDeadLetters).Methods(http.MethodGet)
+	admin.HandleFunc("/dlq/{partition:[0-9]+}/{offset:[0-9]+}/replay", h.ReplayDeadLetter).Methods(http.MethodPost)
+}
+
+// authorize checks the admin token; it fails closed when none is configured
+func (h *DLQHandler) authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.token == "" {
+			http.Error(w, "admin API is disabled", http.StatusServiceUnavailable)
+			return
+		}
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(h.token)) != 1 {
+			http.Error(w, "admin token required", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ListDeadLetters handles listing the most recent dead letters: ?limit=
+func (h *DLQHandler) ListDeadLetters(w http.ResponseWriter, r *http.Request) {
+	limit := defaultPageLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxPageLimit {
+			http.Error(w, fmt.Sprintf("limit must be between 1 and %d", maxPageLimit), http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	letters, err := h.deadLetters.List(r.Context(), limit)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to list dead letters", slog.Any("error", err))
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(letters)
+}
+
+// ReplayDeadLetter handles sending a dead letter back to its original topic
+func (h *DLQHandler) ReplayDeadLetter(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	partition, err := strconv.ParseInt(vars["partition"], 10, 32)
+	if err != nil {
+		http.Error(w, "invalid partition", http.StatusBadRequest)
+		return
+	}
+	offset, err := strconv.ParseInt(vars["offset"], 10, 64)
+	if err != nil {
+		http.Error(w, "invalid offset", http.StatusBadRequest)
+		return
+	}
+
+	letter, err := h.deadLetters.Replay(r.Context(), int32(partition), offset)
+	if err != nil {
+		if errors.Is(err, kafka.ErrDeadLetterNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		slog.ErrorContext(r.Context(), "failed to replay dead letter",
+			slog.Int64("partition", partition),
+			slog.Int64("offset", offset),
+			slog.Any("error", err))
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(letter)
+}
@@ -2,13 +2,22 @@ package api
 
 import (
 	"RESTAPI/internal/discussion/model"
+	"RESTAPI/internal/discussion/repository"
 	"RESTAPI/internal/discussion/service"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/gorilla/mux"
-	"log"
+	"log/slog"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
+)
+
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
 )
 
 // Handler handles HTTP requests for messages
@@ -32,8 +41,17 @@ func (h *Handler) RegisterRoutes(r *mux.Router) {
 	api.HandleFunc("/messages/{id:[0-9]+}", h.DeleteMessage).Methods(http.MethodDelete)
 }
 
-// GetAllMessages handles retrieving all messages
+// GetAllMessages handles retrieving all messages, or a page of them when
+// the query has paging, filter or sort parameters
 func (h *Handler) GetAllMessages(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	for _, name := range listParams {
+		if query.Has(name) {
+			h.listMessages(w, r)
+			return
+		}
+	}
+
 	messages, err := h.service.GetAllMessages(r.Context())
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -48,27 +66,120 @@ func (h *Handler) GetAllMessages(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(messages)
 }
 
+// listParams are the query parameters of listMessages
+var listParams = []string{"page", "pageSize", "sort", "newsId", "id"}
+
+// listMessages serves offset pages: ?page=&pageSize=&newsId=&id=&sort=.
+// sort accepts id and newsId, "-" for descending, and needs a newsId filter;
+// X-Total-Count is set unless the total would need a scan of every node.
+func (h *Handler) listMessages(w http.ResponseWriter, r *http.Request) {
+	q, err := parseListQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	messages, total, err := h.service.ListMessages(r.Context(), q)
+	if errors.Is(err, service.ErrPageTooDeep) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to list messages", slog.Any("error", err))
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if total >= 0 {
+		w.Header().Set("X-Total-Count", strconv.FormatInt(total, 10))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(messages)
+}
+
+// parseListQuery reads the parameters of listMessages. Without page and
+// pageSize every match is returned.
+func parseListQuery(query url.Values) (service.ListQuery, error) {
+	q := service.ListQuery{Page: 1}
+	if query.Has("page") || query.Has("pageSize") {
+		q.PageSize = defaultPageLimit
+	}
+	if v := query.Get("page"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return q, errors.New("page must be a positive integer")
+		}
+		q.Page = n
+	}
+	if v := query.Get("pageSize"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxPageLimit {
+			return q, fmt.Errorf("pageSize must be between 1 and %d", maxPageLimit)
+		}
+		q.PageSize = n
+	}
+	for name, dest := range map[string]*int64{"newsId": &q.NewsID, "id": &q.ID} {
+		if v := query.Get(name); v != "" {
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil || n < 1 {
+				return q, fmt.Errorf("%s must be a positive integer", name)
+			}
+			*dest = n
+		}
+	}
+
+	// With a newsId filter every message has the same news ID, so the
+	// first sort key on id decides the order
+	for _, key := range strings.Split(query.Get("sort"), ",") {
+		if key == "" {
+			continue
+		}
+		field := strings.TrimPrefix(key, "-")
+		if field != "id" && field != "newsId" {
+			return q, fmt.Errorf("unknown sort field %q", field)
+		}
+		if q.NewsID == 0 {
+			return q, errors.New("messages can only be sorted with a newsId filter")
+		}
+		if field == "id" {
+			q.Desc = strings.HasPrefix(key, "-")
+			break
+		}
+	}
+	return q, nil
+}
+
+// newsCheckStatus maps a failed check of the message's news to a status:
+// 400 for a news that does not exist, 503 when the publisher cannot tell
+func newsCheckStatus(err error) (int, bool) {
+	switch {
+	case errors.Is(err, service.ErrNewsNotFound):
+		return http.StatusBadRequest, true
+	case errors.Is(err, service.ErrPublisherUnavailable):
+		return http.StatusServiceUnavailable, true
+	}
+	return 0, false
+}
+
 // CreateMessage handles message creation
 func (h *Handler) CreateMessage(w http.ResponseWriter, r *http.Request) {
 	var message model.Message
 	if err := json.NewDecoder(r.Body).Decode(&message); err != nil {
-		log.Printf("Error decoding request: %v", err)
+		slog.DebugContext(r.Context(), "invalid request body", slog.Any("error", err))
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	// Log request
-	log.Printf("Received create message request: %+v", message)
-
 	if err := h.service.CreateMessage(r.Context(), &message); err != nil {
-		log.Printf("Error creating message: %v", err)
+		if status, ok := newsCheckStatus(err); ok {
+			http.Error(w, err.Error(), status)
+			return
+		}
+		slog.ErrorContext(r.Context(), "failed to create message", slog.Any("error", err))
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	// Log response
-	log.Printf("Successfully created message: %+v", message)
-
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(message)
 }
@@ -78,7 +189,7 @@ func (h *Handler) GetMessage(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.ParseInt(vars["id"], 10, 64)
 	if err != nil {
-		log.Printf("Error parsing ID: %v", err)
+		slog.DebugContext(r.Context(), "invalid message ID", slog.Any("error", err))
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusBadRequest)
 		json.NewEncoder(w).Encode(map[string]string{"error": "invalid ID format"})
@@ -87,15 +198,20 @@ func (h *Handler) GetMessage(w http.ResponseWriter, r *http.Request) {
 
 	message, err := h.service.GetMessage(r.Context(), id)
 	if err != nil {
-		log.Printf("Error getting message: %v", err)
+		status := http.StatusInternalServerError
+		if errors.Is(err, repository.ErrNotFound) {
+			status = http.StatusNotFound
+		} else {
+			slog.ErrorContext(r.Context(), "failed to get message", slog.Int64("message_id", id), slog.Any("error", err))
+		}
 		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusNotFound)
+		w.WriteHeader(status)
 		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
 		return
 	}
 
 	if message == nil {
-		log.Printf("Message with ID %d not found", id)
+		slog.DebugContext(r.Context(), "message not found", slog.Int64("message_id", id))
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusNotFound)
 		json.NewEncoder(w).Encode(map[string]string{"error": fmt.Sprintf("message with ID %d not found", id)})
@@ -112,14 +228,20 @@ func (h *Handler) GetMessagesByNewsID(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	newsID, err := strconv.ParseInt(vars["newsId"], 10, 64)
 	if err != nil {
-		log.Printf("Error parsing NewsID: %v", err)
+		slog.DebugContext(r.Context(), "invalid news ID", slog.Any("error", err))
 		http.Error(w, "invalid NewsID format", http.StatusBadRequest)
 		return
 	}
 
+	query := r.URL.Query()
+	if query.Has("after") || query.Has("limit") {
+		h.getMessagesPageByNewsID(w, r, newsID)
+		return
+	}
+
 	messages, err := h.service.GetMessagesByNewsID(r.Context(), newsID)
 	if err != nil {
-		log.Printf("Error getting messages: %v", err)
+		slog.ErrorContext(r.Context(), "failed to get messages", slog.Int64("news_id", newsID), slog.Any("error", err))
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -128,26 +250,73 @@ func (h *Handler) GetMessagesByNewsID(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(messages)
 }
 
+// getMessagesPageByNewsID serves keyset pages: ?after=<cursor>&limit=
+func (h *Handler) getMessagesPageByNewsID(w http.ResponseWriter, r *http.Request, newsID int64) {
+	query := r.URL.Query()
+	limit := defaultPageLimit
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxPageLimit {
+			http.Error(w, fmt.Sprintf("limit must be between 1 and %d", maxPageLimit), http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	messages, next, err := h.service.GetMessagesPageByNewsID(r.Context(), newsID, query.Get("after"), limit)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to get page of messages", slog.Int64("news_id", newsID), slog.Any("error", err))
+		if errors.Is(err, service.ErrInvalidCursor) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if next != "" {
+		nextURL := *r.URL
+		values := nextURL.Query()
+		values.Set("after", next)
+		values.Set("limit", strconv.Itoa(limit))
+		nextURL.RawQuery = values.Encode()
+
+		w.Header().Set("X-Next-Cursor", next)
+		w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, nextURL.RequestURI()))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(messages)
+}
+
 // UpdateMessage handles message updates
 func (h *Handler) UpdateMessage(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.ParseInt(vars["id"], 10, 64)
 	if err != nil {
-		log.Printf("Error parsing ID: %v", err)
+		slog.DebugContext(r.Context(), "invalid message ID", slog.Any("error", err))
 		http.Error(w, "invalid ID format", http.StatusBadRequest)
 		return
 	}
 
 	var message model.Message
 	if err := json.NewDecoder(r.Body).Decode(&message); err != nil {
-		log.Printf("Error decoding request: %v", err)
+		slog.DebugContext(r.Context(), "invalid request body", slog.Any("error", err))
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 	message.ID = id
 
 	if err := h.service.UpdateMessage(r.Context(), &message); err != nil {
-		log.Printf("Error updating message: %v", err)
+		if errors.Is(err, repository.ErrNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if status, ok := newsCheckStatus(err); ok {
+			http.Error(w, err.Error(), status)
+			return
+		}
+		slog.ErrorContext(r.Context(), "failed to update message", slog.Int64("message_id", id), slog.Any("error", err))
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -161,13 +330,17 @@ func (h *Handler) DeleteMessage(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.ParseInt(vars["id"], 10, 64)
 	if err != nil {
-		log.Printf("Error parsing ID: %v", err)
+		slog.DebugContext(r.Context(), "invalid message ID", slog.Any("error", err))
 		http.Error(w, "invalid ID format", http.StatusBadRequest)
 		return
 	}
 
 	if err := h.service.DeleteMessage(r.Context(), id); err != nil {
-		log.Printf("Error deleting message: %v", err)
+		if errors.Is(err, repository.ErrNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		slog.ErrorContext(r.Context(), "failed to delete message", slog.Int64("message_id", id), slog.Any("error", err))
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -0,0 +1,172 @@
+// Package client is used by other services to work with the discussion
+// service: an HTTP client for its message API and the Kafka side of the
+// moderation flow (requests to message-in, results from message-out).
+package client
+
+import (
+	"RESTAPI/internal/discussion/model"
+	"RESTAPI/internal/logging"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned when the discussion service does not know the message
+var ErrNotFound = errors.New("message not found in discussion service")
+
+// ErrUnavailable is returned when the discussion service cannot be reached or fails
+var ErrUnavailable = errors.New("discussion service unavailable")
+
+// Client calls the message API of the discussion service
+type Client struct {
+	baseURL string
+	http    *http.Client
+}
+
+// New creates a Client for the service at baseURL, e.g. "http://localhost:24130"
+func New(baseURL string, timeout time.Duration) *Client {
+	return &Client{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		http:    &http.Client{Timeout: timeout},
+	}
+}
+
+// Get returns a single message
+func (c *Client) Get(ctx context.Context, id int64) (*model.Message, error) {
+	var message model.Message
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/api/v1.0/messages/%d", id), nil, &message); err != nil {
+		return nil, err
+	}
+	return &message, nil
+}
+
+// List returns every message
+func (c *Client) List(ctx context.Context) ([]*model.Message, error) {
+	var messages []*model.Message
+	if err := c.do(ctx, http.MethodGet, "/api/v1.0/messages", nil, &messages); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// ListPage returns a page of messages and the number of all matches, or -1
+// when the discussion service does not count them. filter may hold id and
+// newsId; sort names id or newsId, "-" for descending, and needs a newsId
+// filter. A zero page or pageSize leaves it to the discussion service.
+func (c *Client) ListPage(ctx context.Context, page, pageSize int, filter map[string]string, sort []string) ([]*model.Message, int64, error) {
+	query := url.Values{}
+	if page > 0 {
+		query.Set("page", strconv.Itoa(page))
+	}
+	if pageSize > 0 {
+		query.Set("pageSize", strconv.Itoa(pageSize))
+	}
+	for name, value := range filter {
+		query.Set(name, value)
+	}
+	if len(sort) > 0 {
+		query.Set("sort", strings.Join(sort, ","))
+	}
+
+	var messages []*model.Message
+	header, err := c.send(ctx, http.MethodGet, "/api/v1.0/messages?"+query.Encode(), nil, &messages)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	total := int64(-1)
+	if v := header.Get("X-Total-Count"); v != "" {
+		if total, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return nil, 0, fmt.Errorf("invalid X-Total-Count %q", v)
+		}
+	}
+	return messages, total, nil
+}
+
+// ListByNews returns the messages of a news item
+func (c *Client) ListByNews(ctx context.Context, newsID int64) ([]*model.Message, error) {
+	var messages []*model.Message
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/api/v1.0/messages/news/%d", newsID), nil, &messages); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// Update replaces the news and content of a message; an empty state keeps the current one
+func (c *Client) Update(ctx context.Context, message *model.Message) (*model.Message, error) {
+	var updated model.Message
+	if err := c.do(ctx, http.MethodPut, fmt.Sprintf("/api/v1.0/messages/%d", message.ID), message, &updated); err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// Delete removes a message
+func (c *Client) Delete(ctx context.Context, id int64) error {
+	return c.do(ctx, http.MethodDelete, fmt.Sprintf("/api/v1.0/messages/%d", id), nil, nil)
+}
+
+// do sends a JSON request and decodes the JSON response into out, if set.
+// The request ID and trace context of ctx are passed on in the headers.
+func (c *Client) do(ctx context.Context, method, path string, in, out interface{}) error {
+	_, err := c.send(ctx, method, path, in, out)
+	return err
+}
+
+// send is do returning the response headers
+func (c *Client) send(ctx context.Context, method, path string, in, out interface{}) (http.Header, error) {
+	var body io.Reader
+	if in != nil {
+		raw, err := json.Marshal(in)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode request: %w", err)
+		}
+		body = bytes.NewReader(raw)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if id := logging.RequestID(ctx); id != "" {
+		req.Header.Set(logging.RequestIDHeader, id)
+	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, ErrNotFound
+	case resp.StatusCode >= http.StatusInternalServerError:
+		return nil, fmt.Errorf("%w: %s %s returned %d", ErrUnavailable, method, path, resp.StatusCode)
+	case resp.StatusCode >= http.StatusBadRequest:
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("%s %s returned %d: %s", method, path, resp.StatusCode, strings.TrimSpace(string(detail)))
+	}
+
+	if out == nil {
+		return resp.Header, nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return resp.Header, nil
+}
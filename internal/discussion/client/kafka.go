@@ -0,0 +1,269 @@
+package client
+
+import (
+	"RESTAPI/internal/discussion/config"
+	"RESTAPI/internal/discussion/model"
+	"RESTAPI/internal/logging"
+	"RESTAPI/internal/metrics"
+	"RESTAPI/internal/tracing"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/IBM/sarama"
+	"go.opentelemetry.io/otel/codes"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Publisher sends messages to message-in for moderation
+type Publisher struct {
+	producer sarama.SyncProducer
+}
+
+// NewPublisher creates a Publisher
+func NewPublisher(kafkaConfig *config.KafkaConfig) (*Publisher, error) {
+	producer, err := sarama.NewSyncProducer(kafkaConfig.Brokers, kafkaConfig.Config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create producer: %v", err)
+	}
+	return &Publisher{producer: producer}, nil
+}
+
+// Publish sends the message to message-in, keyed by news so that the
+// messages of one news item keep their order. The record gets a fresh
+// correlation ID; use Requester to wait for the reply.
+func (p *Publisher) Publish(ctx context.Context, message *model.Message) error {
+	return p.publish(ctx, message, logging.NewRequestID())
+}
+
+// publish sends the message as a request whose reply goes to message-out with the given correlation ID
+func (p *Publisher) publish(ctx context.Context, message *model.Message, correlationID string) error {
+	value, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %v", err)
+	}
+
+	msg := &sarama.ProducerMessage{
+		Topic: config.InTopic,
+		Key:   sarama.StringEncoder(strconv.FormatInt(message.NewsID, 10)),
+		Value: sarama.ByteEncoder(value),
+		Headers: []sarama.RecordHeader{
+			{Key: []byte(config.CorrelationIDHeader), Value: []byte(correlationID)},
+			{Key: []byte(config.ReplyToHeader), Value: []byte(config.OutTopic)},
+		},
+	}
+	if id := logging.RequestID(ctx); id != "" {
+		msg.Headers = append(msg.Headers, sarama.RecordHeader{Key: []byte(logging.RequestIDHeader), Value: []byte(id)})
+	}
+	ctx, span := tracing.StartProduce(ctx, msg)
+	defer span.End()
+
+	start := time.Now()
+	partition, offset, err := p.producer.SendMessage(msg)
+	metrics.ObserveProduce(msg.Topic, start, err)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("failed to send message: %v", err)
+	}
+
+	slog.DebugContext(ctx, "message published",
+		slog.Int64("message_id", message.ID),
+		slog.String("correlation_id", correlationID),
+		slog.Int("partition", int(partition)),
+		slog.Int64("offset", offset))
+	return nil
+}
+
+// Close closes the producer
+func (p *Publisher) Close() error {
+	return p.producer.Close()
+}
+
+// ResultHandler receives a moderated message from message-out
+type ResultHandler func(ctx context.Context, message *model.Message) error
+
+// Results consumes moderation results from message-out
+type Results struct {
+	consumer sarama.ConsumerGroup
+	handle   ResultHandler
+	retry    config.RetryConfig
+	stopCh   chan struct{}
+	stopOnce sync.Once
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+// NewResults creates a consumer of message-out in the given consumer group.
+// Instances of a service share a group, so each result is handled once.
+func NewResults(kafkaConfig *config.KafkaConfig, group string, handle ResultHandler) (*Results, error) {
+	consumer, err := sarama.NewConsumerGroup(kafkaConfig.Brokers, group, kafkaConfig.Config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create consumer group: %v", err)
+	}
+	return &Results{
+		consumer: consumer,
+		handle:   handle,
+		retry:    kafkaConfig.Retry,
+		stopCh:   make(chan struct{}),
+		done:     make(chan struct{}),
+	}, nil
+}
+
+// Start starts consuming results
+func (r *Results) Start() error {
+	topics := []string{config.OutTopic}
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+
+	go func() {
+		defer close(r.done)
+		for {
+			select {
+			case <-r.stopCh:
+				return
+			default:
+				if err := r.consumer.Consume(ctx, topics, r); err != nil {
+					slog.Error("results consumer session failed", slog.Any("error", err))
+				}
+			}
+		}
+	}()
+
+	return nil
+}
+
+// Stop finishes the current result, commits the offsets and leaves the
+// consumer group before it returns or ctx expires
+func (r *Results) Stop(ctx context.Context) error {
+	var err error
+	r.stopOnce.Do(func() {
+		close(r.stopCh)
+		if r.cancel != nil {
+			r.cancel()
+
+			select {
+			case <-r.done:
+			case <-ctx.Done():
+				slog.Warn("results consumer did not stop in time", slog.Any("error", ctx.Err()))
+			}
+		}
+
+		if err = r.consumer.Close(); err != nil {
+			slog.Error("failed to close results consumer", slog.Any("error", err))
+		}
+	})
+	return err
+}
+
+// Setup is run at the beginning of a new session
+func (r *Results) Setup(sarama.ConsumerGroupSession) error {
+	return nil
+}
+
+// Cleanup commits the offsets marked so far
+func (r *Results) Cleanup(session sarama.ConsumerGroupSession) error {
+	session.Commit()
+	return nil
+}
+
+// ConsumeClaim hands every result of a partition to the handler
+func (r *Results) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	for {
+		select {
+		case record, ok := <-claim.Messages():
+			if !ok {
+				return nil
+			}
+			if !r.process(session, record) {
+				// Later results must not be marked before this one
+				return nil
+			}
+		case <-r.stopCh:
+			return nil
+		case <-session.Context().Done():
+			return nil
+		}
+	}
+}
+
+// process handles one result inside a span that continues the producer's
+// trace. A result that cannot be decoded is logged and skipped, as it would
+// never succeed. Otherwise handling is retried with backoff until it
+// succeeds, so a verdict is never committed past; process returns false when
+// the consumer stops first, leaving the result to be consumed again.
+func (r *Results) process(session sarama.ConsumerGroupSession, record *sarama.ConsumerMessage) bool {
+	ctx, span := tracing.StartConsume(context.Background(), record)
+	defer span.End()
+	ctx = logging.WithRequestID(ctx, requestIDOf(record))
+
+	var message model.Message
+	if err := json.Unmarshal(record.Value, &message); err != nil {
+		metrics.ObserveConsumed(record.Topic, err)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		slog.ErrorContext(ctx, "skipping undecodable moderation result",
+			slog.Int("partition", int(record.Partition)),
+			slog.Int64("offset", record.Offset),
+			slog.Any("error", err))
+		session.MarkMessage(record, "")
+		return true
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := r.handle(ctx, &message)
+		metrics.ObserveConsumed(record.Topic, err)
+		if err == nil {
+			session.MarkMessage(record, "")
+			return true
+		}
+
+		delay := r.retry.Delay(attempt)
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "failed to handle moderation result, retrying",
+			slog.Int("partition", int(record.Partition)),
+			slog.Int64("offset", record.Offset),
+			slog.Int("attempt", attempt),
+			slog.Duration("delay", delay),
+			slog.Any("error", err))
+		metrics.ObserveRetry(record.Topic, "handle")
+		if !r.sleep(session, delay) {
+			span.SetStatus(codes.Error, err.Error())
+			return false
+		}
+	}
+}
+
+// sleep waits for d and reports false if the consumer stops or the session ends first
+func (r *Results) sleep(session sarama.ConsumerGroupSession, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-r.stopCh:
+		return false
+	case <-session.Context().Done():
+		return false
+	}
+}
+
+// requestIDOf continues the request ID of the producer or starts a new one
+func requestIDOf(record *sarama.ConsumerMessage) string {
+	if id := header(record, logging.RequestIDHeader); id != "" {
+		return id
+	}
+	return logging.NewRequestID()
+}
+
+// header returns the value of the first record header with the given key, or ""
+func header(record *sarama.ConsumerMessage, key string) string {
+	for _, h := range record.Headers {
+		if h != nil && string(h.Key) == key && len(h.Value) > 0 {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,138 @@
+package client
+
+import (
+	"RESTAPI/internal/discussion/config"
+	"RESTAPI/internal/discussion/model"
+	"RESTAPI/internal/logging"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/IBM/sarama"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// ErrNoReply is returned when the moderation result does not arrive in time
+var ErrNoReply = errors.New("no reply from discussion service")
+
+// Requester sends a message to message-in and waits for its moderation
+// result on message-out, matched by the correlation ID header.
+//
+// Results reads message-out as a consumer group, so a reply lands on one
+// instance of the service only. Requester instead reads every partition of
+// the reply topic from the newest offset without a group, so each instance
+// sees each reply and picks out those it waits for.
+type Requester struct {
+	publisher  *Publisher
+	consumer   sarama.Consumer
+	partitions []sarama.PartitionConsumer
+	timeout    time.Duration
+	wg         sync.WaitGroup
+
+	mu      sync.Mutex
+	pending map[string]chan *model.Message
+}
+
+// NewRequester starts listening for replies; requests time out after timeout.
+// Partitions added to message-out later are not picked up until restart.
+func NewRequester(kafkaConfig *config.KafkaConfig, publisher *Publisher, timeout time.Duration) (*Requester, error) {
+	consumer, err := sarama.NewConsumer(kafkaConfig.Brokers, kafkaConfig.Config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create consumer: %v", err)
+	}
+	partitions, err := consumer.Partitions(config.OutTopic)
+	if err != nil {
+		consumer.Close()
+		return nil, fmt.Errorf("failed to list partitions of %s: %v", config.OutTopic, err)
+	}
+
+	r := &Requester{
+		publisher: publisher,
+		consumer:  consumer,
+		timeout:   timeout,
+		pending:   make(map[string]chan *model.Message),
+	}
+	for _, partition := range partitions {
+		pc, err := consumer.ConsumePartition(config.OutTopic, partition, sarama.OffsetNewest)
+		if err != nil {
+			r.Close()
+			return nil, fmt.Errorf("failed to consume partition %d of %s: %v", partition, config.OutTopic, err)
+		}
+		r.partitions = append(r.partitions, pc)
+		r.wg.Add(1)
+		go r.listen(pc)
+	}
+	return r, nil
+}
+
+// Request publishes the message and returns its moderated version. It fails
+// with ErrNoReply if the reply does not arrive within the timeout or before ctx ends.
+func (r *Requester) Request(ctx context.Context, message *model.Message) (*model.Message, error) {
+	correlationID := logging.NewRequestID()
+	reply := make(chan *model.Message, 1)
+
+	// Registered before publishing so that an early reply is not missed
+	r.mu.Lock()
+	r.pending[correlationID] = reply
+	r.mu.Unlock()
+	defer func() {
+		r.mu.Lock()
+		delete(r.pending, correlationID)
+		r.mu.Unlock()
+	}()
+
+	if err := r.publisher.publish(ctx, message, correlationID); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	select {
+	case result := <-reply:
+		return result, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("%w: message %d: %v", ErrNoReply, message.ID, ctx.Err())
+	}
+}
+
+// listen hands the replies of one partition to the waiting requests
+func (r *Requester) listen(pc sarama.PartitionConsumer) {
+	defer r.wg.Done()
+	for record := range pc.Messages() {
+		correlationID := header(record, config.CorrelationIDHeader)
+		if correlationID == "" {
+			continue
+		}
+
+		r.mu.Lock()
+		reply, ok := r.pending[correlationID]
+		delete(r.pending, correlationID)
+		r.mu.Unlock()
+		if !ok {
+			// Another instance is waiting for it, or the request timed out
+			continue
+		}
+
+		var message model.Message
+		if err := json.Unmarshal(record.Value, &message); err != nil {
+			slog.Error("failed to unmarshal reply",
+				slog.String("correlation_id", correlationID),
+				slog.Int("partition", int(record.Partition)),
+				slog.Int64("offset", record.Offset),
+				slog.Any("error", err))
+			continue
+		}
+		reply <- &message
+	}
+}
+
+// Close stops listening for replies; requests still waiting run into their timeout
+func (r *Requester) Close() error {
+	for _, pc := range r.partitions {
+		pc.AsyncClose()
+	}
+	r.wg.Wait()
+	return r.consumer.Close()
+}
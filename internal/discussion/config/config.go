@@ -1,27 +1,181 @@
 package config
 
 import (
+	"fmt"
+	"strings"
 	"time"
+
+	loader "RESTAPI/internal/config"
+	"RESTAPI/internal/discussion/cache"
+	"RESTAPI/internal/discussion/moderation"
+	"RESTAPI/internal/discussion/repository"
+	"github.com/gocql/gocql"
+	"github.com/redis/go-redis/v9"
 )
 
 // Config holds all configuration for the service
 type Config struct {
-	Kafka  *KafkaConfig
-	DB     *DBConfig
-	Server *ServerConfig
+	Kafka      *KafkaConfig      `yaml:"kafka" toml:"kafka"`
+	DB         *DBConfig         `yaml:"cassandra" toml:"cassandra"`
+	Server     *ServerConfig     `yaml:"http" toml:"http"`
+	Publisher  *PublisherConfig  `yaml:"publisher" toml:"publisher"`
+	Admin      *AdminConfig      `yaml:"admin" toml:"admin"`
+	Moderation *ModerationConfig `yaml:"moderation" toml:"moderation"`
+	IDs        *IDConfig         `yaml:"ids" toml:"ids"`
+	Cache      *CacheConfig      `yaml:"cache" toml:"cache"`
+	Tracing    loader.Tracing    `yaml:"tracing" toml:"tracing"`
+	Logging    loader.Logging    `yaml:"logging" toml:"logging"`
 }
 
 // DBConfig holds database configuration
 type DBConfig struct {
-	Hosts       []string
-	Keyspace    string
-	Consistency string
-	Timeout     time.Duration
+	Hosts       []string      `yaml:"hosts" toml:"hosts" env:"CASSANDRA_HOSTS" flag:"cassandra-hosts" usage:"Cassandra hosts separated by commas" required:"true"`
+	Port        int           `yaml:"port" toml:"port" env:"CASSANDRA_PORT" flag:"cassandra-port" usage:"Cassandra native protocol port of all hosts"`
+	Keyspace    string        `yaml:"keyspace" toml:"keyspace" env:"CASSANDRA_KEYSPACE" flag:"cassandra-keyspace" usage:"Cassandra keyspace" required:"true"`
+	Consistency string        `yaml:"consistency" toml:"consistency" env:"CASSANDRA_CONSISTENCY" flag:"cassandra-consistency" usage:"default consistency level"`
+	Timeout     time.Duration `yaml:"timeout" toml:"timeout" env:"CASSANDRA_TIMEOUT" flag:"cassandra-timeout" usage:"query and connect timeout"`
+
+	ReadConsistency   string `yaml:"read_consistency" toml:"read_consistency" env:"CASSANDRA_READ_CONSISTENCY" flag:"cassandra-read-consistency" usage:"consistency level of reads; the default level when empty"`
+	WriteConsistency  string `yaml:"write_consistency" toml:"write_consistency" env:"CASSANDRA_WRITE_CONSISTENCY" flag:"cassandra-write-consistency" usage:"consistency level of writes; the default level when empty"`
+	SerialConsistency string `yaml:"serial_consistency" toml:"serial_consistency" env:"CASSANDRA_SERIAL_CONSISTENCY" flag:"cassandra-serial-consistency" usage:"serial consistency of lightweight transactions: serial or local_serial"`
+
+	// MigrateTimeout bounds the migrations applied at startup, not "migrate up"
+	MigrateTimeout time.Duration `yaml:"migrate_timeout" toml:"migrate_timeout" env:"CASSANDRA_MIGRATE_TIMEOUT" flag:"cassandra-migrate-timeout" usage:"time allowed for the migrations applied at startup; 0 means no limit"`
+}
+
+// Consistencies parses the consistency levels; reads and writes fall back to Consistency
+func (c *DBConfig) Consistencies() (repository.Consistency, error) {
+	var result repository.Consistency
+	levels := []struct {
+		name  string
+		value string
+		dest  *gocql.Consistency
+	}{
+		{"read", c.ReadConsistency, &result.Read},
+		{"write", c.WriteConsistency, &result.Write},
+	}
+	for _, level := range levels {
+		value := level.value
+		if value == "" {
+			value = c.Consistency
+		}
+		consistency, err := gocql.ParseConsistencyWrapper(value)
+		if err != nil {
+			return result, fmt.Errorf("%s consistency: %w", level.name, err)
+		}
+		*level.dest = consistency
+	}
+	if err := result.Serial.UnmarshalText([]byte(strings.ToUpper(c.SerialConsistency))); err != nil {
+		return result, fmt.Errorf("serial consistency: %w", err)
+	}
+	return result, nil
 }
 
 // ServerConfig holds HTTP server configuration
 type ServerConfig struct {
-	Port string
+	Addr            string        `yaml:"addr" toml:"addr" env:"HTTP_ADDR" flag:"http-addr" usage:"HTTP listen address" required:"true"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT" flag:"shutdown-timeout" usage:"time allowed for a graceful shutdown" required:"true"`
+	DrainPeriod     time.Duration `yaml:"drain_period" toml:"drain_period" env:"DRAIN_PERIOD" flag:"drain-period" usage:"how long /readyz reports draining before the server stops; part of the shutdown timeout"`
+}
+
+// PublisherConfig points at the publisher service the discussion service calls back
+type PublisherConfig struct {
+	URL   string        `yaml:"url" toml:"url" env:"PUBLISHER_URL" flag:"publisher-url" usage:"base URL of the publisher service" required:"true"`
+	Token loader.Secret `yaml:"token" toml:"token" env:"PUBLISHER_TOKEN" flag:"publisher-token" usage:"bearer token news are read with, the publisher's DISCUSSION_TOKEN"`
+}
+
+// AdminConfig protects the admin API, e.g. the dead-letter endpoints
+type AdminConfig struct {
+	Token loader.Secret `yaml:"token" toml:"token" env:"ADMIN_TOKEN" flag:"admin-token" usage:"bearer token of the admin API; the API is disabled when empty"`
+}
+
+// IDConfig identifies the instance in the IDs it generates
+type IDConfig struct {
+	NodeID int64 `yaml:"node_id" toml:"node_id" env:"NODE_ID" flag:"node-id" usage:"node ID (0-1023) of generated message IDs, unique per instance; required"`
+}
+
+// Cache backends
+const (
+	CacheNone   = "none"
+	CacheMemory = "memory"
+	CacheRedis  = "redis"
+)
+
+// CacheConfig configures the cache of messages in front of Cassandra
+type CacheConfig struct {
+	Backend       string        `yaml:"backend" toml:"backend" env:"CACHE_BACKEND" flag:"cache-backend" usage:"message cache: memory (per instance, updates elsewhere show after the TTL), redis or none"`
+	TTL           time.Duration `yaml:"ttl" toml:"ttl" env:"CACHE_TTL" flag:"cache-ttl" usage:"how long cached messages are kept"`
+	Size          int           `yaml:"size" toml:"size" env:"CACHE_SIZE" flag:"cache-size" usage:"entries of the in-memory cache"`
+	RedisAddr     string        `yaml:"redis_addr" toml:"redis_addr" env:"CACHE_REDIS_ADDR" flag:"cache-redis-addr" usage:"Redis address of the redis cache"`
+	RedisPassword loader.Secret `yaml:"redis_password" toml:"redis_password" env:"CACHE_REDIS_PASSWORD" flag:"cache-redis-password" usage:"Redis password"`
+	RedisDB       int           `yaml:"redis_db" toml:"redis_db" env:"CACHE_REDIS_DB" flag:"cache-redis-db" usage:"Redis database number"`
+}
+
+// NewCache creates the cache of the configured backend; it is nil for none
+func (c *CacheConfig) NewCache() (cache.Cache, error) {
+	switch c.Backend {
+	case CacheNone:
+		return nil, nil
+	case CacheMemory:
+		return cache.NewLRU(c.Size), nil
+	case CacheRedis:
+		client := redis.NewClient(&redis.Options{
+			Addr:     c.RedisAddr,
+			Password: c.RedisPassword.Value(),
+			DB:       c.RedisDB,
+		})
+		return cache.NewRedis(client, "discussion:"), nil
+	default:
+		return nil, fmt.Errorf("unknown cache backend %q", c.Backend)
+	}
+}
+
+// Sources of the moderation lists
+const (
+	ModerationSourceDefault   = "default"
+	ModerationSourceFile      = "file"
+	ModerationSourceCassandra = "cassandra"
+)
+
+// ModerationConfig selects where the moderation lists come from and sets the
+// limits of the heuristic rules
+type ModerationConfig struct {
+	Source           string        `yaml:"source" toml:"source" env:"MODERATION_SOURCE" flag:"moderation-source" usage:"where the moderation lists come from: default, file or cassandra"`
+	File             string        `yaml:"file" toml:"file" env:"MODERATION_FILE" flag:"moderation-file" usage:"YAML file of the moderation lists when the source is file"`
+	ReloadInterval   time.Duration `yaml:"reload_interval" toml:"reload_interval" env:"MODERATION_RELOAD_INTERVAL" flag:"moderation-reload-interval" usage:"how often the moderation lists are reloaded, 0 disables reloading"`
+	MaxLinks         int           `yaml:"max_links" toml:"max_links" env:"MODERATION_MAX_LINKS" flag:"moderation-max-links" usage:"links allowed in a message, 0 allows any"`
+	MaxRepeatedChars int           `yaml:"max_repeated_chars" toml:"max_repeated_chars" env:"MODERATION_MAX_REPEATED_CHARS" flag:"moderation-max-repeated-chars" usage:"longest run of one character, 0 disables the check"`
+	MaxUpperRatio    float64       `yaml:"max_upper_ratio" toml:"max_upper_ratio" env:"MODERATION_MAX_UPPER_RATIO" flag:"moderation-max-upper-ratio" usage:"highest share of capital letters, 0 disables the check"`
+	RateLimit        int           `yaml:"rate_limit" toml:"rate_limit" env:"MODERATION_RATE_LIMIT" flag:"moderation-rate-limit" usage:"messages an author may write per rate window, 0 disables the limit"`
+	RateWindow       time.Duration `yaml:"rate_window" toml:"rate_window" env:"MODERATION_RATE_WINDOW" flag:"moderation-rate-window" usage:"window of the per-author rate limit"`
+}
+
+// Settings returns the limits of the heuristic rules
+func (c *ModerationConfig) Settings() moderation.Settings {
+	return moderation.Settings{
+		MaxLinks:         c.MaxLinks,
+		MaxRepeatedChars: c.MaxRepeatedChars,
+		MaxUpperRatio:    c.MaxUpperRatio,
+		RateLimit:        c.RateLimit,
+		RateWindow:       c.RateWindow,
+	}
+}
+
+// NewSource creates the source of the moderation lists
+func (c *ModerationConfig) NewSource(session *gocql.Session) (moderation.Source, error) {
+	switch c.Source {
+	case ModerationSourceDefault, "":
+		return moderation.StaticSource{Lists: moderation.DefaultLists()}, nil
+	case ModerationSourceFile:
+		if c.File == "" {
+			return nil, fmt.Errorf("MODERATION_FILE is required when the moderation source is file")
+		}
+		return moderation.FileSource{Path: c.File}, nil
+	case ModerationSourceCassandra:
+		return moderation.CassandraSource{Session: session}, nil
+	default:
+		return nil, fmt.Errorf("unknown moderation source %q", c.Source)
+	}
 }
 
 // NewConfig creates a new configuration with default values
@@ -29,13 +183,50 @@ func NewConfig() *Config {
 	return &Config{
 		Kafka: NewKafkaConfig([]string{"localhost:9092"}),
 		DB: &DBConfig{
-			Hosts:       []string{"localhost"},
-			Keyspace:    "distcomp",
-			Consistency: "quorum",
-			Timeout:     5 * time.Second,
+			Hosts:             []string{"localhost"},
+			Port:              9042,
+			Keyspace:          "distcomp",
+			Consistency:       "quorum",
+			Timeout:           5 * time.Second,
+			SerialConsistency: "serial",
+			MigrateTimeout:    time.Minute,
 		},
 		Server: &ServerConfig{
-			Port: ":24130",
+			Addr:            ":24130",
+			ShutdownTimeout: 15 * time.Second,
+			DrainPeriod:     5 * time.Second,
+		},
+		Publisher: &PublisherConfig{
+			URL: "http://localhost:24110",
+		},
+		Admin: &AdminConfig{},
+		Moderation: &ModerationConfig{
+			Source:         ModerationSourceDefault,
+			ReloadInterval: 30 * time.Second,
+			RateWindow:     time.Minute,
+		},
+		IDs: &IDConfig{NodeID: -1},
+		Cache: &CacheConfig{
+			Backend:   CacheMemory,
+			TTL:       time.Minute,
+			Size:      10000,
+			RedisAddr: "localhost:6379",
 		},
+		Tracing: loader.DefaultTracing(),
+		Logging: loader.DefaultLogging(),
+	}
+}
+
+// Load reads the configuration over the defaults from the config file,
+// the environment and args, and returns the arguments left after the flags
+func Load(args []string) (*Config, []string, error) {
+	cfg := NewConfig()
+	rest, err := loader.Load(cfg, args)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := loader.ValidateDrain(cfg.Server.DrainPeriod, cfg.Server.ShutdownTimeout); err != nil {
+		return nil, nil, err
 	}
+	return cfg, rest, nil
 }
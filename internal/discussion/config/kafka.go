@@ -1,20 +1,64 @@
 package config
 
 import (
+	"errors"
+	"fmt"
 	"github.com/IBM/sarama"
-	"log"
+	"log/slog"
+	"strings"
 	"time"
 )
 
 const (
 	InTopic  = "message-in"
 	OutTopic = "message-out"
+	// DLQTopic receives message-in records that could not be processed
+	DLQTopic = "message-dlq"
 )
 
+// Request/reply headers of message-in records. A requester sets a correlation
+// ID and the topic it listens on; the reply carries the same correlation ID.
+const (
+	CorrelationIDHeader = "X-Correlation-ID"
+	ReplyToHeader       = "X-Reply-To"
+	// ReplyTopicPrefix starts the names of the topics other than OutTopic
+	// that replies may be sent to, e.g. "message-reply.publisher"
+	ReplyTopicPrefix = "message-reply."
+)
+
+// IsReplyTopic reports whether a requester may have replies sent to topic.
+// Anyone producing to InTopic sets the reply topic, so it is restricted to
+// OutTopic and topics named with ReplyTopicPrefix.
+func IsReplyTopic(topic string) bool {
+	return topic == OutTopic || (strings.HasPrefix(topic, ReplyTopicPrefix) && len(topic) > len(ReplyTopicPrefix))
+}
+
 // KafkaConfig holds Kafka configuration
 type KafkaConfig struct {
-	Brokers []string
-	Config  *sarama.Config
+	Brokers []string       `yaml:"brokers" toml:"brokers" env:"KAFKA_BROKERS" flag:"kafka-brokers" usage:"Kafka brokers separated by commas" required:"true"`
+	MaxLag  int64          `yaml:"max_lag" toml:"max_lag" env:"KAFKA_MAX_LAG" flag:"kafka-max-lag" usage:"consumer lag above which the service reports not ready"`
+	Retry   RetryConfig    `yaml:"retry" toml:"retry"`
+	Config  *sarama.Config `yaml:"-" toml:"-"`
+}
+
+// RetryConfig bounds how often the consumer retries a failing record before
+// sending it to DLQTopic
+type RetryConfig struct {
+	Attempts   int           `yaml:"attempts" toml:"attempts" env:"KAFKA_RETRY_ATTEMPTS" flag:"kafka-retry-attempts" usage:"attempts to process a record before it goes to the dead-letter topic"`
+	Backoff    time.Duration `yaml:"backoff" toml:"backoff" env:"KAFKA_RETRY_BACKOFF" flag:"kafka-retry-backoff" usage:"delay before the first retry, doubled on every further one"`
+	MaxBackoff time.Duration `yaml:"max_backoff" toml:"max_backoff" env:"KAFKA_RETRY_MAX_BACKOFF" flag:"kafka-retry-max-backoff" usage:"upper bound of the retry delay"`
+}
+
+// Delay returns the wait before retry number attempt (starting at 1)
+func (r RetryConfig) Delay(attempt int) time.Duration {
+	delay := r.Backoff
+	for i := 1; i < attempt && delay < r.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if r.MaxBackoff > 0 && delay > r.MaxBackoff {
+		delay = r.MaxBackoff
+	}
+	return delay
 }
 
 // NewKafkaConfig creates a new Kafka configuration
@@ -43,29 +87,37 @@ func NewKafkaConfig(brokers []string) *KafkaConfig {
 
 	return &KafkaConfig{
 		Brokers: brokers,
-		Config:  config,
+		MaxLag:  1000,
+		Retry: RetryConfig{
+			Attempts:   3,
+			Backoff:    200 * time.Millisecond,
+			MaxBackoff: 5 * time.Second,
+		},
+		Config: config,
 	}
 }
 
-// CreateTopics creates Kafka topics if they don't exist
-func (k *KafkaConfig) CreateTopics() error {
+// CreateTopics creates the message topics and the extra ones if they don't
+// exist. A topic that fails does not stop the others; the errors are joined.
+func (k *KafkaConfig) CreateTopics(extra ...string) error {
 	admin, err := sarama.NewClusterAdmin(k.Brokers, k.Config)
 	if err != nil {
 		return err
 	}
 	defer admin.Close()
 
-	topics := []string{InTopic, OutTopic}
+	var errs []error
+	topics := append([]string{InTopic, OutTopic, DLQTopic}, extra...)
 	for _, topic := range topics {
 		err := admin.CreateTopic(topic, &sarama.TopicDetail{
 			NumPartitions:     3,
 			ReplicationFactor: 1, // For local development
 		}, false)
-		if err != nil && err != sarama.ErrTopicAlreadyExists {
-			log.Printf("Error creating topic %s: %v", topic, err)
-			return err
+		// The admin returns a *sarama.TopicError wrapping the code
+		if err != nil && !errors.Is(err, sarama.ErrTopicAlreadyExists) {
+			slog.Error("failed to create topic", slog.String("topic", topic), slog.Any("error", err))
+			errs = append(errs, fmt.Errorf("topic %s: %w", topic, err))
 		}
 	}
-
-	return nil
+	return errors.Join(errs...)
 }
@@ -0,0 +1,66 @@
+package config
+
+import (
+	"github.com/IBM/sarama"
+	"testing"
+	"time"
+)
+
+// TestCreateTopicsExisting checks that a topic that already exists does not
+// stop the topics after it from being created
+func TestCreateTopicsExisting(t *testing.T) {
+	broker := sarama.NewMockBroker(t, 1)
+	defer broker.Close()
+
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"ApiVersionsRequest": sarama.NewMockApiVersionsResponse(t),
+		"MetadataRequest": sarama.NewMockMetadataResponse(t).
+			SetController(broker.BrokerID()).
+			SetBroker(broker.Addr(), broker.BrokerID()),
+		"CreateTopicsRequest": sarama.NewMockWrapper(&sarama.CreateTopicsResponse{
+			Version: 3,
+			TopicErrors: map[string]*sarama.TopicError{
+				InTopic:       {Err: sarama.ErrTopicAlreadyExists},
+				OutTopic:      {Err: sarama.ErrNoError},
+				DLQTopic:      {Err: sarama.ErrNoError},
+				"news-events": {Err: sarama.ErrNoError},
+			},
+		}),
+	})
+
+	k := NewKafkaConfig([]string{broker.Addr()})
+	k.Config.Admin.Retry.Max = 0
+	k.Config.Net.ReadTimeout = time.Second
+	if err := k.CreateTopics("news-events"); err != nil {
+		t.Fatalf("CreateTopics() error = %v", err)
+	}
+
+	requested := 0
+	for _, rr := range broker.History() {
+		if _, ok := rr.Request.(*sarama.CreateTopicsRequest); ok {
+			requested++
+		}
+	}
+	if requested != 4 {
+		t.Fatalf("%d topics requested, want 4", requested)
+	}
+}
+
+func TestIsReplyTopic(t *testing.T) {
+	tests := []struct {
+		topic string
+		want  bool
+	}{
+		{OutTopic, true},
+		{ReplyTopicPrefix + "publisher", true},
+		{ReplyTopicPrefix, false},
+		{InTopic, false},
+		{DLQTopic, false},
+		{"news-events", false},
+	}
+	for _, tt := range tests {
+		if got := IsReplyTopic(tt.topic); got != tt.want {
+			t.Errorf("IsReplyTopic(%q) = %v, want %v", tt.topic, got, tt.want)
+		}
+	}
+}
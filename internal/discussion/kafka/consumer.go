@@ -3,28 +3,41 @@ package kafka
 import (
 	"RESTAPI/internal/discussion/config"
 	"RESTAPI/internal/discussion/model"
+	"RESTAPI/internal/discussion/moderation"
 	"RESTAPI/internal/discussion/service"
+	"RESTAPI/internal/logging"
+	"RESTAPI/internal/metrics"
+	"RESTAPI/internal/tracing"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/IBM/sarama"
-	"log"
-	"strings"
+	"go.opentelemetry.io/otel/codes"
+	"log/slog"
 	"sync"
+	"time"
 )
 
+// ConsumerGroup is the consumer group of the discussion service
+const ConsumerGroup = "discussion-group"
+
 // Consumer handles message consumption from Kafka
 type Consumer struct {
 	consumer       sarama.ConsumerGroup
 	messageService *service.MessageService
 	producer       *Producer
+	moderator      moderation.Moderator
+	retryPolicy    config.RetryConfig
 	stopCh         chan struct{}
 	stopOnce       sync.Once
+	cancel         context.CancelFunc
+	done           chan struct{}
 }
 
 // NewConsumer creates a new Kafka consumer
-func NewConsumer(kafkaConfig *config.KafkaConfig, messageService *service.MessageService, producer *Producer) (*Consumer, error) {
-	group, err := sarama.NewConsumerGroup(kafkaConfig.Brokers, "discussion-group", kafkaConfig.Config)
+func NewConsumer(kafkaConfig *config.KafkaConfig, messageService *service.MessageService, producer *Producer, moderator moderation.Moderator) (*Consumer, error) {
+	group, err := sarama.NewConsumerGroup(kafkaConfig.Brokers, ConsumerGroup, kafkaConfig.Config)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create consumer group: %v", err)
 	}
@@ -33,23 +46,28 @@ func NewConsumer(kafkaConfig *config.KafkaConfig, messageService *service.Messag
 		consumer:       group,
 		messageService: messageService,
 		producer:       producer,
+		moderator:      moderator,
+		retryPolicy:    kafkaConfig.Retry,
 		stopCh:         make(chan struct{}),
+		done:           make(chan struct{}),
 	}, nil
 }
 
 // Start starts consuming messages
 func (c *Consumer) Start() error {
 	topics := []string{config.InTopic}
-	ctx := context.Background()
+	ctx, cancel := context.WithCancel(context.Background())
+	c.cancel = cancel
 
 	go func() {
+		defer close(c.done)
 		for {
 			select {
 			case <-c.stopCh:
 				return
 			default:
 				if err := c.consumer.Consume(ctx, topics, c); err != nil {
-					log.Printf("Error from consumer: %v", err)
+					slog.Error("consumer session failed", slog.Any("error", err))
 				}
 			}
 		}
@@ -58,14 +76,28 @@ func (c *Consumer) Start() error {
 	return nil
 }
 
-// Stop stops consuming messages
-func (c *Consumer) Stop() {
+// Stop stops consuming messages. The message being processed is finished,
+// its offset committed, and the consumer group is left before Stop returns
+// or ctx expires.
+func (c *Consumer) Stop(ctx context.Context) error {
+	var err error
 	c.stopOnce.Do(func() {
 		close(c.stopCh)
-		if err := c.consumer.Close(); err != nil {
-			log.Printf("Error closing consumer: %v", err)
+		if c.cancel != nil {
+			c.cancel()
+
+			select {
+			case <-c.done:
+			case <-ctx.Done():
+				slog.Warn("consumer did not stop in time", slog.Any("error", ctx.Err()))
+			}
+		}
+
+		if err = c.consumer.Close(); err != nil {
+			slog.Error("failed to close consumer", slog.Any("error", err))
 		}
 	})
+	return err
 }
 
 // Setup is run at the beginning of a new session
@@ -73,55 +105,185 @@ func (c *Consumer) Setup(_ sarama.ConsumerGroupSession) error {
 	return nil
 }
 
-// Cleanup is run at the end of a session
-func (c *Consumer) Cleanup(_ sarama.ConsumerGroupSession) error {
+// Cleanup is run at the end of a session; it commits the offsets marked so
+// far instead of waiting for the next auto-commit tick
+func (c *Consumer) Cleanup(session sarama.ConsumerGroupSession) error {
+	session.Commit()
 	return nil
 }
 
-// moderateMessage checks if message contains stop words
-func (c *Consumer) moderateMessage(message *model.Message) model.MessageState {
-	stopWords := []string{"spam", "abuse", "hate", "violence"}
-	content := strings.ToLower(message.Content)
+// ConsumeClaim processes messages from a partition
+func (c *Consumer) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	for {
+		select {
+		case message, ok := <-claim.Messages():
+			if !ok {
+				return nil
+			}
+
+			metrics.SetConsumerLag(message.Topic, message.Partition, claim.HighWaterMarkOffset(), message.Offset)
+			if !c.process(session, message) {
+				// Later records must not be marked before this one
+				return nil
+			}
+
+		case <-c.stopCh:
+			return nil
+		case <-session.Context().Done():
+			return nil
+		}
+	}
+}
+
+// process handles one record inside a span that continues the producer's trace.
+// A record that still fails after the retries goes to the dead-letter topic,
+// so every record is marked once it has been dealt with. process returns
+// false when the consumer stops before that; the record is then left
+// unmarked and consumed again after the restart.
+func (c *Consumer) process(session sarama.ConsumerGroupSession, message *sarama.ConsumerMessage) bool {
+	ctx, span := tracing.StartConsume(context.Background(), message)
+	defer span.End()
+	ctx = logging.WithRequestID(ctx, requestIDOf(message))
 
-	for _, word := range stopWords {
-		if strings.Contains(content, word) {
-			return model.StateDecline
+	stage, attempts, err := c.handle(ctx, session, message)
+	if errors.Is(err, errStopping) {
+		return false
+	}
+	metrics.ObserveConsumed(message.Topic, err)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to process message",
+			slog.String("topic", message.Topic),
+			slog.Int("partition", int(message.Partition)),
+			slog.Int64("offset", message.Offset),
+			slog.String("stage", stage),
+			slog.Int("attempts", attempts),
+			slog.Any("error", err))
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		if !c.deadLetter(ctx, session, message, stage, attempts, err) {
+			return false
 		}
 	}
 
-	return model.StateApprove
+	session.MarkMessage(message, "")
+	return true
 }
 
-// ConsumeClaim processes messages from a partition
-func (c *Consumer) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
-	for {
-		select {
-		case message := <-claim.Messages():
-			var msg model.Message
-			if err := json.Unmarshal(message.Value, &msg); err != nil {
-				log.Printf("Error unmarshaling message: %v", err)
-				continue
-			}
+// handle moderates, saves and answers a request. On failure it returns the
+// stage that failed and how many attempts were made.
+func (c *Consumer) handle(ctx context.Context, session sarama.ConsumerGroupSession, message *sarama.ConsumerMessage) (string, int, error) {
+	// A record that cannot be decoded will never succeed, so it is not retried
+	var msg model.Message
+	if err := json.Unmarshal(message.Value, &msg); err != nil {
+		return StageDecode, 1, fmt.Errorf("failed to unmarshal message: %w", err)
+	}
 
-			// Moderate message
-			msg.State = c.moderateMessage(&msg)
+	// Moderate message; the rule that declined it is stored with it
+	verdict := c.moderator.Moderate(ctx, &msg)
+	msg.State, msg.DeclineRule = verdict.State, verdict.Rule
+	metrics.ObserveModeration(string(verdict.State), verdict.Rule)
+	if verdict.State == model.StateDecline {
+		slog.InfoContext(ctx, "message declined",
+			slog.Int64("message_id", msg.ID),
+			slog.String("rule", verdict.Rule),
+			logging.Content(verdict.Reason))
+	}
 
-			// Save message to database
-			if err := c.messageService.CreateMessage(context.Background(), &msg); err != nil {
-				log.Printf("Error saving message: %v", err)
-				continue
-			}
+	// Save message to database
+	attempts, err := c.retry(ctx, session, message, StageSave, func() error {
+		return c.messageService.CreateMessage(ctx, &msg)
+	})
+	if err != nil {
+		return StageSave, attempts, err
+	}
 
-			// Send response to OutTopic
-			if err := c.producer.SendMessage(config.OutTopic, &msg); err != nil {
-				log.Printf("Error sending response: %v", err)
-				continue
-			}
+	// Send response to the requested reply topic, OutTopic by default
+	replyTo, headers := replyOf(message)
+	attempts, err = c.retry(ctx, session, message, StageReply, func() error {
+		return c.producer.SendMessage(ctx, replyTo, &msg, headers...)
+	})
+	if err != nil {
+		return StageReply, attempts, err
+	}
+	return "", attempts, nil
+}
 
-			session.MarkMessage(message, "")
+// retry runs fn up to the configured number of attempts with exponential
+// backoff between them. A news that does not exist will not appear, so that
+// error is not retried. It returns errStopping if the consumer stops while waiting.
+func (c *Consumer) retry(ctx context.Context, session sarama.ConsumerGroupSession, message *sarama.ConsumerMessage, stage string, fn func() error) (int, error) {
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= c.retryPolicy.Attempts || errors.Is(err, service.ErrNewsNotFound) {
+			return attempt, err
+		}
 
-		case <-c.stopCh:
-			return nil
+		delay := c.retryPolicy.Delay(attempt)
+		slog.WarnContext(ctx, "retrying message",
+			slog.Int64("offset", message.Offset),
+			slog.String("stage", stage),
+			slog.Int("attempt", attempt),
+			slog.Duration("delay", delay),
+			slog.Any("error", err))
+		metrics.ObserveRetry(message.Topic, stage)
+		if !c.sleep(session, delay) {
+			return attempt, errStopping
+		}
+	}
+}
+
+// sleep waits for d and reports false if the consumer stops or the session ends first
+func (c *Consumer) sleep(session sarama.ConsumerGroupSession, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-c.stopCh:
+		return false
+	case <-session.Context().Done():
+		return false
+	}
+}
+
+// requestIDOf continues the request ID of the producer or starts a new one
+func requestIDOf(message *sarama.ConsumerMessage) string {
+	if id := header(message, logging.RequestIDHeader); id != "" {
+		return id
+	}
+	return logging.NewRequestID()
+}
+
+// replyOf returns the topic to answer a request on and the headers that
+// correlate the reply with it. A reply topic that is not allowed, see
+// config.IsReplyTopic, is replaced by OutTopic.
+func replyOf(message *sarama.ConsumerMessage) (string, []sarama.RecordHeader) {
+	topic := header(message, config.ReplyToHeader)
+	if topic != "" && !config.IsReplyTopic(topic) {
+		slog.Warn("reply topic not allowed, replying on "+config.OutTopic,
+			slog.String("reply_to", topic),
+			slog.Int("partition", int(message.Partition)),
+			slog.Int64("offset", message.Offset))
+		topic = ""
+	}
+	if topic == "" {
+		topic = config.OutTopic
+	}
+
+	var headers []sarama.RecordHeader
+	if id := header(message, config.CorrelationIDHeader); id != "" {
+		headers = append(headers, sarama.RecordHeader{Key: []byte(config.CorrelationIDHeader), Value: []byte(id)})
+	}
+	return topic, headers
+}
+
+// header returns the value of the first record header with the given key, or ""
+func header(message *sarama.ConsumerMessage, key string) string {
+	for _, h := range message.Headers {
+		if h != nil && string(h.Key) == key && len(h.Value) > 0 {
+			return string(h.Value)
 		}
 	}
+	return ""
 }
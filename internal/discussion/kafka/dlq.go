@@ -0,0 +1,303 @@
+package kafka
+
+import (
+	"RESTAPI/internal/discussion/config"
+	"RESTAPI/internal/metrics"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/IBM/sarama"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Processing stages reported in the dead-letter headers
+const (
+	StageDecode = "decode"
+	StageSave   = "save"
+	StageReply  = "reply"
+)
+
+// Headers added to a record sent to the dead-letter topic. The original
+// headers are kept, so a replayed record carries its request and correlation IDs.
+const (
+	dlqHeaderPrefix    = "X-DLQ-"
+	DLQErrorHeader     = "X-DLQ-Error"
+	DLQStageHeader     = "X-DLQ-Stage"
+	DLQAttemptsHeader  = "X-DLQ-Attempts"
+	DLQTopicHeader     = "X-DLQ-Original-Topic"
+	DLQPartitionHeader = "X-DLQ-Original-Partition"
+	DLQOffsetHeader    = "X-DLQ-Original-Offset"
+	DLQFailedAtHeader  = "X-DLQ-Failed-At"
+)
+
+// maxErrorLength bounds the error text stored in a header
+const maxErrorLength = 1024
+
+// dlqReadTimeout bounds reading the dead-letter topic for the admin API
+const dlqReadTimeout = 10 * time.Second
+
+// errStopping aborts processing of a record because the consumer is stopping
+var errStopping = errors.New("consumer is stopping")
+
+// ErrDeadLetterNotFound is returned when no dead letter has the requested position
+var ErrDeadLetterNotFound = errors.New("dead letter not found")
+
+// deadLetter sends a record that could not be processed to the dead-letter
+// topic. It keeps trying until the record is written, since the record would
+// otherwise be lost, and returns false if the consumer stops first.
+func (c *Consumer) deadLetter(ctx context.Context, session sarama.ConsumerGroupSession, message *sarama.ConsumerMessage, stage string, attempts int, cause error) bool {
+	for attempt := 1; ; attempt++ {
+		err := c.producer.send(ctx, deadLetterRecord(message, stage, attempts, cause))
+		if err == nil {
+			metrics.ObserveDeadLetter(message.Topic, stage)
+			slog.WarnContext(ctx, "message sent to dead-letter topic",
+				slog.Int("partition", int(message.Partition)),
+				slog.Int64("offset", message.Offset),
+				slog.String("stage", stage))
+			return true
+		}
+
+		slog.ErrorContext(ctx, "failed to send message to dead-letter topic",
+			slog.Int("partition", int(message.Partition)),
+			slog.Int64("offset", message.Offset),
+			slog.Any("error", err))
+		if !c.sleep(session, c.retryPolicy.Delay(attempt)) {
+			return false
+		}
+	}
+}
+
+// deadLetterRecord copies the record for the dead-letter topic and describes the failure in its headers
+func deadLetterRecord(message *sarama.ConsumerMessage, stage string, attempts int, cause error) *sarama.ProducerMessage {
+	text := cause.Error()
+	if len(text) > maxErrorLength {
+		text = text[:maxErrorLength]
+	}
+
+	headers := originalHeaders(message)
+	headers = append(headers,
+		sarama.RecordHeader{Key: []byte(DLQErrorHeader), Value: []byte(text)},
+		sarama.RecordHeader{Key: []byte(DLQStageHeader), Value: []byte(stage)},
+		sarama.RecordHeader{Key: []byte(DLQAttemptsHeader), Value: []byte(strconv.Itoa(attempts))},
+		sarama.RecordHeader{Key: []byte(DLQTopicHeader), Value: []byte(message.Topic)},
+		sarama.RecordHeader{Key: []byte(DLQPartitionHeader), Value: []byte(strconv.Itoa(int(message.Partition)))},
+		sarama.RecordHeader{Key: []byte(DLQOffsetHeader), Value: []byte(strconv.FormatInt(message.Offset, 10))},
+		sarama.RecordHeader{Key: []byte(DLQFailedAtHeader), Value: []byte(time.Now().UTC().Format(time.RFC3339Nano))},
+	)
+
+	record := &sarama.ProducerMessage{
+		Topic:   config.DLQTopic,
+		Value:   sarama.ByteEncoder(message.Value),
+		Headers: headers,
+	}
+	if message.Key != nil {
+		record.Key = sarama.ByteEncoder(message.Key)
+	}
+	return record
+}
+
+// originalHeaders returns the headers of a record without the dead-letter ones
+func originalHeaders(message *sarama.ConsumerMessage) []sarama.RecordHeader {
+	headers := make([]sarama.RecordHeader, 0, len(message.Headers))
+	for _, h := range message.Headers {
+		if h == nil || strings.HasPrefix(string(h.Key), dlqHeaderPrefix) {
+			continue
+		}
+		headers = append(headers, *h)
+	}
+	return headers
+}
+
+// DeadLetter is a record of the dead-letter topic as shown by the admin API
+type DeadLetter struct {
+	Partition         int32     `json:"partition"`
+	Offset            int64     `json:"offset"`
+	Key               string    `json:"key,omitempty"`
+	Value             string    `json:"value"`
+	Error             string    `json:"error"`
+	Stage             string    `json:"stage"`
+	Attempts          int       `json:"attempts"`
+	OriginalTopic     string    `json:"originalTopic"`
+	OriginalPartition int32     `json:"originalPartition"`
+	OriginalOffset    int64     `json:"originalOffset"`
+	FailedAt          time.Time `json:"failedAt"`
+}
+
+func newDeadLetter(message *sarama.ConsumerMessage) *DeadLetter {
+	d := &DeadLetter{
+		Partition:     message.Partition,
+		Offset:        message.Offset,
+		Key:           string(message.Key),
+		Value:         string(message.Value),
+		Error:         header(message, DLQErrorHeader),
+		Stage:         header(message, DLQStageHeader),
+		OriginalTopic: header(message, DLQTopicHeader),
+	}
+	d.Attempts, _ = strconv.Atoi(header(message, DLQAttemptsHeader))
+	partition, _ := strconv.ParseInt(header(message, DLQPartitionHeader), 10, 32)
+	d.OriginalPartition = int32(partition)
+	d.OriginalOffset, _ = strconv.ParseInt(header(message, DLQOffsetHeader), 10, 64)
+	d.FailedAt, _ = time.Parse(time.RFC3339Nano, header(message, DLQFailedAtHeader))
+	return d
+}
+
+// DeadLetters reads the dead-letter topic and replays its records
+type DeadLetters struct {
+	client   sarama.Client
+	producer *Producer
+}
+
+// NewDeadLetters creates a DeadLetters that replays records through producer
+func NewDeadLetters(kafkaConfig *config.KafkaConfig, producer *Producer) (*DeadLetters, error) {
+	client, err := sarama.NewClient(kafkaConfig.Brokers, kafkaConfig.Config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create client: %v", err)
+	}
+	return &DeadLetters{client: client, producer: producer}, nil
+}
+
+// List returns up to limit of the most recent dead letters, newest first
+func (d *DeadLetters) List(ctx context.Context, limit int) ([]*DeadLetter, error) {
+	partitions, err := d.client.Partitions(config.DLQTopic)
+	if err != nil {
+		return nil, err
+	}
+
+	letters := []*DeadLetter{}
+	for _, partition := range partitions {
+		oldest, newest, err := d.offsets(partition)
+		if err != nil {
+			return nil, err
+		}
+		from := newest - int64(limit)
+		if from < oldest {
+			from = oldest
+		}
+		err = d.read(ctx, partition, from, newest, func(message *sarama.ConsumerMessage) {
+			letters = append(letters, newDeadLetter(message))
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Slice(letters, func(i, j int) bool {
+		if !letters[i].FailedAt.Equal(letters[j].FailedAt) {
+			return letters[i].FailedAt.After(letters[j].FailedAt)
+		}
+		if letters[i].Partition != letters[j].Partition {
+			return letters[i].Partition < letters[j].Partition
+		}
+		return letters[i].Offset > letters[j].Offset
+	})
+	if len(letters) > limit {
+		letters = letters[:limit]
+	}
+	return letters, nil
+}
+
+// Replay sends the dead letter at the given position back to its original
+// topic with its original key and headers. The dead letter itself stays in
+// the topic; if the record fails again it is dead-lettered anew.
+func (d *DeadLetters) Replay(ctx context.Context, partition int32, offset int64) (*DeadLetter, error) {
+	oldest, newest, err := d.offsets(partition)
+	if err != nil {
+		return nil, err
+	}
+	if offset < oldest || offset >= newest {
+		return nil, fmt.Errorf("%w: partition %d offset %d", ErrDeadLetterNotFound, partition, offset)
+	}
+
+	var found *sarama.ConsumerMessage
+	err = d.read(ctx, partition, offset, offset+1, func(message *sarama.ConsumerMessage) {
+		if message.Offset == offset {
+			found = message
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if found == nil {
+		return nil, fmt.Errorf("%w: partition %d offset %d", ErrDeadLetterNotFound, partition, offset)
+	}
+
+	letter := newDeadLetter(found)
+	topic := letter.OriginalTopic
+	if topic == "" {
+		topic = config.InTopic
+	}
+	record := &sarama.ProducerMessage{
+		Topic:   topic,
+		Value:   sarama.ByteEncoder(found.Value),
+		Headers: originalHeaders(found),
+	}
+	if found.Key != nil {
+		record.Key = sarama.ByteEncoder(found.Key)
+	}
+	if err := d.producer.send(ctx, record); err != nil {
+		return nil, err
+	}
+
+	slog.InfoContext(ctx, "dead letter replayed",
+		slog.Int("partition", int(partition)),
+		slog.Int64("offset", offset),
+		slog.String("topic", topic))
+	return letter, nil
+}
+
+// offsets returns the oldest offset of a partition and the offset its next record will get
+func (d *DeadLetters) offsets(partition int32) (int64, int64, error) {
+	oldest, err := d.client.GetOffset(config.DLQTopic, partition, sarama.OffsetOldest)
+	if err != nil {
+		return 0, 0, err
+	}
+	newest, err := d.client.GetOffset(config.DLQTopic, partition, sarama.OffsetNewest)
+	if err != nil {
+		return 0, 0, err
+	}
+	return oldest, newest, nil
+}
+
+// read passes the records of a partition in [from, to) to fn
+func (d *DeadLetters) read(ctx context.Context, partition int32, from, to int64, fn func(*sarama.ConsumerMessage)) error {
+	if from >= to {
+		return nil
+	}
+
+	consumer, err := sarama.NewConsumerFromClient(d.client)
+	if err != nil {
+		return err
+	}
+	defer consumer.Close()
+	pc, err := consumer.ConsumePartition(config.DLQTopic, partition, from)
+	if err != nil {
+		return err
+	}
+	defer pc.Close()
+
+	ctx, cancel := context.WithTimeout(ctx, dlqReadTimeout)
+	defer cancel()
+	for {
+		select {
+		case message, ok := <-pc.Messages():
+			if !ok {
+				return nil
+			}
+			fn(message)
+			if message.Offset >= to-1 {
+				return nil
+			}
+		case <-ctx.Done():
+			return fmt.Errorf("failed to read partition %d of %s: %w", partition, config.DLQTopic, ctx.Err())
+		}
+	}
+}
+
+// Close releases the client connections
+func (d *DeadLetters) Close() error {
+	return d.client.Close()
+}
@@ -0,0 +1,93 @@
+package kafka
+
+import (
+	"RESTAPI/internal/discussion/config"
+	"context"
+	"fmt"
+	"github.com/IBM/sarama"
+)
+
+// Health checks broker connectivity and the lag of the consumer group
+type Health struct {
+	client sarama.Client
+	admin  sarama.ClusterAdmin
+	maxLag int64
+}
+
+// NewHealth creates a Health that reports the consumer group as lagging
+// once more than maxLag messages of the input topic are unprocessed
+func NewHealth(kafkaConfig *config.KafkaConfig, maxLag int64) (*Health, error) {
+	client, err := sarama.NewClient(kafkaConfig.Brokers, kafkaConfig.Config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create client: %v", err)
+	}
+	admin, err := sarama.NewClusterAdminFromClient(client)
+	if err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to create cluster admin: %v", err)
+	}
+
+	return &Health{client: client, admin: admin, maxLag: maxLag}, nil
+}
+
+// CheckBrokers fails when no broker answers a metadata request
+func (h *Health) CheckBrokers(_ context.Context) error {
+	if err := h.client.RefreshMetadata(config.InTopic); err != nil {
+		return err
+	}
+	if _, err := h.client.Controller(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// CheckLag fails when the consumer group is more than maxLag messages behind
+func (h *Health) CheckLag(_ context.Context) error {
+	lag, err := h.Lag()
+	if err != nil {
+		return err
+	}
+	if lag > h.maxLag {
+		return fmt.Errorf("consumer group %s lags %d messages behind (limit %d)", ConsumerGroup, lag, h.maxLag)
+	}
+	return nil
+}
+
+// Lag returns how many messages of the input topic the consumer group has not committed yet
+func (h *Health) Lag() (int64, error) {
+	partitions, err := h.client.Partitions(config.InTopic)
+	if err != nil {
+		return 0, err
+	}
+
+	offsets, err := h.admin.ListConsumerGroupOffsets(ConsumerGroup, map[string][]int32{config.InTopic: partitions})
+	if err != nil {
+		return 0, err
+	}
+
+	var lag int64
+	for _, partition := range partitions {
+		newest, err := h.client.GetOffset(config.InTopic, partition, sarama.OffsetNewest)
+		if err != nil {
+			return 0, err
+		}
+
+		committed := int64(-1)
+		if block := offsets.GetBlock(config.InTopic, partition); block != nil {
+			committed = block.Offset
+		}
+		if committed < 0 {
+			// Nothing committed yet: the group starts from the newest offset
+			continue
+		}
+		if newest > committed {
+			lag += newest - committed
+		}
+	}
+	return lag, nil
+}
+
+// Close releases the client connections
+func (h *Health) Close() error {
+	return h.admin.Close()
+}
@@ -0,0 +1,76 @@
+package kafka
+
+import (
+	"RESTAPI/internal/discussion/config"
+	"RESTAPI/internal/discussion/model"
+	"RESTAPI/internal/discussion/repository"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/IBM/sarama"
+	"log/slog"
+	"sync"
+)
+
+// CacheInvalidator drops cached messages when their moderation results are
+// published to OutTopic. The consumer of any instance may have stored the
+// message, so every instance reads every partition from the newest offset,
+// without a consumer group, and drops the message and the list of its news.
+type CacheInvalidator struct {
+	repo       *repository.CachedMessageRepository
+	consumer   sarama.Consumer
+	partitions []sarama.PartitionConsumer
+	wg         sync.WaitGroup
+}
+
+// NewCacheInvalidator starts listening on OutTopic. Partitions added later
+// are not picked up until restart.
+func NewCacheInvalidator(kafkaConfig *config.KafkaConfig, repo *repository.CachedMessageRepository) (*CacheInvalidator, error) {
+	consumer, err := sarama.NewConsumer(kafkaConfig.Brokers, kafkaConfig.Config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create consumer: %v", err)
+	}
+	partitions, err := consumer.Partitions(config.OutTopic)
+	if err != nil {
+		consumer.Close()
+		return nil, fmt.Errorf("failed to list partitions of %s: %v", config.OutTopic, err)
+	}
+
+	i := &CacheInvalidator{repo: repo, consumer: consumer}
+	for _, partition := range partitions {
+		pc, err := consumer.ConsumePartition(config.OutTopic, partition, sarama.OffsetNewest)
+		if err != nil {
+			i.Close()
+			return nil, fmt.Errorf("failed to consume partition %d of %s: %v", partition, config.OutTopic, err)
+		}
+		i.partitions = append(i.partitions, pc)
+		i.wg.Add(1)
+		go i.listen(pc)
+	}
+	return i, nil
+}
+
+// listen invalidates the messages of one partition
+func (i *CacheInvalidator) listen(pc sarama.PartitionConsumer) {
+	defer i.wg.Done()
+	for record := range pc.Messages() {
+		var message model.Message
+		if err := json.Unmarshal(record.Value, &message); err != nil {
+			slog.Error("failed to unmarshal message-out record",
+				slog.Int("partition", int(record.Partition)),
+				slog.Int64("offset", record.Offset),
+				slog.Any("error", err))
+			continue
+		}
+		i.repo.Invalidate(context.Background(), &message)
+	}
+}
+
+// Close stops listening
+func (i *CacheInvalidator) Close() error {
+	for _, pc := range i.partitions {
+		pc.AsyncClose()
+	}
+	i.wg.Wait()
+	return i.consumer.Close()
+}
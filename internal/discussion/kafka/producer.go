@@ -3,10 +3,16 @@ package kafka
 import (
 	"RESTAPI/internal/discussion/config"
 	"RESTAPI/internal/discussion/model"
+	"RESTAPI/internal/logging"
+	"RESTAPI/internal/metrics"
+	"RESTAPI/internal/tracing"
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/IBM/sarama"
-	"log"
+	"go.opentelemetry.io/otel/codes"
+	"log/slog"
+	"time"
 )
 
 // Producer handles message production to Kafka
@@ -24,25 +30,45 @@ func NewProducer(kafkaConfig *config.KafkaConfig) (*Producer, error) {
 	return &Producer{producer: producer}, nil
 }
 
-// SendMessage sends a message to Kafka
-func (p *Producer) SendMessage(topic string, message *model.Message) error {
+// SendMessage sends a message to Kafka with the given extra headers; the
+// trace context of ctx travels in the record headers
+func (p *Producer) SendMessage(ctx context.Context, topic string, message *model.Message, headers ...sarama.RecordHeader) error {
 	value, err := json.Marshal(message)
 	if err != nil {
 		return fmt.Errorf("failed to marshal message: %v", err)
 	}
 
 	msg := &sarama.ProducerMessage{
-		Topic: topic,
-		Key:   sarama.StringEncoder(fmt.Sprintf("%d", message.NewsID)), // Ensure messages from same news go to same partition
-		Value: sarama.ByteEncoder(value),
+		Topic:   topic,
+		Key:     sarama.StringEncoder(fmt.Sprintf("%d", message.NewsID)), // Ensure messages from same news go to same partition
+		Value:   sarama.ByteEncoder(value),
+		Headers: headers,
 	}
 
+	if id := logging.RequestID(ctx); id != "" {
+		msg.Headers = append(msg.Headers, sarama.RecordHeader{Key: []byte(logging.RequestIDHeader), Value: []byte(id)})
+	}
+	return p.send(ctx, msg)
+}
+
+// send produces a prepared record; the trace context of ctx travels in its headers
+func (p *Producer) send(ctx context.Context, msg *sarama.ProducerMessage) error {
+	_, span := tracing.StartProduce(ctx, msg)
+	defer span.End()
+
+	start := time.Now()
 	partition, offset, err := p.producer.SendMessage(msg)
+	metrics.ObserveProduce(msg.Topic, start, err)
 	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
 		return fmt.Errorf("failed to send message: %v", err)
 	}
 
-	log.Printf("Message sent to partition %d at offset %d", partition, offset)
+	slog.DebugContext(ctx, "message sent",
+		slog.String("topic", msg.Topic),
+		slog.Int("partition", int(partition)),
+		slog.Int64("offset", offset))
 	return nil
 }
 
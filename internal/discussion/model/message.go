@@ -1,5 +1,10 @@
 package model
 
+import (
+	"RESTAPI/internal/logging"
+	"log/slog"
+)
+
 type MessageState string
 
 const (
@@ -15,6 +20,23 @@ type Message struct {
 	NewsID  int64        `json:"newsId" cql:"newsid"`
 	Content string       `json:"content" cql:"content"`
 	State   MessageState `json:"state"`
+	// DeclineRule names the moderation rule that declined the message
+	DeclineRule string `json:"declineRule,omitempty" cql:"decline_rule"`
+	// WriterID is the author; it travels in message-in for the rate rules and is not stored
+	WriterID int64 `json:"writerId,omitempty"`
+}
+
+// LogValue logs the message as a group; the content goes under the logging
+// content key, so it is redacted unless message content logging is enabled
+func (m Message) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.Int64("id", m.ID),
+		slog.Int64("news_id", m.NewsID),
+		slog.String("country", m.Country),
+		slog.String("state", string(m.State)),
+		slog.String("decline_rule", m.DeclineRule),
+		logging.Content(m.Content),
+	)
 }
 
 // MessageTable represents the Cassandra table name for messages
@@ -0,0 +1,101 @@
+package moderation
+
+import (
+	"context"
+	"fmt"
+	"github.com/gocql/gocql"
+	"gopkg.in/yaml.v3"
+	"os"
+)
+
+// Lists are the data of the rules that change at run time
+type Lists struct {
+	// StopWords are the stop words per language, e.g. "en" or "ru"
+	StopWords map[string][]string `yaml:"stop_words"`
+	// Patterns are regular expressions by name
+	Patterns map[string]string `yaml:"patterns"`
+	// BlockedDomains may not be linked to
+	BlockedDomains []string `yaml:"blocked_domains"`
+}
+
+// DefaultLists are used when no source is configured
+func DefaultLists() *Lists {
+	return &Lists{
+		StopWords: map[string][]string{
+			"en": {"spam", "abuse", "hate", "violence"},
+		},
+	}
+}
+
+// Source loads the lists
+type Source interface {
+	Load(ctx context.Context) (*Lists, error)
+}
+
+// StaticSource always returns the same lists
+type StaticSource struct {
+	Lists *Lists
+}
+
+func (s StaticSource) Load(context.Context) (*Lists, error) {
+	return s.Lists, nil
+}
+
+// FileSource reads the lists from a YAML file:
+//
+//	stop_words:
+//	  en: [spam, scam]
+//	  ru: [спам, мошенник]
+//	patterns:
+//	  phone: '\+?\d[\d\s()-]{9,}\d'
+//	blocked_domains: [bit.ly]
+type FileSource struct {
+	Path string
+}
+
+func (s FileSource) Load(context.Context) (*Lists, error) {
+	data, err := os.ReadFile(s.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read moderation lists: %w", err)
+	}
+	var lists Lists
+	if err := yaml.Unmarshal(data, &lists); err != nil {
+		return nil, fmt.Errorf("failed to parse moderation lists %s: %w", s.Path, err)
+	}
+	return &lists, nil
+}
+
+// Kinds of rows in tbl_moderation_list
+const (
+	KindStopWord      = "stop_word"
+	KindPattern       = "pattern"
+	KindBlockedDomain = "blocked_domain"
+)
+
+// CassandraSource reads the lists from tbl_moderation_list. A row is a stop
+// word (scope is the language), a pattern (entry is the name, value the
+// expression) or a blocked domain.
+type CassandraSource struct {
+	Session *gocql.Session
+}
+
+func (s CassandraSource) Load(ctx context.Context) (*Lists, error) {
+	lists := &Lists{StopWords: map[string][]string{}, Patterns: map[string]string{}}
+
+	iter := s.Session.Query(`SELECT kind, scope, entry, value FROM tbl_moderation_list`).WithContext(ctx).Iter()
+	var kind, scope, entry, value string
+	for iter.Scan(&kind, &scope, &entry, &value) {
+		switch kind {
+		case KindStopWord:
+			lists.StopWords[scope] = append(lists.StopWords[scope], entry)
+		case KindPattern:
+			lists.Patterns[entry] = value
+		case KindBlockedDomain:
+			lists.BlockedDomains = append(lists.BlockedDomains, entry)
+		}
+	}
+	if err := iter.Close(); err != nil {
+		return nil, fmt.Errorf("failed to read moderation lists: %w", err)
+	}
+	return lists, nil
+}
@@ -0,0 +1,48 @@
+// Package moderation decides whether a discussion message is approved or
+// declined. A Pipeline runs the message through a chain of rules, the first
+// rule that objects declines it and is recorded on the message. The rules
+// take their word lists, patterns and domains from a Source that is polled,
+// so the lists can be changed without a restart.
+package moderation
+
+import (
+	"RESTAPI/internal/discussion/model"
+	"context"
+)
+
+// Verdict is the outcome of moderating a message
+type Verdict struct {
+	State model.MessageState
+	// Rule names the rule that declined the message, e.g. "stop-words/en"
+	Rule string
+	// Reason explains the decision in a few words; it may quote the message,
+	// so it is logged as content, see logging.Content
+	Reason string
+}
+
+// Moderator decides on messages
+type Moderator interface {
+	Moderate(ctx context.Context, message *model.Message) Verdict
+}
+
+// Rule is one check of the chain
+type Rule interface {
+	// Name identifies the rule in verdicts and metrics
+	Name() string
+	// Check returns a reason and true if the message has to be declined
+	Check(ctx context.Context, message *model.Message, text *Text) (string, bool)
+}
+
+// Chain is a Moderator running its rules in order until one declines
+type Chain []Rule
+
+// Moderate approves the message unless a rule declines it
+func (c Chain) Moderate(ctx context.Context, message *model.Message) Verdict {
+	text := NewText(message.Content)
+	for _, rule := range c {
+		if reason, declined := rule.Check(ctx, message, text); declined {
+			return Verdict{State: model.StateDecline, Rule: rule.Name(), Reason: reason}
+		}
+	}
+	return Verdict{State: model.StateApprove}
+}
@@ -0,0 +1,155 @@
+package moderation
+
+import (
+	"RESTAPI/internal/discussion/model"
+	"context"
+	"log/slog"
+	"reflect"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Settings are the limits of the heuristic rules
+type Settings struct {
+	// MaxLinks is the number of links a message may contain; zero allows any
+	MaxLinks int
+	// MaxRepeatedChars is the longest run of one character; zero disables the check
+	MaxRepeatedChars int
+	// MaxUpperRatio is the highest share of capital letters; zero disables the check
+	MaxUpperRatio float64
+	// RateLimit is the number of messages an author may write per RateWindow; zero disables the rule
+	RateLimit  int
+	RateWindow time.Duration
+}
+
+// Pipeline is the Moderator of the service. Its chain is rebuilt whenever
+// the lists of its source change; the rate rule is kept across rebuilds so
+// reloading does not reset the counts.
+type Pipeline struct {
+	source   Source
+	settings Settings
+	rate     *Rate
+
+	chain atomic.Pointer[Chain]
+	lists *Lists
+
+	stopOnce sync.Once
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+// NewPipeline loads the lists and builds the chain; it fails if the lists
+// cannot be loaded or contain an invalid pattern
+func NewPipeline(ctx context.Context, source Source, settings Settings) (*Pipeline, error) {
+	p := &Pipeline{source: source, settings: settings, done: make(chan struct{})}
+	if settings.RateLimit > 0 {
+		p.rate = NewRate(settings.RateLimit, settings.RateWindow)
+	}
+	if _, err := p.Reload(ctx); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Moderate runs the message through the current chain
+func (p *Pipeline) Moderate(ctx context.Context, message *model.Message) Verdict {
+	return p.chain.Load().Moderate(ctx, message)
+}
+
+// Reload loads the lists and rebuilds the chain if they changed. It reports
+// whether the chain was rebuilt; on error the current chain stays in use.
+func (p *Pipeline) Reload(ctx context.Context) (bool, error) {
+	lists, err := p.source.Load(ctx)
+	if err != nil {
+		return false, err
+	}
+	if p.lists != nil && reflect.DeepEqual(p.lists, lists) {
+		return false, nil
+	}
+
+	chain, err := p.build(lists)
+	if err != nil {
+		return false, err
+	}
+	p.chain.Store(&chain)
+	p.lists = lists
+	return true, nil
+}
+
+// build orders the rules from the cheapest to the most expensive. The rate
+// rule goes first so that every message of an author is counted.
+func (p *Pipeline) build(lists *Lists) (Chain, error) {
+	var chain Chain
+	if p.rate != nil {
+		chain = append(chain, p.rate)
+	}
+	chain = append(chain,
+		NewSpam(p.settings.MaxRepeatedChars, p.settings.MaxUpperRatio),
+		NewLinks(p.settings.MaxLinks, lists.BlockedDomains))
+
+	for _, language := range sortedKeys(lists.StopWords) {
+		chain = append(chain, NewStopWords(language, lists.StopWords[language]))
+	}
+	for _, name := range sortedKeys(lists.Patterns) {
+		pattern, err := NewPattern(name, lists.Patterns[name])
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, pattern)
+	}
+	return chain, nil
+}
+
+// Start reloads the lists every interval until Stop
+func (p *Pipeline) Start(interval time.Duration) {
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+
+	go func() {
+		defer close(p.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			reloaded, err := p.Reload(ctx)
+			if err != nil {
+				slog.ErrorContext(ctx, "failed to reload moderation lists", slog.Any("error", err))
+				continue
+			}
+			if reloaded {
+				slog.InfoContext(ctx, "moderation lists reloaded", slog.Int("rules", len(*p.chain.Load())))
+			}
+		}
+	}()
+}
+
+// Stop stops reloading
+func (p *Pipeline) Stop(ctx context.Context) error {
+	p.stopOnce.Do(func() {
+		if p.cancel == nil {
+			return
+		}
+		p.cancel()
+		select {
+		case <-p.done:
+		case <-ctx.Done():
+		}
+	})
+	return nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,228 @@
+package moderation
+
+import (
+	"RESTAPI/internal/discussion/model"
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// StopWords declines messages containing a word of a language's list or one
+// of its forms, e.g. "spammers" for "spam". Words are matched as whole
+// tokens, so "skyscraper" does not match "scrape".
+type StopWords struct {
+	language string
+	words    []string
+	match    matcher
+}
+
+// NewStopWords creates the stop-word rule of a language, e.g. "en" or "ru"
+func NewStopWords(language string, words []string) *StopWords {
+	lower := make([]string, 0, len(words))
+	for _, word := range words {
+		if word = strings.ToLower(strings.TrimSpace(word)); word != "" {
+			lower = append(lower, word)
+		}
+	}
+	return &StopWords{language: language, words: lower, match: matcherFor(language)}
+}
+
+func (r *StopWords) Name() string { return "stop-words/" + r.language }
+
+func (r *StopWords) Check(_ context.Context, _ *model.Message, text *Text) (string, bool) {
+	for _, token := range text.Tokens {
+		for _, word := range r.words {
+			if r.match(token, word) {
+				return fmt.Sprintf("contains %q", word), true
+			}
+		}
+	}
+	return "", false
+}
+
+// Pattern declines messages matching a regular expression
+type Pattern struct {
+	name string
+	re   *regexp.Regexp
+}
+
+// NewPattern compiles a pattern rule; the expression is matched against the original content
+func NewPattern(name, expr string) (*Pattern, error) {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %w", name, err)
+	}
+	return &Pattern{name: name, re: re}, nil
+}
+
+func (r *Pattern) Name() string { return "pattern/" + r.name }
+
+func (r *Pattern) Check(_ context.Context, _ *model.Message, text *Text) (string, bool) {
+	if r.re.MatchString(text.Raw) {
+		return "matches " + r.name, true
+	}
+	return "", false
+}
+
+// linkPattern finds links with a scheme or starting with www.
+var linkPattern = regexp.MustCompile(`(?i)\b(?:https?://|www\.)[^\s<>"]+`)
+
+// Links declines messages with too many links or a link to a blocked domain.
+// A blocked domain also blocks its subdomains.
+type Links struct {
+	max     int
+	blocked []string
+}
+
+// NewLinks creates the link rule; max is the number of links allowed, zero allows any
+func NewLinks(max int, blocked []string) *Links {
+	domains := make([]string, 0, len(blocked))
+	for _, domain := range blocked {
+		if domain = strings.Trim(strings.ToLower(strings.TrimSpace(domain)), "."); domain != "" {
+			domains = append(domains, domain)
+		}
+	}
+	return &Links{max: max, blocked: domains}
+}
+
+func (r *Links) Name() string { return "links" }
+
+func (r *Links) Check(_ context.Context, _ *model.Message, text *Text) (string, bool) {
+	links := linkPattern.FindAllString(text.Lower, -1)
+	if r.max > 0 && len(links) > r.max {
+		return fmt.Sprintf("%d links, at most %d allowed", len(links), r.max), true
+	}
+	for _, link := range links {
+		host := hostOf(link)
+		for _, domain := range r.blocked {
+			if host == domain || strings.HasSuffix(host, "."+domain) {
+				return "links to " + domain, true
+			}
+		}
+	}
+	return "", false
+}
+
+func hostOf(link string) string {
+	if !strings.Contains(link, "://") {
+		link = "http://" + link
+	}
+	u, err := url.Parse(link)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(u.Hostname(), "www.")
+}
+
+// spamMinLetters is the length below which the share of capitals is not checked
+const spamMinLetters = 20
+
+// Spam declines messages that shout or stretch characters, e.g. "BUY NOW!!!!!!!!!!"
+type Spam struct {
+	maxRepeated   int
+	maxUpperRatio float64
+}
+
+// NewSpam creates the spam rule. maxRepeated is the longest allowed run of
+// one character and maxUpperRatio the highest allowed share of capital
+// letters; zero disables a check.
+func NewSpam(maxRepeated int, maxUpperRatio float64) *Spam {
+	return &Spam{maxRepeated: maxRepeated, maxUpperRatio: maxUpperRatio}
+}
+
+func (r *Spam) Name() string { return "spam" }
+
+func (r *Spam) Check(_ context.Context, _ *model.Message, text *Text) (string, bool) {
+	var (
+		prev            rune
+		run             int
+		letters, uppers int
+	)
+	for _, c := range text.Raw {
+		if c == prev {
+			run++
+		} else {
+			prev, run = c, 1
+		}
+		if r.maxRepeated > 0 && run > r.maxRepeated && !unicode.IsSpace(c) {
+			return fmt.Sprintf("character repeated more than %d times", r.maxRepeated), true
+		}
+		if unicode.IsLetter(c) {
+			letters++
+			if unicode.IsUpper(c) {
+				uppers++
+			}
+		}
+	}
+	if r.maxUpperRatio > 0 && letters >= spamMinLetters && float64(uppers)/float64(letters) > r.maxUpperRatio {
+		return "too many capital letters", true
+	}
+	return "", false
+}
+
+// Rate declines messages of an author who has already written limit
+// messages within the window. Messages are counted in memory, so every
+// instance of the service limits on its own; messages without an author
+// are not limited.
+type Rate struct {
+	limit  int
+	window time.Duration
+	now    func() time.Time
+
+	mu      sync.Mutex
+	written map[int64][]time.Time
+	swept   time.Time
+}
+
+// NewRate creates the rate rule
+func NewRate(limit int, window time.Duration) *Rate {
+	return &Rate{limit: limit, window: window, now: time.Now, written: make(map[int64][]time.Time)}
+}
+
+func (r *Rate) Name() string { return "rate" }
+
+// Check counts every message it sees, declined ones included
+func (r *Rate) Check(_ context.Context, message *model.Message, _ *Text) (string, bool) {
+	if message.WriterID == 0 {
+		return "", false
+	}
+	now := r.now()
+	since := now.Add(-r.window)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if now.Sub(r.swept) > r.window {
+		r.sweep(since)
+		r.swept = now
+	}
+
+	times := recent(r.written[message.WriterID], since)
+	r.written[message.WriterID] = append(times, now)
+	if len(times) >= r.limit {
+		return fmt.Sprintf("more than %d messages in %s", r.limit, r.window), true
+	}
+	return "", false
+}
+
+// sweep forgets the authors who wrote nothing since the given time
+func (r *Rate) sweep(since time.Time) {
+	for writer, times := range r.written {
+		if len(recent(times, since)) == 0 {
+			delete(r.written, writer)
+		}
+	}
+}
+
+// recent drops the times not after since from the ordered times
+func recent(times []time.Time, since time.Time) []time.Time {
+	i := 0
+	for i < len(times) && !times[i].After(since) {
+		i++
+	}
+	return times[i:]
+}
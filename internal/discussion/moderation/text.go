@@ -0,0 +1,107 @@
+package moderation
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Text is the content of a message prepared for the rules
+type Text struct {
+	Raw   string
+	Lower string
+	// Tokens are the lower-case words and numbers of the content
+	Tokens []string
+}
+
+// NewText splits content into tokens at everything but letters and digits
+func NewText(content string) *Text {
+	lower := strings.ToLower(content)
+	return &Text{
+		Raw:   content,
+		Lower: lower,
+		Tokens: strings.FieldsFunc(lower, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		}),
+	}
+}
+
+// matcher reports whether a lower-case token is a form of a lower-case stop word
+type matcher func(token, word string) bool
+
+// matchers hold the word forms known per language; other languages match whole words only
+var matchers = map[string]matcher{
+	"en": matchEnglish,
+	"ru": matchRussian,
+}
+
+func matcherFor(language string) matcher {
+	if m, ok := matchers[language]; ok {
+		return m
+	}
+	return func(token, word string) bool { return token == word }
+}
+
+var (
+	// englishSuffixes may follow the stop word as it is: spam → spams, spammy
+	englishSuffixes = []string{"s", "es", "ed", "er", "ers", "ing", "ings", "y", "ly"}
+	// englishVowelSuffixes replace a final e (abuse → abusing) or follow a
+	// doubled final consonant (spam → spamming, spammer)
+	englishVowelSuffixes = []string{"ed", "er", "ers", "ing", "ings", "y"}
+)
+
+// matchEnglish accepts the stop word and its regular inflections
+func matchEnglish(token, word string) bool {
+	if token == word {
+		return true
+	}
+	if rest, ok := strings.CutPrefix(token, word); ok && contains(englishSuffixes, rest) {
+		return true
+	}
+
+	last := word[len(word)-1]
+	if last == 'e' {
+		rest, ok := strings.CutPrefix(token, word[:len(word)-1])
+		return ok && contains(englishVowelSuffixes, rest)
+	}
+	if !strings.ContainsRune("aeiouy", rune(last)) {
+		rest, ok := strings.CutPrefix(token, word+string(last))
+		return ok && contains(englishVowelSuffixes, rest)
+	}
+	return false
+}
+
+// russianEndings are the case and number endings of nouns and adjectives, longest first
+var russianEndings = []string{
+	"иями", "ями", "ами", "ого", "его", "ому", "ему", "ыми", "ими",
+	"ов", "ев", "ей", "ой", "ий", "ый", "ая", "яя", "ое", "ее", "ые", "ие",
+	"ам", "ям", "ах", "ях", "ом", "ем", "ую", "юю",
+	"а", "я", "о", "е", "ы", "и", "у", "ю", "ь",
+}
+
+// matchRussian strips the ending of the stop word and accepts its stem with any known ending
+func matchRussian(token, word string) bool {
+	if token == word {
+		return true
+	}
+	stem := russianStem(word)
+	rest, ok := strings.CutPrefix(token, stem)
+	return ok && (rest == "" || contains(russianEndings, rest))
+}
+
+func russianStem(word string) string {
+	for _, ending := range russianEndings {
+		if stem, ok := strings.CutSuffix(word, ending); ok && len([]rune(stem)) >= 3 {
+			return stem
+		}
+	}
+	return word
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
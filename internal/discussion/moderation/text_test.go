@@ -0,0 +1,97 @@
+package moderation
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestNewText(t *testing.T) {
+	tests := []struct {
+		content string
+		want    []string
+	}{
+		{"Hello, World!", []string{"hello", "world"}},
+		{"  ", nil},
+		{"Привет, МИР", []string{"привет", "мир"}},
+		{"e-mail:me@example.com", []string{"e", "mail", "me", "example", "com"}},
+	}
+	for _, tt := range tests {
+		got := NewText(tt.content).Tokens
+		if len(got) == 0 && len(tt.want) == 0 {
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("NewText(%q).Tokens = %q, want %q", tt.content, got, tt.want)
+		}
+	}
+}
+
+func TestMatchEnglish(t *testing.T) {
+	tests := []struct {
+		token, word string
+		want        bool
+	}{
+		{"spam", "spam", true},
+		{"spams", "spam", true},
+		{"spamming", "spam", true},
+		{"skyspam", "spam", false},
+		{"spa", "spam", false},
+		{"abusing", "abuse", true},
+		{"asses", "ass", true},
+		{"classification", "ass", false},
+	}
+	for _, tt := range tests {
+		if got := matchEnglish(tt.token, tt.word); got != tt.want {
+			t.Errorf("matchEnglish(%q, %q) = %v, want %v", tt.token, tt.word, got, tt.want)
+		}
+	}
+}
+
+func TestMatchRussian(t *testing.T) {
+	tests := []struct {
+		token, word string
+		want        bool
+	}{
+		{"реклама", "реклама", true},
+		{"рекламу", "реклама", true},
+		{"рекламщик", "реклама", false},
+		{"антиреклама", "реклама", false},
+		{"спамом", "спам", true},
+		{"спамер", "спам", false},
+		{"оскорблениями", "оскорбление", true},
+	}
+	for _, tt := range tests {
+		if got := matchRussian(tt.token, tt.word); got != tt.want {
+			t.Errorf("matchRussian(%q, %q) = %v, want %v", tt.token, tt.word, got, tt.want)
+		}
+	}
+}
+
+func TestStopWords(t *testing.T) {
+	tests := []struct {
+		name     string
+		language string
+		words    []string
+		content  string
+		want     bool
+	}{
+		{"whole word", "en", []string{"spam"}, "This is spam.", true},
+		{"inflection", "en", []string{"spam"}, "Stop spamming here", true},
+		{"part of a hyphenated word", "en", []string{"spam"}, "a spam-bot again", true},
+		{"inside a word", "en", []string{"spam"}, "Skyspam is a band", false},
+		{"no stop word", "en", []string{"spam", "hate"}, "Nice article, thanks", false},
+		{"russian ending", "ru", []string{"реклама"}, "Хватит рекламы", true},
+		{"other language matches whole words only", "de", []string{"spam"}, "spams", false},
+		{"blank words are ignored", "en", []string{" ", ""}, "anything", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := NewStopWords(tt.language, tt.words)
+			_, got := rule.Check(context.Background(), nil, NewText(tt.content))
+			if got != tt.want {
+				t.Fatalf("Check(%q) declined = %v, want %v", tt.content, got, tt.want)
+			}
+		})
+	}
+}
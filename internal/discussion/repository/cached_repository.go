@@ -0,0 +1,183 @@
+package repository
+
+import (
+	"RESTAPI/internal/discussion/cache"
+	"RESTAPI/internal/discussion/model"
+	"RESTAPI/internal/metrics"
+	"context"
+	"encoding/json"
+	"fmt"
+	"golang.org/x/sync/singleflight"
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+// CachedMessageRepository is a read-through cache in front of a MessageRepository.
+//
+// Messages by ID and the lists of messages of a news item are kept for the
+// TTL; concurrent misses of one key share a single query. Writes through the
+// repository drop the entries they affect, and Invalidate drops a message
+// whose moderation result was published to message-out, which is how
+// messages saved by the Kafka consumer of any instance become visible.
+// Updates and deletes are not published: with a shared cache such as Redis
+// the dropped entries are gone for every instance, but an in-process cache
+// of another instance keeps serving the old message until the TTL expires.
+// Pages, counts and the full list are not cached.
+type CachedMessageRepository struct {
+	next  MessageRepository
+	cache cache.Cache
+	ttl   time.Duration
+	group singleflight.Group
+	// generation counts the invalidations, so that a load that overlapped
+	// one does not leave what it read before the write in the cache
+	generation atomic.Uint64
+}
+
+// NewCachedMessageRepository wraps next with a cache
+func NewCachedMessageRepository(next MessageRepository, cache cache.Cache, ttl time.Duration) *CachedMessageRepository {
+	return &CachedMessageRepository{next: next, cache: cache, ttl: ttl}
+}
+
+// Kinds of cache entries, also the label of the cache metrics
+const (
+	cacheKindMessage = "message"
+	cacheKindNews    = "news"
+)
+
+func messageKey(id int64) string {
+	return fmt.Sprintf("message:%d", id)
+}
+
+func newsKey(newsID int64) string {
+	return fmt.Sprintf("news:%d:messages", newsID)
+}
+
+func (r *CachedMessageRepository) Create(ctx context.Context, message *model.Message) error {
+	if err := r.next.Create(ctx, message); err != nil {
+		return err
+	}
+	r.drop(ctx, messageKey(message.ID), newsKey(message.NewsID))
+	return nil
+}
+
+func (r *CachedMessageRepository) FindByID(ctx context.Context, id int64) (*model.Message, error) {
+	return readThrough(ctx, r, cacheKindMessage, messageKey(id), func(ctx context.Context) (*model.Message, error) {
+		return r.next.FindByID(ctx, id)
+	})
+}
+
+func (r *CachedMessageRepository) FindByNewsID(ctx context.Context, newsID int64) ([]*model.Message, error) {
+	return readThrough(ctx, r, cacheKindNews, newsKey(newsID), func(ctx context.Context) ([]*model.Message, error) {
+		return r.next.FindByNewsID(ctx, newsID)
+	})
+}
+
+func (r *CachedMessageRepository) FindPageByNewsID(ctx context.Context, newsID int64, pageState []byte, limit int, desc bool) ([]*model.Message, []byte, error) {
+	return r.next.FindPageByNewsID(ctx, newsID, pageState, limit, desc)
+}
+
+func (r *CachedMessageRepository) FindPage(ctx context.Context, pageState []byte, limit int) ([]*model.Message, []byte, error) {
+	return r.next.FindPage(ctx, pageState, limit)
+}
+
+func (r *CachedMessageRepository) CountByNewsID(ctx context.Context, newsID int64) (int64, error) {
+	return r.next.CountByNewsID(ctx, newsID)
+}
+
+func (r *CachedMessageRepository) FindAll(ctx context.Context) ([]*model.Message, error) {
+	return r.next.FindAll(ctx)
+}
+
+// Update drops the message and the lists of the news it left and joined
+func (r *CachedMessageRepository) Update(ctx context.Context, message *model.Message) (int64, error) {
+	previousNewsID, err := r.next.Update(ctx, message)
+	if err != nil {
+		return 0, err
+	}
+
+	keys := []string{messageKey(message.ID), newsKey(message.NewsID)}
+	if previousNewsID != message.NewsID {
+		keys = append(keys, newsKey(previousNewsID))
+	}
+	r.drop(ctx, keys...)
+	return previousNewsID, nil
+}
+
+// Delete drops the message and the list of its news
+func (r *CachedMessageRepository) Delete(ctx context.Context, id int64) (int64, error) {
+	newsID, err := r.next.Delete(ctx, id)
+	if err != nil {
+		return 0, err
+	}
+	r.drop(ctx, messageKey(id), newsKey(newsID))
+	return newsID, nil
+}
+
+// Invalidate drops the cached message and the list of its news
+func (r *CachedMessageRepository) Invalidate(ctx context.Context, message *model.Message) {
+	r.drop(ctx, messageKey(message.ID), newsKey(message.NewsID))
+}
+
+// readThrough returns the cached value of key or loads and caches it. A
+// failing cache is bypassed, so it slows requests down but does not fail them.
+func readThrough[T any](ctx context.Context, r *CachedMessageRepository, kind, key string, load func(ctx context.Context) (T, error)) (T, error) {
+	data, found, err := r.cache.Get(ctx, key)
+	switch {
+	case err != nil:
+		metrics.ObserveCacheLookup(kind, "error")
+		slog.WarnContext(ctx, "failed to read cache", slog.String("key", key), slog.Any("error", err))
+	case found:
+		var value T
+		if err := json.Unmarshal(data, &value); err == nil {
+			metrics.ObserveCacheLookup(kind, "hit")
+			return value, nil
+		}
+		metrics.ObserveCacheLookup(kind, "error")
+		slog.WarnContext(ctx, "failed to decode cache entry", slog.String("key", key), slog.Any("error", err))
+	default:
+		metrics.ObserveCacheLookup(kind, "miss")
+	}
+
+	// Callers missing the same key wait for the first one's query. It runs
+	// without the first caller's cancellation, which would fail the others too.
+	loadCtx := context.WithoutCancel(ctx)
+	value, err, _ := r.group.Do(key, func() (interface{}, error) {
+		generation := r.generation.Load()
+		value, err := load(loadCtx)
+		if err != nil {
+			return value, err
+		}
+		// If this instance invalidated entries while the value was read or
+		// stored, the value may predate the write and is dropped again
+		r.store(loadCtx, key, value)
+		if r.generation.Load() != generation {
+			if err := r.cache.Delete(loadCtx, key); err != nil {
+				slog.WarnContext(ctx, "failed to invalidate cache", slog.String("key", key), slog.Any("error", err))
+			}
+		}
+		return value, nil
+	})
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return value.(T), nil
+}
+
+func (r *CachedMessageRepository) store(ctx context.Context, key string, value interface{}) {
+	data, err := json.Marshal(value)
+	if err == nil {
+		err = r.cache.Set(ctx, key, data, r.ttl)
+	}
+	if err != nil {
+		slog.WarnContext(ctx, "failed to write cache", slog.String("key", key), slog.Any("error", err))
+	}
+}
+
+func (r *CachedMessageRepository) drop(ctx context.Context, keys ...string) {
+	r.generation.Add(1)
+	if err := r.cache.Delete(ctx, keys...); err != nil {
+		slog.WarnContext(ctx, "failed to invalidate cache", slog.Any("keys", keys), slog.Any("error", err))
+	}
+}
@@ -2,30 +2,86 @@ package repository
 
 import (
 	"RESTAPI/internal/discussion/model"
+	"RESTAPI/internal/idgen"
 	"context"
+	"errors"
 	"fmt"
 	"github.com/gocql/gocql"
-	"log"
+	"log/slog"
 )
 
+// ErrNotFound is returned when the requested message does not exist
+var ErrNotFound = errors.New("message not found")
+
 // MessageRepository defines the interface for message storage operations
 type MessageRepository interface {
 	Create(ctx context.Context, message *model.Message) error
 	FindAll(ctx context.Context) ([]*model.Message, error)
 	FindByID(ctx context.Context, id int64) (*model.Message, error)
 	FindByNewsID(ctx context.Context, newsID int64) ([]*model.Message, error)
-	Update(ctx context.Context, message *model.Message) error
-	Delete(ctx context.Context, id int64) error
+	FindPageByNewsID(ctx context.Context, newsID int64, pageState []byte, limit int, desc bool) ([]*model.Message, []byte, error)
+	FindPage(ctx context.Context, pageState []byte, limit int) ([]*model.Message, []byte, error)
+	CountByNewsID(ctx context.Context, newsID int64) (int64, error)
+	// Update and Delete return the news ID the message had before, as told
+	// by the write itself, so callers need not read the message first
+	Update(ctx context.Context, message *model.Message) (previousNewsID int64, err error)
+	Delete(ctx context.Context, id int64) (newsID int64, err error)
 }
 
-// CassandraMessageRepository implements MessageRepository using Cassandra
+// maxIDAttempts bounds how often Create draws a new ID when a generated one is taken
+const maxIDAttempts = 3
+
+// maxMoveAttempts bounds how often Update and Delete retry when the news of a
+// message changes concurrently
+const maxMoveAttempts = 3
+
+// CassandraMessageRepository implements MessageRepository using Cassandra.
+//
+// tbl_message holds the messages by ID and is the source of truth; its writes
+// are lightweight transactions. messages_by_news holds the same columns
+// partitioned by news and ordered by ID for the queries by news. It is written
+// after tbl_message, since a conditional statement cannot share a batch with
+// another table; statements touching several of its rows are sent as logged
+// batches so that all of them apply.
 type CassandraMessageRepository struct {
-	session *gocql.Session
+	session     *gocql.Session
+	ids         *idgen.Generator
+	consistency Consistency
+}
+
+// Consistency holds the consistency levels per kind of statement
+type Consistency struct {
+	// Read is used by queries
+	Read gocql.Consistency
+	// Write is used by writes and batches, and by the commit of lightweight transactions
+	Write gocql.Consistency
+	// Serial is used by the Paxos round of lightweight transactions
+	Serial gocql.SerialConsistency
+}
+
+// NewCassandraMessageRepository creates a new CassandraMessageRepository;
+// ids generates the IDs of messages created without one
+func NewCassandraMessageRepository(session *gocql.Session, ids *idgen.Generator, consistency Consistency) *CassandraMessageRepository {
+	return &CassandraMessageRepository{session: session, ids: ids, consistency: consistency}
 }
 
-// NewCassandraMessageRepository creates a new CassandraMessageRepository
-func NewCassandraMessageRepository(session *gocql.Session) *CassandraMessageRepository {
-	return &CassandraMessageRepository{session: session}
+// read prepares a query bound to ctx with the read consistency
+func (r *CassandraMessageRepository) read(ctx context.Context, stmt string, values ...interface{}) *gocql.Query {
+	return r.session.Query(stmt, values...).WithContext(ctx).Consistency(r.consistency.Read)
+}
+
+// write prepares a write bound to ctx with the write and serial consistencies
+func (r *CassandraMessageRepository) write(ctx context.Context, stmt string, values ...interface{}) *gocql.Query {
+	return r.session.Query(stmt, values...).WithContext(ctx).
+		Consistency(r.consistency.Write).
+		SerialConsistency(r.consistency.Serial)
+}
+
+// batch prepares a logged batch bound to ctx with the write consistency
+func (r *CassandraMessageRepository) batch(ctx context.Context) *gocql.Batch {
+	batch := r.session.NewBatch(gocql.LoggedBatch).WithContext(ctx)
+	batch.SetConsistency(r.consistency.Write)
+	return batch
 }
 
 // validateState checks if the given state is valid
@@ -40,23 +96,7 @@ func validateState(state string) error {
 
 // Create inserts a new message into Cassandra
 func (r *CassandraMessageRepository) Create(ctx context.Context, message *model.Message) error {
-	log.Printf("Creating message with ID: %d, NewsID: %d, Content: %s, State: %s",
-		message.ID, message.NewsID, message.Content, message.State)
-
-	// If ID is not set, generate a new one
-	if message.ID == 0 {
-		// Get the maximum ID from the table
-		var maxID int64
-		err := r.session.Query(`
-			SELECT MAX(id) FROM tbl_message
-			USING CONSISTENCY QUORUM`).
-			WithContext(ctx).Scan(&maxID)
-		if err != nil && err != gocql.ErrNotFound {
-			log.Printf("Error getting max ID: %v", err)
-			return fmt.Errorf("failed to get max ID: %v", err)
-		}
-		message.ID = maxID + 1
-	}
+	slog.DebugContext(ctx, "creating message", slog.Any("message", message))
 
 	// Ensure newsId is set
 	if message.NewsID == 0 {
@@ -71,224 +111,364 @@ func (r *CassandraMessageRepository) Create(ctx context.Context, message *model.
 		return err
 	}
 
-	// Use INSERT IF NOT EXISTS to prevent race conditions
-	applied, err := r.session.Query(`
-		INSERT INTO tbl_message (id, newsid, country, content, state)
-		VALUES (?, ?, ?, ?, ?)
-		IF NOT EXISTS
-		USING CONSISTENCY QUORUM`,
-		message.ID, message.NewsID, message.Country, message.Content, message.State).
-		WithContext(ctx).ScanCAS()
-	if err != nil {
-		log.Printf("Error creating message: %v", err)
-		return fmt.Errorf("failed to create message: %v", err)
-	}
-	if !applied {
-		log.Printf("Message with ID %d already exists", message.ID)
-		return fmt.Errorf("message with ID %d already exists", message.ID)
-	}
+	// Messages from the publisher keep its ID; others get a generated one.
+	// Generated IDs are unique per node, so a taken one means two instances
+	// share a node ID; a new ID is drawn instead of failing.
+	generated := message.ID == 0
+	for attempt := 1; ; attempt++ {
+		if generated {
+			message.ID = r.ids.Next()
+		}
 
-	// Verify the message was created
-	created, err := r.FindByID(ctx, message.ID)
-	if err != nil {
-		log.Printf("Error verifying message creation: %v", err)
-		return fmt.Errorf("failed to verify message creation: %v", err)
+		// Use INSERT IF NOT EXISTS to prevent race conditions
+		existing := map[string]interface{}{}
+		applied, err := r.write(ctx, `
+			INSERT INTO tbl_message (id, newsid, country, content, state, decline_rule)
+			VALUES (?, ?, ?, ?, ?, ?)
+			IF NOT EXISTS`,
+			message.ID, message.NewsID, message.Country, message.Content, message.State, message.DeclineRule).
+			MapScanCAS(existing)
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to create message", slog.Int64("message_id", message.ID), slog.Any("error", err))
+			return fmt.Errorf("failed to create message: %v", err)
+		}
+		if applied {
+			break
+		}
+		// The same message means an earlier attempt stopped before
+		// messages_by_news; the stored row is what the copy has to hold
+		if sameMessage(existing, message) {
+			storedValues(existing, message)
+			break
+		}
+		if !generated || attempt == maxIDAttempts {
+			slog.WarnContext(ctx, "message already exists", slog.Int64("message_id", message.ID))
+			return fmt.Errorf("message with ID %d already exists", message.ID)
+		}
+		slog.WarnContext(ctx, "generated message ID is taken, check the node IDs", slog.Int64("message_id", message.ID))
 	}
-	if created == nil {
-		log.Printf("Message with ID %d was not created", message.ID)
-		return fmt.Errorf("message with ID %d was not created", message.ID)
+
+	if err := r.write(ctx, insertByNews, byNewsValues(message)...).Exec(); err != nil {
+		slog.ErrorContext(ctx, "failed to index message by news", slog.Int64("message_id", message.ID), slog.Any("error", err))
+		return fmt.Errorf("failed to create message: %v", err)
 	}
 
-	// Log successful creation
-	log.Printf("Successfully created message with ID: %d, NewsID: %d, Content: %s, State: %s",
-		message.ID, message.NewsID, message.Content, message.State)
+	slog.DebugContext(ctx, "created message", slog.Any("message", message))
 
 	return nil
 }
 
+// insertByNews writes a row of messages_by_news; byNewsValues returns its values
+const insertByNews = `
+	INSERT INTO messages_by_news (newsid, id, country, content, state, decline_rule)
+	VALUES (?, ?, ?, ?, ?, ?)`
+
+func byNewsValues(message *model.Message) []interface{} {
+	return []interface{}{message.NewsID, message.ID, message.Country, message.Content, message.State, message.DeclineRule}
+}
+
+// sameMessage reports whether the row returned by a failed INSERT IF NOT
+// EXISTS holds the message that was being inserted
+func sameMessage(row map[string]interface{}, message *model.Message) bool {
+	newsID, _ := row["newsid"].(int64)
+	content, _ := row["content"].(string)
+	return newsID == message.NewsID && content == message.Content
+}
+
+// storedValues copies the columns returned by a failed INSERT IF NOT EXISTS into message
+func storedValues(row map[string]interface{}, message *model.Message) {
+	message.Country, _ = row["country"].(string)
+	state, _ := row["state"].(string)
+	message.State = model.MessageState(state)
+	message.DeclineRule, _ = row["decline_rule"].(string)
+}
+
 // FindByID retrieves a message by its ID
 func (r *CassandraMessageRepository) FindByID(ctx context.Context, id int64) (*model.Message, error) {
-	log.Printf("Finding message by ID: %d", id)
+	slog.DebugContext(ctx, "finding message", slog.Int64("message_id", id))
 
 	var message model.Message
-	err := r.session.Query(`
-		SELECT id, newsid, country, content, state
+	err := r.read(ctx, `
+		SELECT id, newsid, country, content, state, decline_rule
 		FROM tbl_message
 		WHERE id = ?
-		USING CONSISTENCY QUORUM
-	`, id).Scan(&message.ID, &message.NewsID, &message.Country, &message.Content, &message.State)
+	`, id).Scan(&message.ID, &message.NewsID, &message.Country, &message.Content, &message.State, &message.DeclineRule)
 
 	if err != nil {
 		if err == gocql.ErrNotFound {
-			log.Printf("Message with ID %d not found", id)
-			return nil, fmt.Errorf("message with ID %d not found", id)
+			slog.DebugContext(ctx, "message not found", slog.Int64("message_id", id))
+			return nil, fmt.Errorf("%w: ID %d", ErrNotFound, id)
 		}
-		log.Printf("Error finding message by ID %d: %v", id, err)
+		slog.ErrorContext(ctx, "failed to find message", slog.Int64("message_id", id), slog.Any("error", err))
 		return nil, fmt.Errorf("failed to retrieve message: %v", err)
 	}
 
-	log.Printf("Found message with ID: %d, NewsID: %d, Content: %s, State: %s",
-		message.ID, message.NewsID, message.Content, message.State)
+	slog.DebugContext(ctx, "found message", slog.Any("message", message))
 	return &message, nil
 }
 
-// FindByNewsID retrieves all messages for a specific news item
+// FindByNewsID retrieves all messages for a specific news item in ID order
 func (r *CassandraMessageRepository) FindByNewsID(ctx context.Context, newsID int64) ([]*model.Message, error) {
-	log.Printf("Finding messages by NewsID: %d", newsID)
+	slog.DebugContext(ctx, "finding messages by news", slog.Int64("news_id", newsID))
 
-	iter := r.session.Query(`
-		SELECT id, newsid, country, content, state
-		FROM tbl_message
+	iter := r.read(ctx, `
+		SELECT id, newsid, country, content, state, decline_rule
+		FROM messages_by_news
 		WHERE newsid = ?
-		USING CONSISTENCY QUORUM
 	`, newsID).Iter()
 
 	var messages []*model.Message
 	var message model.Message
 
-	for iter.Scan(&message.ID, &message.NewsID, &message.Country, &message.Content, &message.State) {
+	for iter.Scan(&message.ID, &message.NewsID, &message.Country, &message.Content, &message.State, &message.DeclineRule) {
 		// Create a new message for each iteration to avoid pointer issues
 		msg := model.Message{
-			ID:      message.ID,
-			NewsID:  message.NewsID,
-			Country: message.Country,
-			Content: message.Content,
-			State:   message.State,
+			ID:          message.ID,
+			NewsID:      message.NewsID,
+			Country:     message.Country,
+			Content:     message.Content,
+			State:       message.State,
+			DeclineRule: message.DeclineRule,
 		}
 		messages = append(messages, &msg)
 	}
 
 	if err := iter.Close(); err != nil {
-		log.Printf("Error closing iterator: %v", err)
+		slog.ErrorContext(ctx, "failed to read messages", slog.Any("error", err))
 		return nil, fmt.Errorf("failed to retrieve messages: %v", err)
 	}
 
 	if len(messages) == 0 {
-		log.Printf("No messages found for NewsID: %d", newsID)
+		slog.DebugContext(ctx, "no messages found for news", slog.Int64("news_id", newsID))
 		return []*model.Message{}, nil
 	}
 
-	log.Printf("Found %d messages for NewsID: %d", len(messages), newsID)
+	slog.DebugContext(ctx, "found messages for news", slog.Int64("news_id", newsID), slog.Int("count", len(messages)))
 	return messages, nil
 }
 
-// Update modifies an existing message
-func (r *CassandraMessageRepository) Update(ctx context.Context, message *model.Message) error {
-	log.Printf("Updating message with ID: %d, NewsID: %d, Content: %s, State: %s",
-		message.ID, message.NewsID, message.Content, message.State)
+// FindPageByNewsID retrieves a single page of messages for a news item in ID
+// order, descending if desc is set. pageState is the token returned by the
+// previous call with the same order (nil for the first page); the returned
+// state is empty when there are no more pages.
+func (r *CassandraMessageRepository) FindPageByNewsID(ctx context.Context, newsID int64, pageState []byte, limit int, desc bool) ([]*model.Message, []byte, error) {
+	slog.DebugContext(ctx, "finding page of messages by news", slog.Int64("news_id", newsID), slog.Int("limit", limit))
+
+	stmt := `
+		SELECT id, newsid, country, content, state, decline_rule
+		FROM messages_by_news
+		WHERE newsid = ?`
+	if desc {
+		stmt += ` ORDER BY id DESC`
+	}
+	messages, next, err := r.page(r.read(ctx, stmt, newsID), pageState, limit)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to read page of messages", slog.Int64("news_id", newsID), slog.Any("error", err))
+		return nil, nil, err
+	}
+
+	slog.DebugContext(ctx, "found messages for news", slog.Int64("news_id", newsID), slog.Int("count", len(messages)))
+	return messages, next, nil
+}
+
+// FindPage retrieves a single page of all messages. They come in token
+// order, which is stable but unrelated to IDs or news; pageState works as in
+// FindPageByNewsID.
+func (r *CassandraMessageRepository) FindPage(ctx context.Context, pageState []byte, limit int) ([]*model.Message, []byte, error) {
+	slog.DebugContext(ctx, "finding page of messages", slog.Int("limit", limit))
+
+	messages, next, err := r.page(r.read(ctx, `
+		SELECT id, newsid, country, content, state, decline_rule
+		FROM tbl_message
+	`), pageState, limit)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to read page of messages", slog.Any("error", err))
+		return nil, nil, err
+	}
+
+	slog.DebugContext(ctx, "found messages", slog.Int("count", len(messages)))
+	return messages, next, nil
+}
+
+// page reads one page of a query selecting the message columns
+func (r *CassandraMessageRepository) page(query *gocql.Query, pageState []byte, limit int) ([]*model.Message, []byte, error) {
+	iter := query.PageSize(limit).PageState(pageState).Iter()
+	nextState := iter.PageState()
+
+	messages := make([]*model.Message, 0, limit)
+	scanner := iter.Scanner()
+	for scanner.Next() {
+		var msg model.Message
+		if err := scanner.Scan(&msg.ID, &msg.NewsID, &msg.Country, &msg.Content, &msg.State, &msg.DeclineRule); err != nil {
+			return nil, nil, fmt.Errorf("failed to scan message: %v", err)
+		}
+		messages = append(messages, &msg)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, fmt.Errorf("failed to retrieve messages: %v", err)
+	}
+	return messages, nextState, nil
+}
+
+// CountByNewsID counts the messages of a news item; it reads a single partition
+func (r *CassandraMessageRepository) CountByNewsID(ctx context.Context, newsID int64) (int64, error) {
+	var count int64
+	if err := r.read(ctx, `SELECT COUNT(*) FROM messages_by_news WHERE newsid = ?`, newsID).Scan(&count); err != nil {
+		slog.ErrorContext(ctx, "failed to count messages", slog.Int64("news_id", newsID), slog.Any("error", err))
+		return 0, fmt.Errorf("failed to count messages: %v", err)
+	}
+	return count, nil
+}
+
+// Update modifies an existing message. The update is conditional on the
+// news the stored message belongs to, so that the row in messages_by_news
+// can be found; the message is expected to stay with its news, and if it
+// moves, its row is moved as well. The news the message was in is returned.
+func (r *CassandraMessageRepository) Update(ctx context.Context, message *model.Message) (int64, error) {
+	slog.DebugContext(ctx, "updating message", slog.Any("message", message))
 
 	// Ensure newsId is set
 	if message.NewsID == 0 {
-		return fmt.Errorf("newsId is required")
+		return 0, fmt.Errorf("newsId is required")
 	}
 
 	// Validate state
 	if err := validateState(string(message.State)); err != nil {
-		return err
+		return 0, err
 	}
 
-	// Use UPDATE IF EXISTS to handle race conditions
-	applied, err := r.session.Query(`
-		UPDATE tbl_message
-		SET newsid = ?, country = ?, content = ?, state = ?
-		WHERE id = ?
-		IF EXISTS
-		USING CONSISTENCY QUORUM`,
-		message.NewsID, message.Country, message.Content, message.State, message.ID).
-		WithContext(ctx).ScanCAS()
-	if err != nil {
-		log.Printf("Error updating message: %v", err)
-		return fmt.Errorf("failed to update message: %v", err)
-	}
-	if !applied {
-		log.Printf("Message with ID %d not found", message.ID)
-		return fmt.Errorf("message with ID %d not found", message.ID)
+	// A failed condition returns the stored news, which the next attempt expects
+	storedNewsID := message.NewsID
+	for attempt := 1; ; attempt++ {
+		current := map[string]interface{}{}
+		applied, err := r.write(ctx, `
+			UPDATE tbl_message
+			SET newsid = ?, country = ?, content = ?, state = ?, decline_rule = ?
+			WHERE id = ?
+			IF newsid = ?`,
+			message.NewsID, message.Country, message.Content, message.State, message.DeclineRule, message.ID, storedNewsID).
+			MapScanCAS(current)
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to update message", slog.Int64("message_id", message.ID), slog.Any("error", err))
+			return 0, fmt.Errorf("failed to update message: %v", err)
+		}
+		if applied {
+			break
+		}
+
+		// Stored messages always have a news ID, so none means no message
+		newsID, _ := current["newsid"].(int64)
+		if newsID == 0 {
+			slog.DebugContext(ctx, "message not found", slog.Int64("message_id", message.ID))
+			return 0, fmt.Errorf("%w: ID %d", ErrNotFound, message.ID)
+		}
+		if attempt == maxMoveAttempts {
+			slog.WarnContext(ctx, "message keeps changing during update", slog.Int64("message_id", message.ID))
+			return 0, fmt.Errorf("message with ID %d was changed concurrently", message.ID)
+		}
+		storedNewsID = newsID
 	}
 
-	// Verify the message was updated
-	updated, err := r.FindByID(ctx, message.ID)
-	if err != nil {
-		log.Printf("Error verifying message update: %v", err)
-		return fmt.Errorf("failed to verify message update: %v", err)
+	batch := r.batch(ctx)
+	if storedNewsID != message.NewsID {
+		batch.Query(`DELETE FROM messages_by_news WHERE newsid = ? AND id = ?`, storedNewsID, message.ID)
 	}
-	if updated == nil {
-		log.Printf("Message with ID %d was not updated", message.ID)
-		return fmt.Errorf("message with ID %d was not updated", message.ID)
+	batch.Query(insertByNews, byNewsValues(message)...)
+	if err := r.session.ExecuteBatch(batch); err != nil {
+		slog.ErrorContext(ctx, "failed to index message by news", slog.Int64("message_id", message.ID), slog.Any("error", err))
+		return 0, fmt.Errorf("failed to update message: %v", err)
 	}
 
-	// Log successful update
-	log.Printf("Successfully updated message with ID: %d, NewsID: %d, Content: %s, State: %s",
-		message.ID, message.NewsID, message.Content, message.State)
+	slog.DebugContext(ctx, "updated message", slog.Any("message", message))
 
-	return nil
+	return storedNewsID, nil
 }
 
-// Delete removes a message by its ID
-func (r *CassandraMessageRepository) Delete(ctx context.Context, id int64) error {
-	log.Printf("Deleting message with ID: %d", id)
-
-	// Check if message exists
-	existing, err := r.FindByID(ctx, id)
-	if err != nil {
-		return fmt.Errorf("failed to check existing message: %v", err)
+// Delete removes a message by its ID with a single lightweight transaction
+// and returns the news it was in. Cassandra returns no values from an applied
+// IF EXISTS, so the news ID needed for messages_by_news is read first with a
+// plain read; the delete is conditional on that news ID, which both checks
+// that the message exists and catches a concurrent move. A failed condition
+// returns the stored news ID, which the next attempt expects.
+func (r *CassandraMessageRepository) Delete(ctx context.Context, id int64) (int64, error) {
+	slog.DebugContext(ctx, "deleting message", slog.Int64("message_id", id))
+
+	var newsID int64
+	err := r.read(ctx, `SELECT newsid FROM tbl_message WHERE id = ?`, id).Scan(&newsID)
+	if errors.Is(err, gocql.ErrNotFound) {
+		slog.DebugContext(ctx, "message not found", slog.Int64("message_id", id))
+		return 0, fmt.Errorf("%w: ID %d", ErrNotFound, id)
 	}
-	if existing == nil {
-		return fmt.Errorf("message with ID %d not found", id)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to read message", slog.Int64("message_id", id), slog.Any("error", err))
+		return 0, fmt.Errorf("failed to delete message: %v", err)
 	}
 
-	err = r.session.Query(`
-		DELETE FROM tbl_message
-		WHERE id = ?
-		USING CONSISTENCY QUORUM`,
-		id).WithContext(ctx).Exec()
-	if err != nil {
-		log.Printf("Error deleting message: %v", err)
-		return fmt.Errorf("failed to delete message: %v", err)
+	for attempt := 1; ; attempt++ {
+		current := map[string]interface{}{}
+		applied, err := r.write(ctx, `DELETE FROM tbl_message WHERE id = ? IF newsid = ?`, id, newsID).MapScanCAS(current)
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to delete message", slog.Int64("message_id", id), slog.Any("error", err))
+			return 0, fmt.Errorf("failed to delete message: %v", err)
+		}
+		if applied {
+			break
+		}
+
+		// Stored messages always have a news ID, so none means no message
+		stored, _ := current["newsid"].(int64)
+		if stored == 0 {
+			slog.DebugContext(ctx, "message not found", slog.Int64("message_id", id))
+			return 0, fmt.Errorf("%w: ID %d", ErrNotFound, id)
+		}
+		if attempt == maxMoveAttempts {
+			slog.WarnContext(ctx, "message keeps changing during delete", slog.Int64("message_id", id))
+			return 0, fmt.Errorf("message with ID %d was changed concurrently", id)
+		}
+		newsID = stored
 	}
 
-	// Verify the message was deleted
-	deleted, err := r.FindByID(ctx, id)
-	if err == nil && deleted != nil {
-		log.Printf("Message with ID %d was not deleted", id)
-		return fmt.Errorf("message with ID %d was not deleted", id)
+	err = r.write(ctx, `DELETE FROM messages_by_news WHERE newsid = ? AND id = ?`, newsID, id).Exec()
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to remove message from its news", slog.Int64("message_id", id), slog.Any("error", err))
+		return 0, fmt.Errorf("failed to delete message: %v", err)
 	}
 
-	// Log successful deletion
-	log.Printf("Successfully deleted message with ID: %d", id)
+	slog.DebugContext(ctx, "deleted message", slog.Int64("message_id", id))
 
-	return nil
+	return newsID, nil
 }
 
 // FindAll retrieves all messages
 func (r *CassandraMessageRepository) FindAll(ctx context.Context) ([]*model.Message, error) {
-	log.Printf("Finding all messages")
+	slog.DebugContext(ctx, "finding all messages")
 
-	iter := r.session.Query(`
-		SELECT id, newsid, country, content, state
+	iter := r.read(ctx, `
+		SELECT id, newsid, country, content, state, decline_rule
 		FROM tbl_message
-		USING CONSISTENCY QUORUM
 	`).Iter()
 
 	var messages []*model.Message
 	var message model.Message
 
-	for iter.Scan(&message.ID, &message.NewsID, &message.Country, &message.Content, &message.State) {
+	for iter.Scan(&message.ID, &message.NewsID, &message.Country, &message.Content, &message.State, &message.DeclineRule) {
 		// Create a new message for each iteration to avoid pointer issues
 		msg := model.Message{
-			ID:      message.ID,
-			NewsID:  message.NewsID,
-			Country: message.Country,
-			Content: message.Content,
-			State:   message.State,
+			ID:          message.ID,
+			NewsID:      message.NewsID,
+			Country:     message.Country,
+			Content:     message.Content,
+			State:       message.State,
+			DeclineRule: message.DeclineRule,
 		}
 		messages = append(messages, &msg)
 	}
 
 	if err := iter.Close(); err != nil {
-		log.Printf("Error closing iterator: %v", err)
+		slog.ErrorContext(ctx, "failed to read messages", slog.Any("error", err))
 		return nil, fmt.Errorf("failed to retrieve messages: %v", err)
 	}
 
-	log.Printf("Found %d messages", len(messages))
+	slog.DebugContext(ctx, "found messages", slog.Int("count", len(messages)))
 	return messages, nil
 }
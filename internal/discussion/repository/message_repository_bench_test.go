@@ -0,0 +1,137 @@
+package repository
+
+import (
+	"RESTAPI/db/migrations"
+	"RESTAPI/internal/discussion/model"
+	"RESTAPI/internal/idgen"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gocql/gocql"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// The benchmarks need a Cassandra node, e.g.
+//
+//	docker run -d -p 9042:9042 cassandra:4.1
+//	CASSANDRA_BENCH_HOSTS=localhost go test -run '^$' -bench . ./internal/discussion/repository
+//
+// They use the keyspace discussion_bench and skip without the variable.
+const benchKeyspace = "discussion_bench"
+
+// queryCounter counts the statements sent to Cassandra
+type queryCounter struct {
+	queries atomic.Int64
+}
+
+func (c *queryCounter) ObserveQuery(context.Context, gocql.ObservedQuery) {
+	c.queries.Add(1)
+}
+
+func benchRepository(b *testing.B) (*CassandraMessageRepository, *queryCounter) {
+	hosts := os.Getenv("CASSANDRA_BENCH_HOSTS")
+	if hosts == "" {
+		b.Skip("CASSANDRA_BENCH_HOSTS is not set")
+	}
+
+	cluster := gocql.NewCluster(strings.Split(hosts, ",")...)
+	cluster.Keyspace = benchKeyspace
+	cluster.Timeout = 10 * time.Second
+	if err := migrations.CreateKeyspace(cluster, benchKeyspace); err != nil {
+		b.Fatalf("failed to create keyspace: %v", err)
+	}
+	session, err := cluster.CreateSession()
+	if err != nil {
+		b.Fatalf("failed to connect: %v", err)
+	}
+	b.Cleanup(session.Close)
+
+	migrator, err := migrations.NewCassandra(session)
+	if err != nil {
+		b.Fatal(err)
+	}
+	if _, err := migrator.Up(context.Background()); err != nil {
+		b.Fatalf("failed to migrate: %v", err)
+	}
+
+	// Statements are counted from here on; the session above is only for setup
+	counter := &queryCounter{}
+	cluster.QueryObserver = counter
+	counted, err := cluster.CreateSession()
+	if err != nil {
+		b.Fatalf("failed to connect: %v", err)
+	}
+	b.Cleanup(counted.Close)
+
+	ids, err := idgen.New(0)
+	if err != nil {
+		b.Fatal(err)
+	}
+	consistency := Consistency{Read: gocql.Quorum, Write: gocql.Quorum, Serial: gocql.Serial}
+	return NewCassandraMessageRepository(counted, ids, consistency), counter
+}
+
+// deleteWithVerify is Delete as it was before the writes trusted the
+// lightweight transaction: the message is read, deleted and read again to
+// verify the delete; the row in messages_by_news is removed as Delete does
+func deleteWithVerify(ctx context.Context, r *CassandraMessageRepository, id int64) error {
+	existing, err := r.FindByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := r.write(ctx, `DELETE FROM tbl_message WHERE id = ?`, id).Exec(); err != nil {
+		return err
+	}
+	err = r.write(ctx, `DELETE FROM messages_by_news WHERE newsid = ? AND id = ?`, existing.NewsID, id).Exec()
+	if err != nil {
+		return err
+	}
+	if _, err := r.FindByID(ctx, id); !errors.Is(err, ErrNotFound) {
+		return fmt.Errorf("message %d was not deleted: %v", id, err)
+	}
+	return nil
+}
+
+// BenchmarkDelete compares the statements and the latency of a delete
+// before and after the verifying reads were dropped
+func BenchmarkDelete(b *testing.B) {
+	repo, counter := benchRepository(b)
+	flows := []struct {
+		name   string
+		delete func(ctx context.Context, id int64) error
+	}{
+		{"verify-reads", func(ctx context.Context, id int64) error { return deleteWithVerify(ctx, repo, id) }},
+		{"conditional-delete", func(ctx context.Context, id int64) error {
+			_, err := repo.Delete(ctx, id)
+			return err
+		}},
+	}
+
+	for _, flow := range flows {
+		b.Run(flow.name, func(b *testing.B) {
+			ctx := context.Background()
+			ids := make([]int64, b.N)
+			for i := range ids {
+				message := &model.Message{NewsID: int64(i%10 + 1), Content: "benchmark"}
+				if err := repo.Create(ctx, message); err != nil {
+					b.Fatalf("failed to create message: %v", err)
+				}
+				ids[i] = message.ID
+			}
+
+			counter.queries.Store(0)
+			b.ResetTimer()
+			for _, id := range ids {
+				if err := flow.delete(ctx, id); err != nil {
+					b.Fatalf("failed to delete message %d: %v", id, err)
+				}
+			}
+			b.StopTimer()
+			b.ReportMetric(float64(counter.queries.Load())/float64(b.N), "queries/op")
+		})
+	}
+}
@@ -4,72 +4,87 @@ import (
 	"RESTAPI/internal/discussion/model"
 	"RESTAPI/internal/discussion/repository"
 	"context"
-	"encoding/json"
+	"encoding/base64"
+	"errors"
 	"fmt"
-	"log"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"log/slog"
 	"net/http"
+	"slices"
+	"strings"
 	"time"
 )
 
+var (
+	// ErrInvalidCursor is returned when a pagination cursor cannot be decoded
+	ErrInvalidCursor = errors.New("invalid cursor")
+	// ErrNewsNotFound is returned when the publisher service answers that a news does not exist
+	ErrNewsNotFound = errors.New("news not found")
+	// ErrPublisherUnavailable is returned when the publisher service cannot tell
+	// whether a news exists: it is unreachable, refuses the token or fails
+	ErrPublisherUnavailable = errors.New("publisher service unavailable")
+)
+
 // MessageService handles business logic for messages
 type MessageService struct {
-	repo   repository.MessageRepository
-	client *http.Client
+	repo           repository.MessageRepository
+	client         *http.Client
+	publisherURL   string
+	publisherToken string
 }
 
 // NewMessageService creates a new MessageService
-func NewMessageService(repo repository.MessageRepository) *MessageService {
+// publisherURL is the base URL of the publisher service, e.g. "http://localhost:24110",
+// and publisherToken the bearer token news are read with
+func NewMessageService(repo repository.MessageRepository, publisherURL, publisherToken string) *MessageService {
 	return &MessageService{
-		repo: repo,
+		repo:           repo,
+		publisherURL:   strings.TrimSuffix(publisherURL, "/"),
+		publisherToken: publisherToken,
 		client: &http.Client{
-			Timeout: 5 * time.Second,
+			Timeout:   5 * time.Second,
+			Transport: otelhttp.NewTransport(http.DefaultTransport),
 		},
 	}
 }
 
-func (s *MessageService) checkNewsExists(newsId int64) error {
-	resp, err := s.client.Get(fmt.Sprintf("http://localhost:24110/api/v1.0/news/%d", newsId))
+// checkNewsExists asks the publisher service for the news; the request ends
+// with ctx and continues its trace. Only a 404 means the news does not exist.
+func (s *MessageService) checkNewsExists(ctx context.Context, newsId int64) error {
+	url := fmt.Sprintf("%s/api/v1.0/news/%d", s.publisherURL, newsId)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return fmt.Errorf("failed to check news existence: %v", err)
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("news with ID %d does not exist", newsId)
+	if s.publisherToken != "" {
+		req.Header.Set("Authorization", "Bearer "+s.publisherToken)
 	}
-
-	return nil
-}
-
-func (s *MessageService) getMessageFromMainService(id int64) (*model.Message, error) {
-	resp, err := s.client.Get(fmt.Sprintf("http://localhost:24110/api/v1.0/messages/%d", id))
+	resp, err := s.client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get message from main service: %v", err)
+		return fmt.Errorf("%w: %v", ErrPublisherUnavailable, err)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("message with ID %d not found in main service", id)
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusNotFound:
+		return fmt.Errorf("%w: ID %d", ErrNewsNotFound, newsId)
+	default:
+		return fmt.Errorf("%w: GET %s returned %d", ErrPublisherUnavailable, url, resp.StatusCode)
 	}
-
-	var message model.Message
-	if err := json.NewDecoder(resp.Body).Decode(&message); err != nil {
-		return nil, fmt.Errorf("failed to decode message: %v", err)
-	}
-
-	return &message, nil
 }
 
 // CreateMessage creates a new message
 func (s *MessageService) CreateMessage(ctx context.Context, message *model.Message) error {
-	log.Printf("Creating message with NewsID: %d", message.NewsID)
+	slog.DebugContext(ctx, "creating message", slog.Int64("news_id", message.NewsID))
 
 	// Validate newsId
 	if message.NewsID == 0 {
 		return fmt.Errorf("newsId is required")
 	}
 
-	if err := s.checkNewsExists(message.NewsID); err != nil {
+	if err := s.checkNewsExists(ctx, message.NewsID); err != nil {
 		return err
 	}
 
@@ -79,136 +94,208 @@ func (s *MessageService) CreateMessage(ctx context.Context, message *model.Messa
 		return fmt.Errorf("failed to create message: %v", err)
 	}
 
-	// Log successful creation
-	log.Printf("Successfully created message with ID: %d, NewsID: %d",
-		message.ID, message.NewsID)
+	slog.InfoContext(ctx, "message created", slog.Int64("message_id", message.ID), slog.Int64("news_id", message.NewsID))
 
 	return nil
 }
 
 // GetMessage retrieves a message by ID
 func (s *MessageService) GetMessage(ctx context.Context, id int64) (*model.Message, error) {
-	log.Printf("Getting message with ID: %d", id)
+	slog.DebugContext(ctx, "getting message", slog.Int64("message_id", id))
+
+	return s.repo.FindByID(ctx, id)
+}
+
+// GetMessagesByNewsID retrieves all messages for a news item
+func (s *MessageService) GetMessagesByNewsID(ctx context.Context, newsID int64) ([]*model.Message, error) {
+	slog.DebugContext(ctx, "getting messages for news", slog.Int64("news_id", newsID))
 
-	// First try to get from Cassandra
-	message, err := s.repo.FindByID(ctx, id)
-	if err == nil {
-		return message, nil
+	return s.repo.FindByNewsID(ctx, newsID)
+}
+
+// GetMessagesPageByNewsID retrieves one page of messages for a news item.
+// after is the opaque cursor from the previous page; the returned cursor is empty on the last page.
+func (s *MessageService) GetMessagesPageByNewsID(ctx context.Context, newsID int64, after string, limit int) ([]*model.Message, string, error) {
+	slog.DebugContext(ctx, "getting page of messages for news", slog.Int64("news_id", newsID))
+
+	var pageState []byte
+	if after != "" {
+		state, err := base64.RawURLEncoding.DecodeString(after)
+		if err != nil {
+			return nil, "", ErrInvalidCursor
+		}
+		pageState = state
 	}
 
-	// If not found in Cassandra, try to get from main service
-	message, err = s.getMessageFromMainService(id)
+	messages, next, err := s.repo.FindPageByNewsID(ctx, newsID, pageState, limit, false)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
-	// Save to Cassandra for future use
-	if err := s.repo.Create(ctx, message); err != nil {
-		log.Printf("Warning: Failed to save message to Cassandra: %v", err)
+	if len(next) == 0 {
+		return messages, "", nil
 	}
-
-	return message, nil
+	return messages, base64.RawURLEncoding.EncodeToString(next), nil
 }
 
-// GetMessagesByNewsID retrieves all messages for a news item
-func (s *MessageService) GetMessagesByNewsID(ctx context.Context, newsID int64) ([]*model.Message, error) {
-	log.Printf("Getting messages for NewsID: %d", newsID)
+// MaxListOffset bounds the messages ListMessages skips to reach a page. Every
+// skipped page is a Cassandra round-trip, so deeper pages are refused with
+// ErrPageTooDeep; the cursor API of GetMessagesPageByNewsID has no such limit.
+const MaxListOffset = 1000
+
+// ErrPageTooDeep is returned by ListMessages for a page beyond MaxListOffset
+var ErrPageTooDeep = fmt.Errorf("page too deep: at most %d messages can be skipped, "+
+	"page through /messages/news/{newsId} with after= instead", MaxListOffset)
+
+// ListQuery selects a page of messages, see ListMessages
+type ListQuery struct {
+	// Page counts from 1
+	Page int
+	// PageSize 0 returns every match
+	PageSize int
+	// NewsID restricts the list to a news item, 0 lists every message
+	NewsID int64
+	// ID restricts the list to a single message, 0 for any
+	ID int64
+	// Desc orders by ID descending; messages are only ordered within a news item
+	Desc bool
+}
 
-	// First try to get from Cassandra
-	messages, err := s.repo.FindByNewsID(ctx, newsID)
-	if err == nil && len(messages) > 0 {
-		return messages, nil
+// ListMessages returns a page of messages and the number of all matches, or
+// -1 when counting them would scan every node. The pages before the requested
+// one are skipped with the Cassandra page state, so only the requested page
+// leaves the service. The messages of a news item come in ID order, the
+// others in the order they are stored.
+func (s *MessageService) ListMessages(ctx context.Context, q ListQuery) ([]*model.Message, int64, error) {
+	slog.DebugContext(ctx, "listing messages", slog.Int64("news_id", q.NewsID), slog.Int("page", q.Page))
+	if q.PageSize > 0 && q.Page-1 > MaxListOffset/q.PageSize {
+		return nil, 0, ErrPageTooDeep
 	}
 
-	// If not found in Cassandra, try to get from main service
-	resp, err := s.client.Get(fmt.Sprintf("http://localhost:24110/api/v1.0/messages?newsId=%d", newsID))
-	if err != nil {
-		return nil, fmt.Errorf("failed to get messages from main service: %v", err)
+	if q.ID != 0 {
+		message, err := s.repo.FindByID(ctx, q.ID)
+		if errors.Is(err, repository.ErrNotFound) || (err == nil && q.NewsID != 0 && message.NewsID != q.NewsID) {
+			return []*model.Message{}, 0, nil
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		if q.Page > 1 {
+			return []*model.Message{}, 1, nil
+		}
+		return []*model.Message{message}, 1, nil
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to get messages: status %d", resp.StatusCode)
+	if q.PageSize <= 0 {
+		return s.listAll(ctx, q)
 	}
 
-	if err := json.NewDecoder(resp.Body).Decode(&messages); err != nil {
-		return nil, fmt.Errorf("failed to decode messages: %v", err)
-	}
+	var state []byte
+	for page := 1; ; page++ {
+		var (
+			messages []*model.Message
+			next     []byte
+			err      error
+		)
+		if q.NewsID != 0 {
+			messages, next, err = s.repo.FindPageByNewsID(ctx, q.NewsID, state, q.PageSize, q.Desc)
+		} else {
+			messages, next, err = s.repo.FindPage(ctx, state, q.PageSize)
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		if page < q.Page && len(next) > 0 {
+			state = next
+			continue
+		}
+		if page < q.Page {
+			messages = []*model.Message{}
+		}
 
-	// Save to Cassandra for future use
-	for _, message := range messages {
-		if err := s.repo.Create(ctx, message); err != nil {
-			log.Printf("Warning: Failed to save message to Cassandra: %v", err)
+		if q.NewsID == 0 {
+			return messages, -1, nil
+		}
+		total, err := s.repo.CountByNewsID(ctx, q.NewsID)
+		if err != nil {
+			return nil, 0, err
 		}
+		return messages, total, nil
 	}
+}
 
-	return messages, nil
+// listAll returns every message matching q, see ListMessages
+func (s *MessageService) listAll(ctx context.Context, q ListQuery) ([]*model.Message, int64, error) {
+	if q.NewsID == 0 {
+		messages, err := s.repo.FindAll(ctx)
+		if err != nil {
+			return nil, 0, err
+		}
+		return messages, int64(len(messages)), nil
+	}
+	messages, err := s.repo.FindByNewsID(ctx, q.NewsID)
+	if err != nil {
+		return nil, 0, err
+	}
+	if q.Desc {
+		slices.Reverse(messages)
+	}
+	return messages, int64(len(messages)), nil
 }
 
-// UpdateMessage updates an existing message
+// UpdateMessage updates an existing message.
+// An empty state or country keeps the stored value. The rule that declined
+// the message is kept with its state and cleared once it is no longer DECLINE.
 func (s *MessageService) UpdateMessage(ctx context.Context, message *model.Message) error {
-	log.Printf("Updating message with ID: %d, NewsID: %d", message.ID, message.NewsID)
+	slog.DebugContext(ctx, "updating message", slog.Int64("message_id", message.ID), slog.Int64("news_id", message.NewsID))
 
 	// Validate newsId
 	if message.NewsID == 0 {
 		return fmt.Errorf("newsId is required")
 	}
 
-	if err := s.checkNewsExists(message.NewsID); err != nil {
+	if err := s.checkNewsExists(ctx, message.NewsID); err != nil {
 		return err
 	}
 
+	if message.State == "" || message.Country == "" {
+		existing, err := s.repo.FindByID(ctx, message.ID)
+		if err != nil {
+			return err
+		}
+		if message.State == "" {
+			message.State = existing.State
+			message.DeclineRule = existing.DeclineRule
+		}
+		if message.Country == "" {
+			message.Country = existing.Country
+		}
+	}
+
+	if message.State != model.StateDecline {
+		message.DeclineRule = ""
+	}
+
 	// Update the message
-	err := s.repo.Update(ctx, message)
-	if err != nil {
-		return fmt.Errorf("failed to update message: %v", err)
+	if _, err := s.repo.Update(ctx, message); err != nil {
+		return fmt.Errorf("failed to update message: %w", err)
 	}
 
-	// Log successful update
-	log.Printf("Successfully updated message with ID: %d, NewsID: %d",
-		message.ID, message.NewsID)
+	slog.InfoContext(ctx, "message updated", slog.Int64("message_id", message.ID), slog.Int64("news_id", message.NewsID))
 
 	return nil
 }
 
 // DeleteMessage deletes a message by ID
 func (s *MessageService) DeleteMessage(ctx context.Context, id int64) error {
-	log.Printf("Deleting message with ID: %d", id)
+	slog.DebugContext(ctx, "deleting message", slog.Int64("message_id", id))
 
-	return s.repo.Delete(ctx, id)
+	_, err := s.repo.Delete(ctx, id)
+	return err
 }
 
 // GetAllMessages retrieves all messages
 func (s *MessageService) GetAllMessages(ctx context.Context) ([]*model.Message, error) {
-	log.Printf("Getting all messages")
-
-	// First try to get from Cassandra
-	messages, err := s.repo.FindAll(ctx)
-	if err == nil && len(messages) > 0 {
-		return messages, nil
-	}
-
-	// If not found in Cassandra, try to get from main service
-	resp, err := s.client.Get("http://localhost:24110/api/v1.0/messages")
-	if err != nil {
-		return nil, fmt.Errorf("failed to get messages from main service: %v", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to get messages: status %d", resp.StatusCode)
-	}
-
-	if err := json.NewDecoder(resp.Body).Decode(&messages); err != nil {
-		return nil, fmt.Errorf("failed to decode messages: %v", err)
-	}
-
-	// Save to Cassandra for future use
-	for _, message := range messages {
-		if err := s.repo.Create(ctx, message); err != nil {
-			log.Printf("Warning: Failed to save message to Cassandra: %v", err)
-		}
-	}
+	slog.DebugContext(ctx, "getting all messages")
 
-	return messages, nil
+	return s.repo.FindAll(ctx)
 }
@@ -0,0 +1,17 @@
+package dto
+
+type LoginRequestTo struct {
+	Login    string `json:"login" validate:"required,min=2,max=64"`
+	Password string `json:"password" validate:"required,min=8,max=128"`
+}
+
+type RefreshRequestTo struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+type TokenResponseTo struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
@@ -1,6 +1,6 @@
 package dto
 
 type MarkUpdateRequestTo struct {
-	Name string `json:"name" validate:"required,min=2,max=32"`
+	Name string `json:"name" validate:"required,min=2,max=32,markname"`
 	ID   int64  `json:"id"`
 }
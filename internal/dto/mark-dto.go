@@ -1,7 +1,7 @@
 package dto
 
 type MarkRequestTo struct {
-	Name string `json:"name" validate:"required,min=2,max=32"`
+	Name string `json:"name" validate:"required,min=2,max=32,markname"`
 }
 
 type MarkResponseTo struct {
@@ -1,12 +1,15 @@
 package dto
 
 type MessageRequestTo struct {
-	NewsID  int64  `json:"newsId" validate:"required"`
-	Content string `json:"content" validate:"required,min=2,max=2048"`
+	NewsID   int64  `json:"newsId" validate:"required"`
+	Content  string `json:"content" validate:"required,min=2,max=2048"`
+	WriterID int64  `json:"-"` // автор, берётся из токена
 }
 
 type MessageResponseTo struct {
-	ID      int64  `json:"id"`
-	NewsID  int64  `json:"newsId"`
-	Content string `json:"content"`
+	ID          int64  `json:"id"`
+	NewsID      int64  `json:"newsId"`
+	Content     string `json:"content"`
+	State       string `json:"state"`                 // PENDING, пока сервис обсуждений не вынес решение
+	DeclineRule string `json:"declineRule,omitempty"` // правило модерации, отклонившее сообщение
 }
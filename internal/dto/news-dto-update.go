@@ -5,5 +5,5 @@ type NewsUpdateRequestTo struct {
 	Title    string                `json:"title" validate:"required,min=2,max=64"`
 	Content  string                `json:"content" validate:"required,min=4,max=2048"`
 	ID       int64                 `json:"id"`
-	Marks    []MarkUpdateRequestTo `json:"marks"`
+	Marks    []MarkUpdateRequestTo `json:"marks" validate:"dive"`
 }
@@ -8,7 +8,7 @@ type NewsRequestTo struct {
 	WriterID int64    `json:"writerId" validate:"required"`
 	Title    string   `json:"title" validate:"required,min=2,max=64"`
 	Content  string   `json:"content" validate:"required,min=4,max=2048"`
-	Marks    []string `json:"marks"`
+	Marks    []string `json:"marks" validate:"dive,min=2,max=32,markname"`
 }
 
 type NewsResponseTo struct {
@@ -2,12 +2,29 @@ package entity
 
 import "time"
 
+type Role string
+
+const (
+	RoleAdmin    Role = "ADMIN"
+	RoleCustomer Role = "CUSTOMER"
+)
+
+// MessageState is the moderation state assigned by the discussion service
+type MessageState string
+
+const (
+	MessagePending MessageState = "PENDING"
+	MessageApprove MessageState = "APPROVE"
+	MessageDecline MessageState = "DECLINE"
+)
+
 type Writer struct {
 	ID        int64  `gorm:"primaryKey;autoIncrement" json:"id"`
 	Login     string `gorm:"column:login;size:64;not null;unique" json:"login"`
 	Password  string `gorm:"column:password;size:128;not null" json:"-"`
 	FirstName string `gorm:"column:firstname;size:64;not null" json:"firstname"`
 	LastName  string `gorm:"column:lastname;size:64;not null" json:"lastname"`
+	Role      Role   `gorm:"column:role;size:16;not null;default:CUSTOMER" json:"role"`
 }
 
 func (News) TableName() string {
@@ -26,23 +43,44 @@ func (Message) TableName() string {
 	return "tbl_message"
 }
 
+func (OutboxEvent) TableName() string {
+	return "tbl_outbox"
+}
+
 type News struct {
-	ID       int64     `gorm:"primaryKey;autoIncrement" json:"id"`
+	ID       int64     `gorm:"primaryKey;autoIncrement;index:idx_news_created_id,priority:2" json:"id"`
 	WriterID int64     `gorm:"not null" json:"writerId"`
 	Title    string    `gorm:"size:255;not null" json:"title"`
 	Content  string    `gorm:"type:text;not null" json:"content"`
-	Created  time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"created"`
+	Created  time.Time `gorm:"default:CURRENT_TIMESTAMP;index:idx_news_created_id,priority:1" json:"created"`
 	Modified time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"modified"`
 	Marks    []Mark    `gorm:"many2many:news_mark;"`
+	Writer   *Writer   `gorm:"foreignKey:WriterID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
 }
 
 type Message struct {
-	ID      int64  `gorm:"primaryKey;autoIncrement" json:"id"`
-	NewsID  int64  `gorm:"not null" json:"newsId"`
-	Content string `gorm:"type:text;not null" json:"content"`
+	ID       int64        `gorm:"primaryKey;autoIncrement" json:"id"`
+	NewsID   int64        `gorm:"not null" json:"newsId"`
+	WriterID int64        `gorm:"column:writer_id" json:"writerId"` // автор сообщения, 0 для старых записей
+	Content  string       `gorm:"type:text;not null" json:"content"`
+	State    MessageState `gorm:"size:16;not null;default:PENDING" json:"state"`
+	News     *News        `gorm:"foreignKey:NewsID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
 }
 
 type Mark struct {
 	ID   int64  `gorm:"primaryKey;autoIncrement" json:"id"`
 	Name string `gorm:"size:32;not null;unique" json:"name"`
 }
+
+// OutboxEvent is a change waiting to be published to Kafka, see internal/outbox
+type OutboxEvent struct {
+	ID            int64      `gorm:"primaryKey;autoIncrement"`
+	AggregateType string     `gorm:"size:32;not null"`
+	AggregateID   int64      `gorm:"not null"`
+	EventType     string     `gorm:"size:64;not null"`
+	Topic         string     `gorm:"size:64;not null"`
+	Payload       []byte     `gorm:"type:jsonb;not null"`
+	RequestID     string     `gorm:"size:64;not null;default:''"`
+	Created       time.Time  `gorm:"default:CURRENT_TIMESTAMP"`
+	SentAt        *time.Time `gorm:"column:sent_at"`
+}
@@ -0,0 +1,18 @@
+package handler
+
+import (
+	"RESTAPI/internal/access"
+	"RESTAPI/internal/auth"
+	"RESTAPI/internal/entity"
+
+	"github.com/labstack/echo/v4"
+)
+
+// principalOf returns the writer authenticated by auth.Middleware
+func principalOf(c echo.Context) access.Principal {
+	claims := auth.ClaimsFrom(c)
+	if claims == nil {
+		return access.Principal{}
+	}
+	return access.Principal{WriterID: claims.WriterID(), Role: entity.Role(claims.Role)}
+}
@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"net/http"
+
+	"RESTAPI/internal/apperr"
+	"RESTAPI/internal/auth"
+	"RESTAPI/internal/dto"
+	"RESTAPI/internal/service"
+
+	"github.com/labstack/echo/v4"
+)
+
+var errInvalidRefreshToken = apperr.Unauthorized("invalid or expired refresh token")
+
+type AuthHandler struct {
+	writers *service.WriterService
+	tokens  *auth.TokenManager
+}
+
+func NewAuthHandler(writers *service.WriterService, tokens *auth.TokenManager) *AuthHandler {
+	return &AuthHandler{writers: writers, tokens: tokens}
+}
+
+// Login exchanges a writer login and password for a token pair
+func (h *AuthHandler) Login(c echo.Context) error {
+	var req dto.LoginRequestTo
+	if err := c.Bind(&req); err != nil {
+		return errInvalidRequest
+	}
+	if err := c.Validate(&req); err != nil {
+		return err
+	}
+
+	writer, err := h.writers.VerifyCredentials(c.Request().Context(), req.Login, req.Password)
+	if err != nil {
+		return err
+	}
+
+	return h.issue(c, writer)
+}
+
+// Refresh exchanges a refresh token for a new token pair
+func (h *AuthHandler) Refresh(c echo.Context) error {
+	var req dto.RefreshRequestTo
+	if err := c.Bind(&req); err != nil {
+		return errInvalidRequest
+	}
+	if err := c.Validate(&req); err != nil {
+		return err
+	}
+
+	claims, err := h.tokens.Parse(req.RefreshToken, auth.TokenTypeRefresh)
+	if err != nil {
+		return errInvalidRefreshToken
+	}
+
+	// The writer may have been deleted or had their role changed since the refresh token was issued
+	writer, err := h.writers.GetById(c.Request().Context(), claims.WriterID())
+	if err != nil {
+		if apperr.IsNotFound(err) {
+			return errInvalidRefreshToken
+		}
+		return err
+	}
+
+	return h.issue(c, writer)
+}
+
+func (h *AuthHandler) issue(c echo.Context, writer *dto.WriterResponseTo) error {
+	pair, err := h.tokens.Issue(writer.ID, writer.Login, writer.Role)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, dto.TokenResponseTo{
+		AccessToken:  pair.AccessToken,
+		RefreshToken: pair.RefreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(pair.ExpiresIn.Seconds()),
+	})
+}
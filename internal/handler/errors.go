@@ -0,0 +1,110 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"RESTAPI/internal/apperr"
+
+	"github.com/labstack/echo/v4"
+)
+
+const mimeProblemJSON = "application/problem+json"
+
+// Problem is an RFC 7807 problem details body
+type Problem struct {
+	Type     string              `json:"type"`
+	Title    string              `json:"title"`
+	Status   int                 `json:"status"`
+	Detail   string              `json:"detail,omitempty"`
+	Instance string              `json:"instance,omitempty"`
+	Errors   []apperr.FieldError `json:"errors,omitempty"`
+}
+
+// statusOf maps error kinds to HTTP status codes.
+// Conflicts are reported as 403: the API has always answered duplicate
+// logins and titles that way and clients rely on it.
+func statusOf(kind apperr.Kind) int {
+	switch kind {
+	case apperr.KindNotFound:
+		return http.StatusNotFound
+	case apperr.KindConflict:
+		return http.StatusForbidden
+	case apperr.KindValidation:
+		return http.StatusBadRequest
+	case apperr.KindForbidden:
+		return http.StatusForbidden
+	case apperr.KindUnauthorized:
+		return http.StatusUnauthorized
+	case apperr.KindUnavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// ErrorHandler renders every error returned by a handler or middleware as
+// application/problem+json
+func ErrorHandler(err error, c echo.Context) {
+	if c.Response().Committed {
+		return
+	}
+
+	problem := Problem{Type: "about:blank", Instance: c.Request().URL.Path}
+
+	var appErr *apperr.Error
+	var httpErr *echo.HTTPError
+	switch {
+	case errors.As(err, &appErr):
+		problem.Type = "/problems/" + appErr.Kind.String()
+		problem.Status = statusOf(appErr.Kind)
+		// Causes wrapped around or inside the error may carry driver
+		// details, so the client gets the message and the log the rest
+		problem.Detail = appErr.Message
+		problem.Errors = appErr.Fields
+		if err.Error() != appErr.Message {
+			level := slog.LevelInfo
+			if problem.Status >= http.StatusInternalServerError {
+				level = slog.LevelError
+			}
+			slog.Log(c.Request().Context(), level, "request failed",
+				slog.String("kind", appErr.Kind.String()), slog.Any("error", err))
+		}
+	case errors.As(err, &httpErr):
+		problem.Status = httpErr.Code
+		if msg, ok := httpErr.Message.(string); ok {
+			problem.Detail = msg
+		}
+	default:
+		// Unclassified errors may carry driver details, so they are only logged
+		slog.ErrorContext(c.Request().Context(), "request failed", slog.Any("error", err))
+		problem.Status = http.StatusInternalServerError
+	}
+	problem.Title = http.StatusText(problem.Status)
+
+	c.Response().Header().Set(echo.HeaderContentType, mimeProblemJSON)
+	if c.Request().Method == http.MethodHead {
+		err = c.NoContent(problem.Status)
+	} else {
+		err = c.JSON(problem.Status, problem)
+	}
+	if err != nil {
+		slog.ErrorContext(c.Request().Context(), "failed to write error response", slog.Any("error", err))
+	}
+}
+
+var (
+	errInvalidID      = apperr.Validation("invalid ID format")
+	errInvalidRequest = apperr.Validation("invalid request format")
+)
+
+// parseID reads a positive int64 path parameter
+func parseID(c echo.Context) (int64, error) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		return 0, errInvalidID
+	}
+	return id, nil
+}
@@ -2,101 +2,88 @@ package handler
 
 import (
 	"net/http"
-	"strconv"
 
+	"RESTAPI/internal/access"
 	"RESTAPI/internal/dto"
-	"RESTAPI/internal/service"
 
-	"github.com/go-playground/validator/v10"
 	"github.com/labstack/echo/v4"
 )
 
 type MarkHandler struct {
-	service *service.MarkService
+	service *access.MarkService
 }
 
-func NewMarkHandler(service *service.MarkService) *MarkHandler {
+func NewMarkHandler(service *access.MarkService) *MarkHandler {
 	return &MarkHandler{service: service}
 }
 
 func (h *MarkHandler) Create(c echo.Context) error {
 	var req dto.MarkRequestTo
 	if err := c.Bind(&req); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request format"})
+		return errInvalidRequest
 	}
-	if err := validator.New().Struct(req); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	if err := c.Validate(&req); err != nil {
+		return err
 	}
-	resp, err := h.service.Create(req)
+	resp, err := h.service.Create(c.Request().Context(), principalOf(c), req)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return err
 	}
 	return c.JSON(http.StatusCreated, resp)
 }
 
 func (h *MarkHandler) GetById(c echo.Context) error {
-	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
-	if err != nil || id <= 0 {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid ID format"})
+	id, err := parseID(c)
+	if err != nil {
+		return err
 	}
-	resp, err := h.service.GetById(id)
+	resp, err := h.service.GetById(c.Request().Context(), id)
 	if err != nil {
-		return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+		return err
 	}
 	return c.JSON(http.StatusOK, resp)
 }
 
 func (h *MarkHandler) Update(c echo.Context) error {
-	/*id, err := strconv.ParseInt(c.Param("id"), 10, 64)
-	if err != nil || id <= 0 {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid ID format"})
-	}*/
-
 	var req dto.MarkUpdateRequestTo
 	if err := c.Bind(&req); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request format"})
+		return errInvalidRequest
 	}
 
-	var Validate = validator.New()
-
 	// Валидация входных данных
-	if err := Validate.Struct(&req); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	if err := c.Validate(&req); err != nil {
+		return err
 	}
 
-	resp, err := h.service.Update(req)
+	resp, err := h.service.Update(c.Request().Context(), principalOf(c), req)
 	if err != nil {
-		if err.Error() == "mark not found" {
-			return c.JSON(http.StatusNotFound, map[string]string{"error": "Mark not found"})
-		}
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return err
 	}
 	return c.JSON(http.StatusOK, resp)
 }
 
 func (h *MarkHandler) Delete(c echo.Context) error {
-	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
-	if err != nil || id <= 0 {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid ID format"})
-	}
-
-	// First check if the mark exists
-	_, err = h.service.GetById(id)
+	id, err := parseID(c)
 	if err != nil {
-		return c.JSON(http.StatusNotFound, map[string]string{"error": "Mark not found"})
+		return err
 	}
 
-	err = h.service.Delete(id)
-	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	if err := h.service.Delete(c.Request().Context(), principalOf(c), id); err != nil {
+		return err
 	}
 
 	return c.NoContent(http.StatusNoContent)
 }
+
 func (h *MarkHandler) GetAll(c echo.Context) error {
-	marks, err := h.service.GetAll()
+	q, err := parseListQuery(c)
+	if err != nil {
+		return err
+	}
+	marks, total, err := h.service.List(c.Request().Context(), q)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return err
 	}
+	setPageHeaders(c, q, total)
 	return c.JSON(http.StatusOK, marks)
 }
@@ -1,104 +1,125 @@
 package handler
 
 import (
+	"RESTAPI/internal/access"
+	"RESTAPI/internal/apperr"
+	"RESTAPI/internal/discussion/model"
 	"RESTAPI/internal/dto"
-	"RESTAPI/internal/service"
 	"net/http"
 	"strconv"
-	"strings"
 
-	"github.com/go-playground/validator/v10"
 	"github.com/labstack/echo/v4"
 )
 
 type MessageHandler struct {
-	service *service.MessageService
+	service *access.MessageService
 }
 
-func NewMessageHandler(service *service.MessageService) *MessageHandler {
+func NewMessageHandler(service *access.MessageService) *MessageHandler {
 	return &MessageHandler{service: service}
 }
 
 func (h *MessageHandler) Create(c echo.Context) error {
 	var req dto.MessageRequestTo
 	if err := c.Bind(&req); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request format"})
+		return errInvalidRequest
 	}
-	if err := validator.New().Struct(req); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	if err := c.Validate(&req); err != nil {
+		return err
 	}
-	resp, err := h.service.Create(req)
-
+	wait, err := waitParam(c)
 	if err != nil {
-		// Handle different types of errors with appropriate status codes
-		if strings.Contains(err.Error(), "writer not found") {
-			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
-		} else if strings.Contains(err.Error(), "already exists") {
-			return c.JSON(http.StatusForbidden, map[string]string{"error": err.Error()})
+		return err
+	}
+	if !wait {
+		resp, err := h.service.Create(c.Request().Context(), principalOf(c), req)
+		if err != nil {
+			return err
 		}
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return c.JSON(http.StatusCreated, resp)
+	}
 
+	// ?wait=true answers with the moderation verdict; 202 means it did not arrive in time
+	resp, err := h.service.CreateAndWait(c.Request().Context(), principalOf(c), req)
+	if err != nil {
+		return err
+	}
+	if resp.State == string(model.StatePending) {
+		return c.JSON(http.StatusAccepted, resp)
 	}
 	return c.JSON(http.StatusCreated, resp)
 }
 
+// waitParam reads ?wait=; it is off by default
+func waitParam(c echo.Context) (bool, error) {
+	v := c.QueryParam("wait")
+	if v == "" {
+		return false, nil
+	}
+	wait, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, apperr.Validation("wait must be true or false",
+			apperr.FieldError{Field: "wait", Rule: "boolean"})
+	}
+	return wait, nil
+}
+
 func (h *MessageHandler) GetById(c echo.Context) error {
-	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
-	if err != nil || id <= 0 {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid ID format"})
+	id, err := parseID(c)
+	if err != nil {
+		return err
 	}
-	resp, err := h.service.GetById(id)
+	resp, err := h.service.GetById(c.Request().Context(), id)
 	if err != nil {
-		return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+		return err
 	}
 	return c.JSON(http.StatusOK, resp)
 }
 
 func (h *MessageHandler) Update(c echo.Context) error {
-	/*id, err := strconv.ParseInt()
-	if err != nil || id <= 0 {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid ID format"})
-	}*/
-
 	var req dto.MessageUpdateRequestTo
 	if err := c.Bind(&req); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request format"})
+		return errInvalidRequest
 	}
-	var Validate = validator.New()
-
 	// Валидация входных данных
-	if err := Validate.Struct(&req); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	if err := c.Validate(&req); err != nil {
+		return err
 	}
 
-	resp, err := h.service.Update(req)
+	resp, err := h.service.Update(c.Request().Context(), principalOf(c), req)
 	if err != nil {
-		if err.Error() == "message not found" {
-			return c.JSON(http.StatusNotFound, map[string]string{"error": "Message not found"})
-		}
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return err
 	}
 	return c.JSON(http.StatusOK, resp)
 }
 
 func (h *MessageHandler) Delete(c echo.Context) error {
-	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
-	if err != nil || id <= 0 {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid ID format"})
+	id, err := parseID(c)
+	if err != nil {
+		return err
 	}
 
-	err = h.service.Delete(id)
-	if err != nil {
-		return c.JSON(http.StatusNotFound, map[string]string{"error": "Message not found"})
+	if err := h.service.Delete(c.Request().Context(), principalOf(c), id); err != nil {
+		return err
 	}
 
 	return c.NoContent(http.StatusNoContent)
 }
 
 func (h *MessageHandler) GetAll(c echo.Context) error {
-	messages, err := h.service.GetAll()
+	q, err := parseListQuery(c)
+	if err != nil {
+		return err
+	}
+	messages, total, err := h.service.List(c.Request().Context(), q)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return err
+	}
+	// Only the messages of a news item are counted, see MessageService.List
+	if total < 0 {
+		setOpenPageHeaders(c, q, len(messages))
+	} else {
+		setPageHeaders(c, q, total)
 	}
 	return c.JSON(http.StatusOK, messages)
 }
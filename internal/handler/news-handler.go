@@ -2,106 +2,79 @@ package handler
 
 import (
 	"net/http"
-	"strconv"
 
+	"RESTAPI/internal/access"
 	"RESTAPI/internal/dto"
-	"RESTAPI/internal/service"
-	"strings"
 
-	"github.com/go-playground/validator/v10"
 	"github.com/labstack/echo/v4"
 )
 
 type NewsHandler struct {
-	service *service.NewsService
+	service *access.NewsService
 }
 
-func NewNewsHandler(service *service.NewsService) *NewsHandler {
+func NewNewsHandler(service *access.NewsService) *NewsHandler {
 	return &NewsHandler{service: service}
 }
 
 func (h *NewsHandler) Create(c echo.Context) error {
 	var req dto.NewsRequestTo
 	if err := c.Bind(&req); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request format"})
+		return errInvalidRequest
 	}
 
-	if err := validator.New().Struct(req); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	if err := c.Validate(&req); err != nil {
+		return err
 	}
 
-	resp, err := h.service.Create(req)
+	resp, err := h.service.Create(c.Request().Context(), principalOf(c), req)
 	if err != nil {
-		// Handle different types of errors with appropriate status codes
-		if strings.Contains(err.Error(), "writer not found") {
-			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
-		} else if strings.Contains(err.Error(), "already exists") {
-			return c.JSON(http.StatusForbidden, map[string]string{"error": err.Error()})
-		}
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return err
 	}
 
 	return c.JSON(http.StatusCreated, resp)
 }
 
 func (h *NewsHandler) GetById(c echo.Context) error {
-	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
-	if err != nil || id <= 0 {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid ID format"})
+	id, err := parseID(c)
+	if err != nil {
+		return err
 	}
-	resp, err := h.service.GetById(id)
+	resp, err := h.service.GetById(c.Request().Context(), id)
 	if err != nil {
-		return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+		return err
 	}
 	return c.JSON(http.StatusOK, resp)
 }
 
 func (h *NewsHandler) Update(c echo.Context) error {
-	/*id, err := strconv.ParseInt(c.Param("id"), 10, 64)
-	if err != nil || id <= 0 {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid ID format"})
-	}*/
-
 	var req dto.NewsUpdateRequestTo
 	if err := c.Bind(&req); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request format"})
+		return errInvalidRequest
 	}
 
-	var Validate = validator.New()
-
 	// Валидация входных данных
-	if err := Validate.Struct(&req); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	if err := c.Validate(&req); err != nil {
+		return err
 	}
 
-	resp, err := h.service.Update(req)
+	resp, err := h.service.Update(c.Request().Context(), principalOf(c), req)
 	if err != nil {
-		if err.Error() == "news not found" {
-			return c.JSON(http.StatusNotFound, map[string]string{"error": "News not found"})
-		}
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return err
 	}
 	return c.JSON(http.StatusOK, resp)
 }
 
 // Delete handles news deletion requests
 func (h *NewsHandler) Delete(c echo.Context) error {
-	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
-	if err != nil || id <= 0 {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid ID format"})
-	}
-
-	// Try to get the news first to check if it exists
-	_, err = h.service.GetById(id)
+	id, err := parseID(c)
 	if err != nil {
-		// If the news doesn't exist, return 404
-		return c.JSON(http.StatusNotFound, map[string]string{"error": "News not found"})
+		return err
 	}
 
-	// Delete the news
-	err = h.service.Delete(id)
-	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	// Delete the news; a missing news item is reported as 404
+	if err := h.service.Delete(c.Request().Context(), principalOf(c), id); err != nil {
+		return err
 	}
 
 	// Return 204 No Content for successful deletion
@@ -109,9 +82,32 @@ func (h *NewsHandler) Delete(c echo.Context) error {
 }
 
 func (h *NewsHandler) GetAll(c echo.Context) error {
-	newsList, err := h.service.GetAll()
+	if isCursorQuery(c) {
+		return h.getAfter(c)
+	}
+
+	q, err := parseListQuery(c)
+	if err != nil {
+		return err
+	}
+	newsList, total, err := h.service.List(c.Request().Context(), q)
+	if err != nil {
+		return err
+	}
+	setPageHeaders(c, q, total)
+	return c.JSON(http.StatusOK, newsList)
+}
+
+// getAfter serves keyset pages: ?after=<cursor>&limit=
+func (h *NewsHandler) getAfter(c echo.Context) error {
+	after, limit, filter, err := parseCursorQuery(c)
+	if err != nil {
+		return err
+	}
+	newsList, next, err := h.service.ListAfter(c.Request().Context(), after, limit, filter)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return err
 	}
+	setCursorHeaders(c, limit, next)
 	return c.JSON(http.StatusOK, newsList)
 }
@@ -0,0 +1,164 @@
+package handler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"RESTAPI/internal/apperr"
+	"RESTAPI/internal/repository"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+
+	// pagedPrefix is the API version whose lists are paged even without page or pageSize
+	pagedPrefix = "/api/v2.0/"
+)
+
+// reservedListParams are query parameters that control paging rather than filtering
+var reservedListParams = map[string]bool{
+	"page":     true,
+	"pageSize": true,
+	"sort":     true,
+	"after":    true,
+	"limit":    true,
+}
+
+// parseListQuery reads ?page=&pageSize=&sort= and treats every other
+// parameter as a filter. Field names are checked later by the repository.
+// Under /api/v2.0 a list is always paged, with defaultPageSize items unless
+// pageSize says otherwise. Under /api/v1.0 the whole collection is returned,
+// as before paging existed, unless page or pageSize is given.
+func parseListQuery(c echo.Context) (repository.ListQuery, error) {
+	params := c.QueryParams()
+	q := repository.ListQuery{Page: 1, Filter: map[string]string{}}
+	if strings.HasPrefix(c.Path(), pagedPrefix) || params.Has("page") || params.Has("pageSize") {
+		q.PageSize = defaultPageSize
+	}
+
+	if v := params.Get("page"); v != "" {
+		page, err := strconv.Atoi(v)
+		if err != nil || page < 1 {
+			return q, apperr.Validation("page must be a positive integer",
+				apperr.FieldError{Field: "page", Rule: "min", Param: "1"})
+		}
+		q.Page = page
+	}
+	if v := params.Get("pageSize"); v != "" {
+		size, err := strconv.Atoi(v)
+		if err != nil || size < 1 || size > maxPageSize {
+			return q, apperr.Validation(fmt.Sprintf("pageSize must be between 1 and %d", maxPageSize),
+				apperr.FieldError{Field: "pageSize", Rule: "max", Param: strconv.Itoa(maxPageSize)})
+		}
+		q.PageSize = size
+	}
+	if v := params.Get("sort"); v != "" {
+		q.Sort = strings.Split(v, ",")
+	}
+
+	for name, values := range params {
+		if reservedListParams[name] || len(values) == 0 {
+			continue
+		}
+		q.Filter[name] = values[0]
+	}
+	return q, nil
+}
+
+// isCursorQuery reports whether the client asked for keyset pagination
+func isCursorQuery(c echo.Context) bool {
+	params := c.QueryParams()
+	return params.Has("after") || params.Has("limit")
+}
+
+// parseCursorQuery reads ?after=<cursor>&limit= and the remaining filters
+func parseCursorQuery(c echo.Context) (string, int, map[string]string, error) {
+	params := c.QueryParams()
+	limit := defaultPageSize
+	if v := params.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxPageSize {
+			return "", 0, nil, apperr.Validation(fmt.Sprintf("limit must be between 1 and %d", maxPageSize),
+				apperr.FieldError{Field: "limit", Rule: "max", Param: strconv.Itoa(maxPageSize)})
+		}
+		limit = n
+	}
+
+	filter := map[string]string{}
+	for name, values := range params {
+		if reservedListParams[name] || len(values) == 0 {
+			continue
+		}
+		filter[name] = values[0]
+	}
+	return params.Get("after"), limit, filter, nil
+}
+
+// setCursorHeaders exposes the next cursor and a ready-made link to the next page
+func setCursorHeaders(c echo.Context, limit int, next string) {
+	if next == "" {
+		return
+	}
+	u := *c.Request().URL
+	values := u.Query()
+	values.Set("after", next)
+	values.Set("limit", strconv.Itoa(limit))
+	u.RawQuery = values.Encode()
+
+	c.Response().Header().Set("X-Next-Cursor", next)
+	c.Response().Header().Set("X-Next-Page", u.RequestURI())
+	c.Response().Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, u.RequestURI()))
+}
+
+// setPageHeaders exposes the total count and RFC 8288 navigation links
+func setPageHeaders(c echo.Context, q repository.ListQuery, total int64) {
+	c.Response().Header().Set("X-Total-Count", strconv.FormatInt(total, 10))
+	if q.PageSize <= 0 {
+		return
+	}
+
+	last := int((total + int64(q.PageSize) - 1) / int64(q.PageSize))
+	if last < 1 {
+		last = 1
+	}
+	setPageLinks(c, q, last, q.Page < last)
+}
+
+// setOpenPageHeaders exposes the navigation links of a collection whose total
+// is unknown: there is no last page, and a next page is linked while pages are full
+func setOpenPageHeaders(c echo.Context, q repository.ListQuery, count int) {
+	if q.PageSize <= 0 {
+		return
+	}
+	setPageLinks(c, q, 0, count == q.PageSize)
+}
+
+// setPageLinks sets the Link header, with a last link unless last is 0
+func setPageLinks(c echo.Context, q repository.ListQuery, last int, hasNext bool) {
+	pageURL := func(page int) string {
+		u := *c.Request().URL
+		values := u.Query()
+		values.Set("page", strconv.Itoa(page))
+		values.Set("pageSize", strconv.Itoa(q.PageSize))
+		u.RawQuery = values.Encode()
+		return u.RequestURI()
+	}
+
+	links := []string{fmt.Sprintf(`<%s>; rel="first"`, pageURL(1))}
+	if last > 0 {
+		links = append(links, fmt.Sprintf(`<%s>; rel="last"`, pageURL(last)))
+	}
+	if q.Page > 1 {
+		links = append(links, fmt.Sprintf(`<%s>; rel="prev"`, pageURL(q.Page-1)))
+	}
+	if hasNext {
+		next := pageURL(q.Page + 1)
+		links = append(links, fmt.Sprintf(`<%s>; rel="next"`, next))
+		c.Response().Header().Set("X-Next-Page", next)
+	}
+	c.Response().Header().Set("Link", strings.Join(links, ", "))
+}
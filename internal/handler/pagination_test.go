@@ -0,0 +1,223 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"RESTAPI/internal/apperr"
+	"RESTAPI/internal/repository"
+
+	"github.com/labstack/echo/v4"
+)
+
+func newContext(target string) (echo.Context, *httptest.ResponseRecorder) {
+	rec := httptest.NewRecorder()
+	return echo.New().NewContext(httptest.NewRequest(http.MethodGet, target, nil), rec), rec
+}
+
+func TestParseListQuery(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		query   string
+		want    repository.ListQuery
+		wantErr bool
+	}{
+		{
+			name:  "v1 lists the whole collection",
+			path:  "/api/v1.0/news",
+			query: "",
+			want:  repository.ListQuery{Page: 1, Filter: map[string]string{}},
+		},
+		{
+			name:  "v1 pages when asked",
+			path:  "/api/v1.0/news",
+			query: "page=2",
+			want:  repository.ListQuery{Page: 2, PageSize: defaultPageSize, Filter: map[string]string{}},
+		},
+		{
+			name:  "v2 always pages",
+			path:  "/api/v2.0/news",
+			query: "",
+			want:  repository.ListQuery{Page: 1, PageSize: defaultPageSize, Filter: map[string]string{}},
+		},
+		{
+			name:  "page, size and sort",
+			query: "page=3&pageSize=50&sort=-created,title",
+			want:  repository.ListQuery{Page: 3, PageSize: 50, Filter: map[string]string{}, Sort: []string{"-created", "title"}},
+		},
+		{
+			name:  "filters",
+			query: "writerId=4&title=Go&title=ignored",
+			want:  repository.ListQuery{Page: 1, Filter: map[string]string{"writerId": "4", "title": "Go"}},
+		},
+		{
+			name:  "cursor parameters are not filters",
+			query: "after=abc&limit=5&id=1",
+			want:  repository.ListQuery{Page: 1, Filter: map[string]string{"id": "1"}},
+		},
+		{name: "page zero", query: "page=0", wantErr: true},
+		{name: "page size too large", query: "pageSize=101", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := newContext("/news?" + tt.query)
+			c.SetPath(tt.path)
+			got, err := parseListQuery(c)
+			if tt.wantErr {
+				if apperr.KindOf(err) != apperr.KindValidation {
+					t.Fatalf("parseListQuery() error = %v, want a validation error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseListQuery() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("parseListQuery() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCursorQuery(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      string
+		wantAfter  string
+		wantLimit  int
+		wantFilter map[string]string
+		wantErr    bool
+	}{
+		{
+			name:       "cursor and limit",
+			query:      "after=eyJpIjoxfQ&limit=10",
+			wantAfter:  "eyJpIjoxfQ",
+			wantLimit:  10,
+			wantFilter: map[string]string{},
+		},
+		{
+			name:       "filters",
+			query:      "after=x&writerId=2&page=4&sort=id",
+			wantAfter:  "x",
+			wantLimit:  defaultPageSize,
+			wantFilter: map[string]string{"writerId": "2"},
+		},
+		{name: "limit too large", query: "limit=101", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := newContext("/news?" + tt.query)
+			after, limit, filter, err := parseCursorQuery(c)
+			if tt.wantErr {
+				if apperr.KindOf(err) != apperr.KindValidation {
+					t.Fatalf("parseCursorQuery() error = %v, want a validation error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseCursorQuery() error = %v", err)
+			}
+			if after != tt.wantAfter || limit != tt.wantLimit || !reflect.DeepEqual(filter, tt.wantFilter) {
+				t.Fatalf("parseCursorQuery() = %q, %d, %v, want %q, %d, %v",
+					after, limit, filter, tt.wantAfter, tt.wantLimit, tt.wantFilter)
+			}
+		})
+	}
+}
+
+func TestIsCursorQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		want  bool
+	}{
+		{"", false},
+		{"page=2&pageSize=10", false},
+		{"after=abc", true},
+	}
+	for _, tt := range tests {
+		c, _ := newContext("/news?" + tt.query)
+		if got := isCursorQuery(c); got != tt.want {
+			t.Errorf("isCursorQuery(%q) = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestSetPageHeaders(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     repository.ListQuery
+		total     int64
+		wantLink  string
+		wantNext  string
+		wantTotal string
+	}{
+		{
+			name:      "first of three pages",
+			query:     repository.ListQuery{Page: 1, PageSize: 10},
+			total:     25,
+			wantLink:  `</news?page=1&pageSize=10>; rel="first", </news?page=3&pageSize=10>; rel="last", </news?page=2&pageSize=10>; rel="next"`,
+			wantNext:  "/news?page=2&pageSize=10",
+			wantTotal: "25",
+		},
+		{
+			name:      "empty collection",
+			query:     repository.ListQuery{Page: 1, PageSize: 10},
+			total:     0,
+			wantLink:  `</news?page=1&pageSize=10>; rel="first", </news?page=1&pageSize=10>; rel="last"`,
+			wantTotal: "0",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, rec := newContext("/news")
+			setPageHeaders(c, tt.query, tt.total)
+			header := rec.Header()
+			if got := header.Get("X-Total-Count"); got != tt.wantTotal {
+				t.Errorf("X-Total-Count = %q, want %q", got, tt.wantTotal)
+			}
+			if got := header.Get("Link"); got != tt.wantLink {
+				t.Errorf("Link = %q, want %q", got, tt.wantLink)
+			}
+			if got := header.Get("X-Next-Page"); got != tt.wantNext {
+				t.Errorf("X-Next-Page = %q, want %q", got, tt.wantNext)
+			}
+		})
+	}
+}
+
+func TestSetOpenPageHeaders(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    repository.ListQuery
+		count    int
+		wantLink string
+	}{
+		{
+			name:     "full page links the next one",
+			query:    repository.ListQuery{Page: 2, PageSize: 10},
+			count:    10,
+			wantLink: `</messages?page=1&pageSize=10>; rel="first", </messages?page=1&pageSize=10>; rel="prev", </messages?page=3&pageSize=10>; rel="next"`,
+		},
+		{
+			name:     "short page is the last one",
+			query:    repository.ListQuery{Page: 1, PageSize: 10},
+			count:    4,
+			wantLink: `</messages?page=1&pageSize=10>; rel="first"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, rec := newContext("/messages")
+			setOpenPageHeaders(c, tt.query, tt.count)
+			if got := rec.Header().Get("Link"); got != tt.wantLink {
+				t.Errorf("Link = %q, want %q", got, tt.wantLink)
+			}
+			if got := rec.Header().Get("X-Total-Count"); got != "" {
+				t.Errorf("X-Total-Count = %q, want none", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+// Package idgen generates Snowflake-style IDs: unique across the nodes of a
+// service without coordination and ordered by the time they were generated.
+//
+// An ID is a positive int64 made of, from the highest bit:
+//
+//	41 bits  milliseconds since Epoch (about 69 years)
+//	10 bits  node ID, 0..MaxNode
+//	12 bits  sequence within the millisecond
+//
+// Every process generating IDs for the same table needs its own node ID.
+package idgen
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	nodeBits     = 10
+	sequenceBits = 12
+
+	// MaxNode is the highest node ID
+	MaxNode = 1<<nodeBits - 1
+
+	maxSequence = 1<<sequenceBits - 1
+	timeShift   = nodeBits + sequenceBits
+)
+
+// Epoch is the time of ID zero
+var Epoch = time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// Generator generates IDs for one node; it is safe for concurrent use
+type Generator struct {
+	node int64
+	now  func() time.Time
+
+	mu       sync.Mutex
+	last     int64 // milliseconds since Epoch of the last ID
+	sequence int64
+}
+
+// New creates the generator of a node
+func New(node int64) (*Generator, error) {
+	if node < 0 || node > MaxNode {
+		return nil, fmt.Errorf("node ID %d out of range 0..%d", node, MaxNode)
+	}
+	return &Generator{node: node, now: time.Now}, nil
+}
+
+// Next returns a new ID, greater than every ID the generator returned before.
+// If the clock goes back, IDs continue from the last time used; if more than
+// 4096 IDs are needed in a millisecond, the next millisecond is borrowed.
+func (g *Generator) Next() int64 {
+	ms := g.now().Sub(Epoch).Milliseconds()
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if ms > g.last {
+		g.last, g.sequence = ms, 0
+	} else if g.sequence < maxSequence {
+		g.sequence++
+	} else {
+		g.last, g.sequence = g.last+1, 0
+	}
+	return g.last<<timeShift | g.node<<sequenceBits | g.sequence
+}
+
+// Time returns when an ID was generated, to the millisecond
+func Time(id int64) time.Time {
+	return Epoch.Add(time.Duration(id>>timeShift) * time.Millisecond)
+}
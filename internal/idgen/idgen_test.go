@@ -0,0 +1,127 @@
+package idgen
+
+import (
+	"testing"
+	"time"
+)
+
+// clock is a settable time source for Generator.now
+type clock struct {
+	t time.Time
+}
+
+func (c *clock) now() time.Time { return c.t }
+
+func newGenerator(t *testing.T, node int64, c *clock) *Generator {
+	t.Helper()
+	g, err := New(node)
+	if err != nil {
+		t.Fatalf("New(%d): %v", node, err)
+	}
+	g.now = c.now
+	return g
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		node    int64
+		wantErr bool
+	}{
+		{0, false},
+		{-1, true},
+		{MaxNode + 1, true},
+	}
+	for _, tt := range tests {
+		_, err := New(tt.node)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("New(%d) error = %v, want error %v", tt.node, err, tt.wantErr)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	start := Epoch.Add(time.Hour)
+	tests := []struct {
+		name string
+		// steps move the clock before each ID
+		steps []time.Duration
+		// wantMs are the milliseconds since start encoded in the IDs
+		wantMs []int64
+		// wantSeq are the sequences encoded in the IDs
+		wantSeq []int64
+	}{
+		{
+			name:    "clock moves forward",
+			steps:   []time.Duration{0, time.Millisecond, 5 * time.Millisecond},
+			wantMs:  []int64{0, 1, 6},
+			wantSeq: []int64{0, 0, 0},
+		},
+		{
+			name:    "same millisecond",
+			steps:   []time.Duration{0, 0, 0},
+			wantMs:  []int64{0, 0, 0},
+			wantSeq: []int64{0, 1, 2},
+		},
+		{
+			name:    "clock goes back",
+			steps:   []time.Duration{0, 10 * time.Millisecond, -5 * time.Millisecond, -time.Second},
+			wantMs:  []int64{0, 10, 10, 10},
+			wantSeq: []int64{0, 0, 1, 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &clock{t: start}
+			g := newGenerator(t, 7, c)
+			startMs := start.Sub(Epoch).Milliseconds()
+
+			var prev int64
+			for i, step := range tt.steps {
+				c.t = c.t.Add(step)
+				id := g.Next()
+				if id <= prev {
+					t.Fatalf("ID %d = %d, not greater than %d", i, id, prev)
+				}
+				prev = id
+
+				if ms := id>>timeShift - startMs; ms != tt.wantMs[i] {
+					t.Errorf("ID %d: millisecond %d, want %d", i, ms, tt.wantMs[i])
+				}
+				if node := id >> sequenceBits & MaxNode; node != 7 {
+					t.Errorf("ID %d: node %d, want 7", i, node)
+				}
+				if seq := id & maxSequence; seq != tt.wantSeq[i] {
+					t.Errorf("ID %d: sequence %d, want %d", i, seq, tt.wantSeq[i])
+				}
+			}
+		})
+	}
+}
+
+func TestNextBorrowsMillisecond(t *testing.T) {
+	start := Epoch.Add(time.Hour)
+	g := newGenerator(t, 0, &clock{t: start})
+
+	var prev int64
+	for i := 0; i <= maxSequence; i++ {
+		prev = g.Next()
+	}
+	id := g.Next()
+	if id <= prev {
+		t.Fatalf("ID after a full millisecond = %d, not greater than %d", id, prev)
+	}
+	if want := start.Add(time.Millisecond); !Time(id).Equal(want) {
+		t.Fatalf("Time() = %v, want the borrowed millisecond %v", Time(id), want)
+	}
+	if seq := id & maxSequence; seq != 0 {
+		t.Fatalf("sequence = %d, want 0", seq)
+	}
+}
+
+func TestTime(t *testing.T) {
+	at := Epoch.Add(36*time.Hour + 123*time.Millisecond)
+	g := newGenerator(t, MaxNode, &clock{t: at.Add(456 * time.Microsecond)})
+	if got := Time(g.Next()); !got.Equal(at) {
+		t.Fatalf("Time() = %v, want %v", got, at)
+	}
+}
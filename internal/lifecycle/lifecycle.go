@@ -0,0 +1,113 @@
+// Package lifecycle runs the long-lived parts of a service and shuts them
+// down in order when SIGINT or SIGTERM arrives.
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// hook is a named shutdown step
+type hook struct {
+	name string
+	stop func(ctx context.Context) error
+}
+
+// Manager collects shutdown hooks and the components to wait for.
+// Hooks run in reverse registration order, like deferred calls: register a
+// resource right after creating it and it is closed after everything that
+// was built on top of it.
+type Manager struct {
+	timeout time.Duration
+
+	mu    sync.Mutex
+	hooks []hook
+
+	failed   chan error
+	once     sync.Once
+	shutdown error
+}
+
+// New creates a Manager whose shutdown must finish within timeout
+func New(timeout time.Duration) *Manager {
+	return &Manager{
+		timeout: timeout,
+		failed:  make(chan error, 1),
+	}
+}
+
+// OnShutdown registers a shutdown step
+func (m *Manager) OnShutdown(name string, stop func(ctx context.Context) error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.hooks = append(m.hooks, hook{name: name, stop: stop})
+}
+
+// Go runs a blocking component, such as an HTTP server, in the background.
+// If it returns an error the whole service shuts down.
+func (m *Manager) Go(name string, run func() error) {
+	go func() {
+		if err := run(); err != nil {
+			select {
+			case m.failed <- fmt.Errorf("%s: %w", name, err):
+			default:
+			}
+		}
+	}()
+}
+
+// Wait blocks until SIGINT/SIGTERM arrives or a component fails, then shuts
+// down. It returns the component failure, or the first shutdown error.
+func (m *Manager) Wait() error {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	var cause error
+	select {
+	case sig := <-signals:
+		slog.Info("shutting down", slog.String("signal", sig.String()))
+	case cause = <-m.failed:
+		slog.Error("shutting down after failure", slog.Any("error", cause))
+	}
+
+	if err := m.Shutdown(); cause == nil {
+		cause = err
+	}
+	return cause
+}
+
+// Shutdown runs the hooks once, newest first, within the timeout.
+// Every hook runs even if an earlier one fails or the deadline passes;
+// the hooks receive the shared deadline through ctx.
+func (m *Manager) Shutdown() error {
+	m.once.Do(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+		defer cancel()
+
+		m.mu.Lock()
+		hooks := m.hooks
+		m.mu.Unlock()
+
+		var errs []error
+		for i := len(hooks) - 1; i >= 0; i-- {
+			h := hooks[i]
+			start := time.Now()
+			if err := h.stop(ctx); err != nil {
+				slog.Error("shutdown failed", slog.String("component", h.name), slog.Any("error", err))
+				errs = append(errs, fmt.Errorf("%s: %w", h.name, err))
+				continue
+			}
+			slog.Info("stopped", slog.String("component", h.name), slog.Int64("duration_ms", time.Since(start).Milliseconds()))
+		}
+		m.shutdown = errors.Join(errs...)
+	})
+	return m.shutdown
+}
@@ -0,0 +1,139 @@
+// Package logging configures the process-wide slog logger. Every record
+// logged with a context carries the request ID and the active trace and span
+// IDs, and sensitive attributes such as message content are redacted.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+// Redacted replaces the value of an attribute that must not reach the logs
+const Redacted = "[redacted]"
+
+// ContentKey is the attribute key for user-written text, e.g. message content.
+// Its value is redacted unless Options.LogContent is set.
+const ContentKey = "content"
+
+// secretKeys are redacted regardless of the options
+var secretKeys = map[string]bool{
+	"password":      true,
+	"token":         true,
+	"access_token":  true,
+	"refresh_token": true,
+	"authorization": true,
+}
+
+// Options selects the log format and verbosity
+type Options struct {
+	// Level is "debug", "info", "warn" or "error"
+	Level string
+	// Format is "json" or "text"
+	Format string
+	// LogContent disables redaction of ContentKey attributes
+	LogContent bool
+}
+
+// Setup installs the default slog logger, which the standard log package
+// writes through as well
+func Setup(opts Options) error {
+	logger, err := New(os.Stderr, opts)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(logger)
+	return nil
+}
+
+// New builds a logger writing to w
+func New(w io.Writer, opts Options) (*slog.Logger, error) {
+	var level slog.Level
+	if opts.Level != "" {
+		if err := level.UnmarshalText([]byte(opts.Level)); err != nil {
+			return nil, fmt.Errorf("invalid log level %q", opts.Level)
+		}
+	}
+
+	handlerOpts := &slog.HandlerOptions{
+		Level:       level,
+		ReplaceAttr: redact(opts.LogContent),
+	}
+	var handler slog.Handler
+	switch strings.ToLower(opts.Format) {
+	case FormatJSON, "":
+		handler = slog.NewJSONHandler(w, handlerOpts)
+	case FormatText:
+		handler = slog.NewTextHandler(w, handlerOpts)
+	default:
+		return nil, fmt.Errorf("unknown log format %q", opts.Format)
+	}
+	return slog.New(contextHandler{handler}), nil
+}
+
+// redact masks secrets and, unless logContent is set, user content
+func redact(logContent bool) func([]string, slog.Attr) slog.Attr {
+	return func(_ []string, a slog.Attr) slog.Attr {
+		key := strings.ToLower(a.Key)
+		if secretKeys[key] || (key == ContentKey && !logContent) {
+			return slog.String(a.Key, Redacted)
+		}
+		return a
+	}
+}
+
+// Content returns the attribute for user-written text; see ContentKey
+func Content(text string) slog.Attr {
+	return slog.String(ContentKey, text)
+}
+
+// contextHandler adds the request and trace IDs found in the record's context
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if ctx != nil {
+		if id := RequestID(ctx); id != "" {
+			r.AddAttrs(slog.String("request_id", id))
+		}
+		if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+			r.AddAttrs(
+				slog.String("trace_id", sc.TraceID().String()),
+				slog.String("span_id", sc.SpanID().String()),
+			)
+		}
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+type requestIDKey struct{}
+
+// WithRequestID returns a copy of ctx carrying the request ID
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the request ID stored in ctx, or ""
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
@@ -0,0 +1,7 @@
+package metrics
+
+// ObserveCacheLookup counts a cache lookup of a kind of entry, e.g. "message";
+// result is "hit", "miss" or "error"
+func ObserveCacheLookup(kind, result string) {
+	cacheLookups.WithLabelValues(kind, result).Inc()
+}
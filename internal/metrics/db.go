@@ -0,0 +1,103 @@
+package metrics
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/gocql/gocql"
+	"gorm.io/gorm"
+)
+
+const gormStartKey = "metrics:start"
+
+// GormPlugin times every gorm operation
+type GormPlugin struct{}
+
+func (GormPlugin) Name() string {
+	return "metrics"
+}
+
+// Initialize registers before/after callbacks around each gorm processor
+func (GormPlugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	steps := []struct {
+		operation string
+		before    func(string, func(*gorm.DB)) error
+		after     func(string, func(*gorm.DB)) error
+	}{
+		{"create", cb.Create().Before("gorm:create").Register, cb.Create().After("gorm:create").Register},
+		{"query", cb.Query().Before("gorm:query").Register, cb.Query().After("gorm:query").Register},
+		{"update", cb.Update().Before("gorm:update").Register, cb.Update().After("gorm:update").Register},
+		{"delete", cb.Delete().Before("gorm:delete").Register, cb.Delete().After("gorm:delete").Register},
+		{"row", cb.Row().Before("gorm:row").Register, cb.Row().After("gorm:row").Register},
+		{"raw", cb.Raw().Before("gorm:raw").Register, cb.Raw().After("gorm:raw").Register},
+	}
+	for _, step := range steps {
+		if err := step.before("metrics:before_"+step.operation, startGorm); err != nil {
+			return err
+		}
+		if err := step.after("metrics:after_"+step.operation, finishGorm(step.operation)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func startGorm(db *gorm.DB) {
+	db.InstanceSet(gormStartKey, time.Now())
+}
+
+func finishGorm(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		value, ok := db.InstanceGet(gormStartKey)
+		if !ok {
+			return
+		}
+		start := value.(time.Time)
+
+		table := db.Statement.Table
+		if table == "" {
+			table = "unknown"
+		}
+		dbDuration.WithLabelValues("postgres", operation, table, result(db.Error)).Observe(seconds(start))
+	}
+}
+
+// CassandraObserver times every gocql query; set it as ClusterConfig.QueryObserver
+type CassandraObserver struct{}
+
+func (CassandraObserver) ObserveQuery(_ context.Context, q gocql.ObservedQuery) {
+	operation, table := describeCQL(q.Statement)
+	dbDuration.WithLabelValues("cassandra", operation, table, result(q.Err)).Observe(q.End.Sub(q.Start).Seconds())
+}
+
+// describeCQL extracts the operation and table of a simple CQL statement
+func describeCQL(stmt string) (operation, table string) {
+	words := strings.Fields(strings.ToLower(stmt))
+	if len(words) == 0 {
+		return "unknown", "unknown"
+	}
+	operation, table = words[0], "unknown"
+
+	var marker string
+	switch operation {
+	case "select", "delete":
+		marker = "from"
+	case "insert":
+		marker = "into"
+	case "update":
+		if len(words) > 1 {
+			table = words[1]
+		}
+	}
+	if marker != "" {
+		for i, w := range words[:len(words)-1] {
+			if w == marker {
+				table = words[i+1]
+				break
+			}
+		}
+	}
+	return operation, strings.Trim(table, `"(`)
+}
@@ -0,0 +1,42 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+)
+
+// ObserveProduce records a synchronous produce call started at start
+func ObserveProduce(topic string, start time.Time, err error) {
+	kafkaProduceDuration.WithLabelValues(topic, result(err)).Observe(seconds(start))
+}
+
+// ObserveConsumed counts a consumed message by the result of processing it
+func ObserveConsumed(topic string, err error) {
+	kafkaConsumed.WithLabelValues(topic, result(err)).Inc()
+}
+
+// SetConsumerLag records how far the consumer is behind the partition high-water mark
+// after consuming the message at offset
+func SetConsumerLag(topic string, partition int32, highWaterMark, offset int64) {
+	lag := highWaterMark - offset - 1
+	if lag < 0 {
+		lag = 0
+	}
+	kafkaConsumerLag.WithLabelValues(topic, strconv.Itoa(int(partition))).Set(float64(lag))
+}
+
+// ObserveModeration counts a moderation decision, e.g. APPROVE or DECLINE,
+// with the rule that declined the message; rule is empty for approved ones
+func ObserveModeration(state, rule string) {
+	moderated.WithLabelValues(state, rule).Inc()
+}
+
+// ObserveRetry counts a retry of a failed processing stage
+func ObserveRetry(topic, stage string) {
+	kafkaRetries.WithLabelValues(topic, stage).Inc()
+}
+
+// ObserveDeadLetter counts a record sent to the dead-letter topic
+func ObserveDeadLetter(topic, stage string) {
+	kafkaDeadLetters.WithLabelValues(topic, stage).Inc()
+}
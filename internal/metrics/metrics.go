@@ -0,0 +1,95 @@
+// Package metrics defines the Prometheus metrics of both services and the
+// hooks that record them: HTTP middleware for Echo and gorilla/mux, a gorm
+// plugin, a gocql query observer and helpers for the Kafka and cache paths.
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "HTTP requests by route and status.",
+	}, []string{"method", "route", "status"})
+
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "HTTP request latency by route and status.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	dbDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "db_query_duration_seconds",
+		Help:    "Database query latency by database, operation and table.",
+		Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"db", "operation", "table", "result"})
+
+	kafkaProduceDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "kafka_produce_duration_seconds",
+		Help:    "Latency of synchronous Kafka produce calls.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"topic", "result"})
+
+	kafkaConsumed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "kafka_messages_consumed_total",
+		Help: "Kafka messages consumed by topic and processing result.",
+	}, []string{"topic", "result"})
+
+	kafkaRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "kafka_retries_total",
+		Help: "Retries of consumed Kafka messages by topic and failed stage.",
+	}, []string{"topic", "stage"})
+
+	kafkaDeadLetters = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "kafka_dead_letters_total",
+		Help: "Consumed Kafka messages sent to the dead-letter topic by topic and failed stage.",
+	}, []string{"topic", "stage"})
+
+	kafkaConsumerLag = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kafka_consumer_lag",
+		Help: "Messages between the last consumed offset and the partition high-water mark.",
+	}, []string{"topic", "partition"})
+
+	moderated = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "messages_moderated_total",
+		Help: "Messages by moderation decision and the rule that declined them.",
+	}, []string{"state", "rule"})
+
+	outboxRelayFailures = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "outbox_relay_failures_total",
+		Help: "Runs of the outbox relay that stopped at a failed read or publish.",
+	})
+
+	outboxPendingAge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "outbox_oldest_pending_age_seconds",
+		Help: "Age of the oldest outbox event not yet published; 0 when the outbox is drained.",
+	})
+
+	cacheLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cache_lookups_total",
+		Help: "Cache lookups by kind of entry and result: hit, miss or error.",
+	}, []string{"kind", "result"})
+)
+
+// Handler serves the metrics in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// result labels an operation outcome
+func result(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "ok"
+}
+
+func seconds(start time.Time) float64 {
+	return time.Since(start).Seconds()
+}
@@ -0,0 +1,18 @@
+package metrics
+
+import "time"
+
+// ObserveOutboxFailure counts a relay run that stopped before the outbox was drained
+func ObserveOutboxFailure() {
+	outboxRelayFailures.Inc()
+}
+
+// SetOutboxPending records when the oldest unpublished event was written;
+// the zero time means there is none
+func SetOutboxPending(oldest time.Time) {
+	if oldest.IsZero() {
+		outboxPendingAge.Set(0)
+		return
+	}
+	outboxPendingAge.Set(seconds(oldest))
+}
@@ -0,0 +1,94 @@
+// Package outbox announces changes of publisher data to other systems through
+// Kafka. Services record an event in tbl_outbox inside the transaction that
+// makes the change, so an event exists exactly when the change was committed.
+// The Relay then publishes the events in the order they were written and
+// marks them sent. Delivery is at least once: consumers deduplicate by the
+// event ID.
+package outbox
+
+import (
+	"RESTAPI/internal/entity"
+	"RESTAPI/internal/logging"
+	"RESTAPI/internal/repository"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Aggregate is the kind of record an event is about
+type Aggregate string
+
+const (
+	News   Aggregate = "news"
+	Writer Aggregate = "writer"
+	Mark   Aggregate = "mark"
+)
+
+// Topic returns the Kafka topic of the aggregate's events, e.g. "news-events"
+func (a Aggregate) Topic() string {
+	return string(a) + "-events"
+}
+
+// Topics lists every topic the relay publishes to
+var Topics = []string{News.Topic(), Writer.Topic(), Mark.Topic()}
+
+// Changes recorded for an aggregate
+const (
+	Created = "created"
+	Updated = "updated"
+	Deleted = "deleted"
+)
+
+// Headers of a published event
+const (
+	EventIDHeader   = "X-Event-ID"
+	EventTypeHeader = "X-Event-Type"
+)
+
+// Envelope is the value of a published event. Type is "<aggregate>.<change>",
+// e.g. "news.updated"; Data is the aggregate as returned by the API, or only
+// its ID for a deleted one.
+type Envelope struct {
+	ID          int64           `json:"id"`
+	Type        string          `json:"type"`
+	AggregateID int64           `json:"aggregateId"`
+	OccurredAt  time.Time       `json:"occurredAt"`
+	Data        json.RawMessage `json:"data"`
+}
+
+// Recorder writes events to the outbox
+type Recorder struct {
+	repo *repository.OutboxRepository
+}
+
+func NewRecorder(repo *repository.OutboxRepository) *Recorder {
+	return &Recorder{repo: repo}
+}
+
+// Record stores an event about a change of the aggregate with the given ID.
+// ctx must carry the transaction of the change, see repository.Transactor.
+func (r *Recorder) Record(ctx context.Context, aggregate Aggregate, id int64, change string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s event: %w", aggregate, err)
+	}
+	return r.repo.Add(ctx, &entity.OutboxEvent{
+		AggregateType: string(aggregate),
+		AggregateID:   id,
+		EventType:     string(aggregate) + "." + change,
+		Topic:         aggregate.Topic(),
+		Payload:       payload,
+		RequestID:     logging.RequestID(ctx),
+	})
+}
+
+// deleted is the data of a deleted aggregate
+type deleted struct {
+	ID int64 `json:"id"`
+}
+
+// RecordDeleted stores an event about the deletion of the aggregate with the given ID
+func (r *Recorder) RecordDeleted(ctx context.Context, aggregate Aggregate, id int64) error {
+	return r.Record(ctx, aggregate, id, Deleted, deleted{ID: id})
+}
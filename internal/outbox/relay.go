@@ -0,0 +1,222 @@
+package outbox
+
+import (
+	"RESTAPI/internal/entity"
+	"RESTAPI/internal/logging"
+	"RESTAPI/internal/metrics"
+	"RESTAPI/internal/repository"
+	"RESTAPI/internal/tracing"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/IBM/sarama"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// cleanupInterval is how often sent events older than the retention are removed
+const cleanupInterval = time.Hour
+
+// Options tune the relay
+type Options struct {
+	// Interval between polls of the outbox
+	Interval time.Duration
+	// BatchSize is the most events published per transaction
+	BatchSize int
+	// Retention is how long sent events are kept; zero keeps them forever
+	Retention time.Duration
+}
+
+// Relay publishes outbox events to Kafka.
+//
+// Every instance of the service runs a relay, but a PostgreSQL advisory lock
+// lets only one of them publish at a time. Events are published one by one in
+// ID order and keyed by aggregate ID, so the events of an aggregate land on
+// one partition in the order they were written. Publishing stops at the first
+// failure and resumes from the same event on the next poll.
+type Relay struct {
+	repo     *repository.OutboxRepository
+	producer sarama.SyncProducer
+	opts     Options
+
+	lastCleanup time.Time
+	stopOnce    sync.Once
+	cancel      context.CancelFunc
+	done        chan struct{}
+}
+
+// NewRelay creates a relay publishing through its own producer
+func NewRelay(repo *repository.OutboxRepository, brokers []string, config *sarama.Config, opts Options) (*Relay, error) {
+	producer, err := sarama.NewSyncProducer(brokers, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create producer: %v", err)
+	}
+	return &Relay{
+		repo:     repo,
+		producer: producer,
+		opts:     opts,
+		done:     make(chan struct{}),
+	}, nil
+}
+
+// Start starts polling the outbox
+func (r *Relay) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+
+	go func() {
+		defer close(r.done)
+		ticker := time.NewTicker(r.opts.Interval)
+		defer ticker.Stop()
+		for {
+			r.drain(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop finishes the current batch and closes the producer before it returns or ctx expires
+func (r *Relay) Stop(ctx context.Context) error {
+	var err error
+	r.stopOnce.Do(func() {
+		if r.cancel != nil {
+			r.cancel()
+
+			select {
+			case <-r.done:
+			case <-ctx.Done():
+				slog.Warn("outbox relay did not stop in time", slog.Any("error", ctx.Err()))
+			}
+		}
+		err = r.producer.Close()
+	})
+	return err
+}
+
+// drain publishes batches until the outbox is empty, publishing fails or the
+// relay stops. A failure is counted and the age of the oldest unsent event is
+// exported, so that a relay stuck on an event shows up in the metrics.
+func (r *Relay) drain(ctx context.Context) {
+	defer r.observeBacklog(ctx)
+	for ctx.Err() == nil {
+		sent, err := r.relayBatch(ctx)
+		if err != nil {
+			metrics.ObserveOutboxFailure()
+			slog.ErrorContext(ctx, "outbox relay failed", slog.Any("error", err))
+			return
+		}
+		if sent < r.opts.BatchSize {
+			break
+		}
+	}
+	r.cleanup(ctx)
+}
+
+// observeBacklog exports the age of the oldest unsent event
+func (r *Relay) observeBacklog(ctx context.Context) {
+	if ctx.Err() != nil {
+		return
+	}
+	oldest, _, err := r.repo.OldestPending(ctx)
+	if err != nil {
+		slog.WarnContext(ctx, "failed to read the outbox backlog", slog.Any("error", err))
+		return
+	}
+	metrics.SetOutboxPending(oldest)
+}
+
+// relayBatch publishes one batch under the relay lock and returns how many events were sent
+func (r *Relay) relayBatch(ctx context.Context) (int, error) {
+	sent := 0
+	var publishErr error
+	_, err := r.repo.WithRelayLock(ctx, func(ctx context.Context) error {
+		events, err := r.repo.Pending(ctx, r.opts.BatchSize)
+		if err != nil {
+			return err
+		}
+
+		ids := make([]int64, 0, len(events))
+		for i := range events {
+			// A later event of the same aggregate must not overtake this one
+			if publishErr = r.publish(ctx, &events[i]); publishErr != nil {
+				break
+			}
+			ids = append(ids, events[i].ID)
+		}
+
+		// Events published so far are marked even if a later one failed
+		if err := r.repo.MarkSent(ctx, ids); err != nil {
+			return err
+		}
+		sent = len(ids)
+		return nil
+	})
+	if err != nil {
+		return sent, err
+	}
+	return sent, publishErr
+}
+
+// publish sends one event to its topic
+func (r *Relay) publish(ctx context.Context, event *entity.OutboxEvent) error {
+	value, err := json.Marshal(Envelope{
+		ID:          event.ID,
+		Type:        event.EventType,
+		AggregateID: event.AggregateID,
+		OccurredAt:  event.Created,
+		Data:        event.Payload,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode event %d: %w", event.ID, err)
+	}
+
+	msg := &sarama.ProducerMessage{
+		Topic: event.Topic,
+		Key:   sarama.StringEncoder(strconv.FormatInt(event.AggregateID, 10)),
+		Value: sarama.ByteEncoder(value),
+		Headers: []sarama.RecordHeader{
+			{Key: []byte(EventIDHeader), Value: []byte(strconv.FormatInt(event.ID, 10))},
+			{Key: []byte(EventTypeHeader), Value: []byte(event.EventType)},
+		},
+	}
+	if event.RequestID != "" {
+		msg.Headers = append(msg.Headers, sarama.RecordHeader{Key: []byte(logging.RequestIDHeader), Value: []byte(event.RequestID)})
+	}
+	_, span := tracing.StartProduce(ctx, msg)
+	defer span.End()
+
+	start := time.Now()
+	_, _, err = r.producer.SendMessage(msg)
+	metrics.ObserveProduce(msg.Topic, start, err)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("failed to publish event %d: %v", event.ID, err)
+	}
+	return nil
+}
+
+// cleanup removes old sent events at most once per cleanupInterval
+func (r *Relay) cleanup(ctx context.Context) {
+	if r.opts.Retention <= 0 || time.Since(r.lastCleanup) < cleanupInterval {
+		return
+	}
+	r.lastCleanup = time.Now()
+
+	removed, err := r.repo.DeleteSentBefore(ctx, time.Now().Add(-r.opts.Retention))
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to remove sent outbox events", slog.Any("error", err))
+		return
+	}
+	if removed > 0 {
+		slog.DebugContext(ctx, "sent outbox events removed", slog.Int64("count", removed))
+	}
+}
@@ -0,0 +1,167 @@
+package password
+
+import (
+	"errors"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// fastArgon2 keeps the tests quick; the encoding does not depend on the cost
+var fastArgon2 = Argon2Params{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 8, KeyLength: 16}
+
+func newHasher(t *testing.T, cfg Config) *Hasher {
+	t.Helper()
+	h, err := NewHasher(cfg)
+	if err != nil {
+		t.Fatalf("NewHasher: %v", err)
+	}
+	return h
+}
+
+func TestNewHasher(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr bool
+	}{
+		{"default", DefaultConfig(), false},
+		{"bcrypt", Config{Algorithm: AlgorithmBcrypt, BcryptCost: bcrypt.MinCost}, false},
+		{"bcrypt cost too low", Config{Algorithm: AlgorithmBcrypt, BcryptCost: bcrypt.MinCost - 1}, true},
+		{"argon2id zero memory", Config{Algorithm: AlgorithmArgon2id, Argon2: Argon2Params{Iterations: 1, Parallelism: 1, SaltLength: 8, KeyLength: 16}}, true},
+		{"unknown algorithm", Config{Algorithm: "md5"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewHasher(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewHasher() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestHashVerify(t *testing.T) {
+	configs := []struct {
+		name string
+		cfg  Config
+	}{
+		{"argon2id", Config{Algorithm: AlgorithmArgon2id, Argon2: fastArgon2}},
+		{"bcrypt", Config{Algorithm: AlgorithmBcrypt, BcryptCost: bcrypt.MinCost}},
+	}
+	for _, c := range configs {
+		t.Run(c.name, func(t *testing.T) {
+			h := newHasher(t, c.cfg)
+			encoded, err := h.Hash("s3cret")
+			if err != nil {
+				t.Fatalf("Hash: %v", err)
+			}
+			if encoded == "s3cret" {
+				t.Fatal("Hash returned the password")
+			}
+
+			tests := []struct {
+				password string
+				want     error
+			}{
+				{"s3cret", nil},
+				{"S3cret", ErrMismatch},
+			}
+			for _, tt := range tests {
+				if err := h.Verify(encoded, tt.password); !errors.Is(err, tt.want) {
+					t.Errorf("Verify(%q) = %v, want %v", tt.password, err, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestVerifyAcrossAlgorithms(t *testing.T) {
+	argon := newHasher(t, Config{Algorithm: AlgorithmArgon2id, Argon2: fastArgon2})
+	bcryptHasher := newHasher(t, Config{Algorithm: AlgorithmBcrypt, BcryptCost: bcrypt.MinCost})
+
+	argonHash, err := argon.Hash("s3cret")
+	if err != nil {
+		t.Fatalf("Hash: %v", err)
+	}
+	bcryptHash, err := bcryptHasher.Hash("s3cret")
+	if err != nil {
+		t.Fatalf("Hash: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		hasher   *Hasher
+		encoded  string
+		password string
+		want     error
+	}{
+		{"argon2id hash, bcrypt configured", bcryptHasher, argonHash, "s3cret", nil},
+		{"bcrypt hash, argon2id configured", argon, bcryptHash, "s3cret", nil},
+		{"legacy plain text", argon, "s3cret", "s3cret", nil},
+		{"legacy plain text mismatch", argon, "s3cret", "other", ErrMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.hasher.Verify(tt.encoded, tt.password); !errors.Is(err, tt.want) {
+				t.Fatalf("Verify() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyMalformedArgon2(t *testing.T) {
+	h := newHasher(t, Config{Algorithm: AlgorithmArgon2id, Argon2: fastArgon2})
+	tests := []struct {
+		name    string
+		encoded string
+	}{
+		{"missing key", "$argon2id$v=19$m=64,t=1,p=1$c2FsdHNhbHQ"},
+		{"wrong version", "$argon2id$v=16$m=64,t=1,p=1$c2FsdHNhbHQ$a2V5a2V5a2V5a2V5a2V5"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := h.Verify(tt.encoded, "s3cret")
+			if err == nil || errors.Is(err, ErrMismatch) {
+				t.Fatalf("Verify() = %v, want a format error", err)
+			}
+		})
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	current := Config{Algorithm: AlgorithmArgon2id, Argon2: fastArgon2}
+	cheaper := current
+	cheaper.Argon2.Iterations = 2
+	bcryptCfg := Config{Algorithm: AlgorithmBcrypt, BcryptCost: bcrypt.MinCost}
+	bcryptCostlier := Config{Algorithm: AlgorithmBcrypt, BcryptCost: bcrypt.MinCost + 1}
+
+	hash := func(cfg Config) string {
+		encoded, err := newHasher(t, cfg).Hash("s3cret")
+		if err != nil {
+			t.Fatalf("Hash: %v", err)
+		}
+		return encoded
+	}
+
+	tests := []struct {
+		name    string
+		cfg     Config
+		encoded string
+		want    bool
+	}{
+		{"same argon2id parameters", current, hash(current), false},
+		{"other iterations", current, hash(cheaper), true},
+		{"bcrypt hash, argon2id configured", current, hash(bcryptCfg), true},
+		{"plain text, argon2id configured", current, "s3cret", true},
+		{"same bcrypt cost", bcryptCfg, hash(bcryptCfg), false},
+		{"other bcrypt cost", bcryptCostlier, hash(bcryptCfg), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := newHasher(t, tt.cfg).NeedsRehash(tt.encoded); got != tt.want {
+				t.Fatalf("NeedsRehash() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package repository
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"RESTAPI/internal/apperr"
+)
+
+// ErrInvalidQuery is returned when a list query references a field that is
+// not exposed for filtering or sorting, or carries a malformed value
+var ErrInvalidQuery = apperr.Validation("invalid list query")
+
+// InvalidQuery returns a validation error wrapping ErrInvalidQuery and
+// explaining what is wrong; the explanation reaches the client
+func InvalidQuery(format string, args ...interface{}) *apperr.Error {
+	err := apperr.Validation(ErrInvalidQuery.Message + ": " + fmt.Sprintf(format, args...))
+	return err.Wrap(ErrInvalidQuery)
+}
+
+// FieldKind describes how a raw query value is converted before it reaches the database
+type FieldKind int
+
+const (
+	FieldString FieldKind = iota
+	FieldInt
+	FieldTime
+)
+
+// ListField maps a public (JSON) field name to its column
+type ListField struct {
+	Column string
+	Kind   FieldKind
+}
+
+// ListFields is the allow-list of fields a collection can be filtered and sorted by
+type ListFields map[string]ListField
+
+// ListQuery describes a page of a collection as requested by a client
+type ListQuery struct {
+	Page     int
+	PageSize int
+	Filter   map[string]string
+	Sort     []string
+}
+
+// filter converts public field names and raw values into a gorm condition map
+func (f ListFields) filter(raw map[string]string) (map[string]interface{}, error) {
+	filter := make(map[string]interface{}, len(raw))
+	for name, value := range raw {
+		field, ok := f[name]
+		if !ok {
+			return nil, InvalidQuery("unknown filter field %q", name)
+		}
+		switch field.Kind {
+		case FieldInt:
+			v, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, InvalidQuery("%s must be an integer", name)
+			}
+			filter[field.Column] = v
+		case FieldTime:
+			v, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return nil, InvalidQuery("%s must be an RFC 3339 timestamp", name)
+			}
+			filter[field.Column] = v
+		default:
+			filter[field.Column] = value
+		}
+	}
+	return filter, nil
+}
+
+// order builds an ORDER BY clause from "field" / "-field" entries.
+// Only columns from the allow-list ever reach the SQL text.
+func (f ListFields) order(sort []string) (string, error) {
+	clauses := make([]string, 0, len(sort)+1)
+	for _, key := range sort {
+		direction := "ASC"
+		if strings.HasPrefix(key, "-") {
+			direction = "DESC"
+			key = key[1:]
+		}
+		field, ok := f[key]
+		if !ok {
+			return "", InvalidQuery("unknown sort field %q", key)
+		}
+		clauses = append(clauses, field.Column+" "+direction)
+	}
+	// Keep pages stable when the requested order has ties
+	clauses = append(clauses, "id ASC")
+	return strings.Join(clauses, ", "), nil
+}
+
+// Query validates q against the allow-list and returns the requested page
+func (r *BaseRepository[T]) Query(ctx context.Context, fields ListFields, q ListQuery) ([]T, int64, error) {
+	filter, err := fields.filter(q.Filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	sort, err := fields.order(q.Sort)
+	if err != nil {
+		return nil, 0, err
+	}
+	return r.List(ctx, q.Page, q.PageSize, filter, sort)
+}
+
+// Cursor is a keyset position: the (created, id) pair of the last row a client has seen
+type Cursor struct {
+	Created time.Time `json:"c"`
+	ID      int64     `json:"i"`
+}
+
+// Encode returns the cursor as an opaque URL-safe token
+func (c Cursor) Encode() string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// DecodeCursor parses a token produced by Cursor.Encode
+func DecodeCursor(token string) (Cursor, error) {
+	var c Cursor
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return c, InvalidQuery("malformed cursor")
+	}
+	if err := json.Unmarshal(raw, &c); err != nil || c.ID <= 0 {
+		return c, InvalidQuery("malformed cursor")
+	}
+	return c, nil
+}
@@ -0,0 +1,125 @@
+package repository
+
+import (
+	"encoding/base64"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"RESTAPI/internal/apperr"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	tests := []Cursor{
+		{Created: time.Date(2025, time.March, 1, 12, 30, 0, 0, time.UTC), ID: 1},
+		{Created: time.Date(2024, time.January, 1, 0, 0, 0, 123456789, time.UTC), ID: 1 << 62},
+	}
+	for _, want := range tests {
+		token := want.Encode()
+		got, err := DecodeCursor(token)
+		if err != nil {
+			t.Fatalf("DecodeCursor(%q) error = %v", token, err)
+		}
+		if !got.Created.Equal(want.Created) || got.ID != want.ID {
+			t.Fatalf("DecodeCursor(%q) = %+v, want %+v", token, got, want)
+		}
+	}
+}
+
+func TestDecodeCursorMalformed(t *testing.T) {
+	encode := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"not base64", "!!!"},
+		{"not JSON", encode("cursor")},
+		{"negative ID", encode(`{"c":"2025-03-01T12:30:00Z","i":-1}`)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := DecodeCursor(tt.token)
+			if apperr.KindOf(err) != apperr.KindValidation {
+				t.Fatalf("DecodeCursor(%q) error = %v, want a validation error", tt.token, err)
+			}
+		})
+	}
+}
+
+var testFields = ListFields{
+	"id":       {Column: "id", Kind: FieldInt},
+	"title":    {Column: "title"},
+	"writerId": {Column: "writer_id", Kind: FieldInt},
+	"created":  {Column: "created", Kind: FieldTime},
+}
+
+func TestListFieldsFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     map[string]string
+		want    map[string]interface{}
+		wantErr bool
+	}{
+		{
+			name: "converts by kind",
+			raw:  map[string]string{"title": "Go", "writerId": "7", "created": "2025-03-01T12:30:00Z"},
+			want: map[string]interface{}{
+				"title":     "Go",
+				"writer_id": int64(7),
+				"created":   time.Date(2025, time.March, 1, 12, 30, 0, 0, time.UTC),
+			},
+		},
+		{name: "unknown field", raw: map[string]string{"password": "x"}, wantErr: true},
+		{name: "bad integer", raw: map[string]string{"writerId": "seven"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := testFields.filter(tt.raw)
+			if tt.wantErr {
+				if apperr.KindOf(err) != apperr.KindValidation || !errors.Is(err, ErrInvalidQuery) {
+					t.Fatalf("filter() error = %v, want ErrInvalidQuery", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("filter() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("filter() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListFieldsOrder(t *testing.T) {
+	tests := []struct {
+		name    string
+		sort    []string
+		want    string
+		wantErr bool
+	}{
+		{name: "default", want: "id ASC"},
+		{name: "ascending and descending", sort: []string{"-created", "title"}, want: "created DESC, title ASC, id ASC"},
+		{name: "unknown field", sort: []string{"password"}, wantErr: true},
+		{name: "column name is not a field", sort: []string{"writer_id"}, wantErr: true},
+		{name: "injection", sort: []string{"id; DROP TABLE tbl_news"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := testFields.order(tt.sort)
+			if tt.wantErr {
+				if apperr.KindOf(err) != apperr.KindValidation || !errors.Is(err, ErrInvalidQuery) {
+					t.Fatalf("order() error = %v, want ErrInvalidQuery", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("order() error = %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("order() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
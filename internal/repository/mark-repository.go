@@ -2,6 +2,7 @@ package repository
 
 import (
 	"RESTAPI/internal/entity"
+	"context"
 
 	"gorm.io/gorm"
 )
@@ -10,6 +11,12 @@ type MarkRepository struct {
 	BaseRepository *BaseRepository[entity.Mark]
 }
 
+// markFields lists the Mark fields exposed to collection filters and sorting
+var markFields = ListFields{
+	"id":   {Column: "id", Kind: FieldInt},
+	"name": {Column: "name", Kind: FieldString},
+}
+
 func NewMarkRepository(db *gorm.DB) *MarkRepository {
 	return &MarkRepository{
 		BaseRepository: NewBaseRepository[entity.Mark](db),
@@ -17,67 +24,77 @@ func NewMarkRepository(db *gorm.DB) *MarkRepository {
 }
 
 // Create создает метку
-func (r *MarkRepository) Create(mark *entity.Mark) error {
-	return r.BaseRepository.Create(mark)
+func (r *MarkRepository) Create(ctx context.Context, mark *entity.Mark) error {
+	return r.BaseRepository.Create(ctx, mark)
 }
 
 // GetById получает метку по ID
-func (r *MarkRepository) GetById(id int64) (entity.Mark, error) {
-	return r.BaseRepository.GetById(id)
+func (r *MarkRepository) GetById(ctx context.Context, id int64) (entity.Mark, error) {
+	return r.BaseRepository.GetById(ctx, id)
 }
 
 // Update обновляет метку
-func (r *MarkRepository) Update(mark *entity.Mark) error {
-	return r.BaseRepository.Update(mark)
+func (r *MarkRepository) Update(ctx context.Context, mark *entity.Mark) error {
+	return r.BaseRepository.Update(ctx, mark)
 }
 
 // Delete удаляет метку по ID
-func (r *MarkRepository) Delete(id int64) error {
-	return r.BaseRepository.Delete(id)
+func (r *MarkRepository) Delete(ctx context.Context, id int64) error {
+	return r.BaseRepository.Delete(ctx, id)
 }
 
 // GetAll возвращает все метки
-func (r *MarkRepository) GetAll() ([]entity.Mark, error) {
+func (r *MarkRepository) GetAll(ctx context.Context) ([]entity.Mark, error) {
 	var marks []entity.Mark
-	result := r.BaseRepository.db.Find(&marks)
+	result := r.BaseRepository.conn(ctx).Find(&marks)
 	if result.Error != nil {
 		return nil, result.Error
 	}
 	return marks, nil
 }
 
+// List возвращает страницу меток с фильтрацией и сортировкой
+func (r *MarkRepository) List(ctx context.Context, q ListQuery) ([]entity.Mark, int64, error) {
+	return r.BaseRepository.Query(ctx, markFields, q)
+}
+
 // Add this method to your MarkRepository
 
 // GetByName returns marks with the specified name
-func (r *MarkRepository) GetByName(name string) ([]entity.Mark, error) {
+func (r *MarkRepository) GetByName(ctx context.Context, name string) ([]entity.Mark, error) {
 	var marks []entity.Mark
-	result := r.BaseRepository.db.Where("name = ?", name).Find(&marks)
+	result := r.BaseRepository.conn(ctx).Where("name = ?", name).Find(&marks)
 	if result.Error != nil {
 		return nil, result.Error
 	}
 	return marks, nil
 }
 
-// DeleteOrphaned deletes marks that are not associated with any news
-func (r *MarkRepository) DeleteOrphaned() error {
+// DeleteOrphaned deletes marks that are not associated with any news and returns them
+func (r *MarkRepository) DeleteOrphaned(ctx context.Context) ([]entity.Mark, error) {
+	var deleted []entity.Mark
 	// This SQL finds and deletes marks that don't have relationships in the join table
-	return r.BaseRepository.db.Exec(`
+	err := r.BaseRepository.conn(ctx).Raw(`
         DELETE FROM tbl_mark 
         WHERE id NOT IN (
             SELECT DISTINCT mark_id FROM news_mark
         )
-    `).Error
+        RETURNING id, name
+    `).Scan(&deleted).Error
+	if err != nil {
+		return nil, err
+	}
+	return deleted, nil
 }
 
-// DeleteByName deletes a mark by its name
-func (r *MarkRepository) DeleteByName(name string) error {
-	return r.BaseRepository.db.Where("name = ?", name).Delete(&entity.Mark{}).Error
+func (r *MarkRepository) DeleteByName(ctx context.Context, name string) error {
+	return r.BaseRepository.conn(ctx).Where("name = ?", name).Delete(&entity.Mark{}).Error
 }
 
 // DeleteMarks deletes marks by their names
-func (r *MarkRepository) DeleteMarks(names []string) error {
+func (r *MarkRepository) DeleteMarks(ctx context.Context, names []string) error {
 	if len(names) == 0 {
 		return nil
 	}
-	return r.BaseRepository.db.Where("name IN ?", names).Delete(&entity.Mark{}).Error
+	return r.BaseRepository.conn(ctx).Where("name IN ?", names).Delete(&entity.Mark{}).Error
 }
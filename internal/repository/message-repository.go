@@ -2,6 +2,7 @@ package repository
 
 import (
 	"RESTAPI/internal/entity"
+	"context"
 
 	"gorm.io/gorm"
 )
@@ -10,6 +11,12 @@ type MessageRepository struct {
 	BaseRepository *BaseRepository[entity.Message]
 }
 
+// messageFields lists the Message fields exposed to collection filters and sorting
+var messageFields = ListFields{
+	"id":     {Column: "id", Kind: FieldInt},
+	"newsId": {Column: "news_id", Kind: FieldInt},
+}
+
 func NewMessageRepository(db *gorm.DB) *MessageRepository {
 	return &MessageRepository{
 		BaseRepository: NewBaseRepository[entity.Message](db),
@@ -17,31 +24,45 @@ func NewMessageRepository(db *gorm.DB) *MessageRepository {
 }
 
 // Create создает новое сообщение
-func (r *MessageRepository) Create(message *entity.Message) error {
-	return r.BaseRepository.Create(message)
+func (r *MessageRepository) Create(ctx context.Context, message *entity.Message) error {
+	return r.BaseRepository.Create(ctx, message)
 }
 
 // GetById получает сообщение по ID
-func (r *MessageRepository) GetById(id int64) (entity.Message, error) {
-	return r.BaseRepository.GetById(id)
+func (r *MessageRepository) GetById(ctx context.Context, id int64) (entity.Message, error) {
+	return r.BaseRepository.GetById(ctx, id)
 }
 
 // Update обновляет существующее сообщение
-func (r *MessageRepository) Update(message *entity.Message) error {
-	return r.BaseRepository.Update(message)
+func (r *MessageRepository) Update(ctx context.Context, message *entity.Message) error {
+	return r.BaseRepository.Update(ctx, message)
 }
 
 // Delete удаляет сообщение по ID
-func (r *MessageRepository) Delete(id int64) error {
-	return r.BaseRepository.Delete(id)
+func (r *MessageRepository) Delete(ctx context.Context, id int64) error {
+	return r.BaseRepository.Delete(ctx, id)
 }
 
 // GetAll возвращает все сообщения
-func (r *MessageRepository) GetAll() ([]entity.Message, error) {
+func (r *MessageRepository) GetAll(ctx context.Context) ([]entity.Message, error) {
 	var messages []entity.Message
-	result := r.BaseRepository.db.Find(&messages)
+	result := r.BaseRepository.conn(ctx).Find(&messages)
 	if result.Error != nil {
 		return nil, result.Error
 	}
 	return messages, nil
 }
+
+// List возвращает страницу сообщений с фильтрацией и сортировкой
+func (r *MessageRepository) List(ctx context.Context, q ListQuery) ([]entity.Message, int64, error) {
+	return r.BaseRepository.Query(ctx, messageFields, q)
+}
+
+// UpdateState сохраняет решение модерации; false, если сообщения нет в базе
+func (r *MessageRepository) UpdateState(ctx context.Context, id int64, state entity.MessageState) (bool, error) {
+	result := r.BaseRepository.conn(ctx).Model(&entity.Message{}).Where("id = ?", id).Update("state", state)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"RESTAPI/internal/entity"
+	"context"
 
 	"gorm.io/gorm"
 )
@@ -10,6 +11,15 @@ type NewsRepository struct {
 	BaseRepository *BaseRepository[entity.News]
 }
 
+// newsFields lists the News fields exposed to collection filters and sorting
+var newsFields = ListFields{
+	"id":       {Column: "id", Kind: FieldInt},
+	"writerId": {Column: "writer_id", Kind: FieldInt},
+	"title":    {Column: "title", Kind: FieldString},
+	"created":  {Column: "created", Kind: FieldTime},
+	"modified": {Column: "modified", Kind: FieldTime},
+}
+
 func NewNewsRepository(db *gorm.DB) *NewsRepository {
 	return &NewsRepository{
 		BaseRepository: NewBaseRepository[entity.News](db),
@@ -17,65 +27,103 @@ func NewNewsRepository(db *gorm.DB) *NewsRepository {
 }
 
 // Create создает новость
-func (r *NewsRepository) Create(news *entity.News) error {
-	return r.BaseRepository.Create(news)
+func (r *NewsRepository) Create(ctx context.Context, news *entity.News) error {
+	return r.BaseRepository.Create(ctx, news)
+}
+
+// Exists проверяет, существует ли новость с данным ID
+func (r *NewsRepository) Exists(ctx context.Context, id int64) (bool, error) {
+	return r.BaseRepository.Exists(ctx, id)
 }
 
 // GetById получает новость по ID
-func (r *NewsRepository) GetById(id int64) (entity.News, error) {
-	return r.BaseRepository.GetById(id)
+func (r *NewsRepository) GetById(ctx context.Context, id int64) (entity.News, error) {
+	return r.BaseRepository.GetById(ctx, id)
 }
 
 // Update обновляет новость
-func (r *NewsRepository) Update(news *entity.News) error {
-	return r.BaseRepository.Update(news)
+func (r *NewsRepository) Update(ctx context.Context, news *entity.News) error {
+	return r.BaseRepository.Update(ctx, news)
 }
 
 // Delete удаляет новость по ID
 // Delete deletes a news article by ID and removes mark associations
-func (r *NewsRepository) Delete(id int64) error {
+func (r *NewsRepository) Delete(ctx context.Context, id int64) error {
 	// First, get the news with its marks
-	news, err := r.GetById(id)
+	news, err := r.GetById(ctx, id)
 	if err != nil {
 		return err
 	}
 
-	// Begin a transaction
-	tx := r.BaseRepository.db.Begin()
+	// Runs as a savepoint when the caller already opened a transaction
+	return r.BaseRepository.conn(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM news_mark WHERE news_id = ?", id).Error; err != nil {
+			return err
+		}
 
-	if err := tx.Exec("DELETE FROM news_mark WHERE news_id = ?", id).Error; err != nil {
-		tx.Rollback()
-		return err
-	}
+		// Then delete the news
+		if err := tx.Exec("DELETE FROM tbl_news WHERE id = ?", id).Error; err != nil {
+			return err
+		}
 
-	// Then delete the news
-	if err := tx.Exec("DELETE FROM tbl_news WHERE id = ?", id).Error; err != nil {
-		tx.Rollback()
-		return err
-	}
+		// Remove the associations in the join table
+		if err := tx.Model(&news).Association("Marks").Clear(); err != nil {
+			return err
+		}
 
-	// Remove the associations in the join table
-	if err := tx.Model(&news).Association("Marks").Clear(); err != nil {
-		tx.Rollback()
-		return err
-	}
+		// Delete the news article
+		return tx.Delete(&news).Error
+	})
+}
 
-	// Delete the news article
-	if err := tx.Delete(&news).Error; err != nil {
-		tx.Rollback()
-		return err
+// IDsByWriter возвращает ID новостей писателя
+func (r *NewsRepository) IDsByWriter(ctx context.Context, writerID int64) ([]int64, error) {
+	var ids []int64
+	err := r.BaseRepository.conn(ctx).Model(&entity.News{}).Where("writer_id = ?", writerID).Order("id").Pluck("id", &ids).Error
+	if err != nil {
+		return nil, err
 	}
-
-	// Commit the transaction
-	return tx.Commit().Error
+	return ids, nil
 }
 
 // GetAll возвращает все новости
-func (r *NewsRepository) GetAll() ([]entity.News, error) {
+func (r *NewsRepository) GetAll(ctx context.Context) ([]entity.News, error) {
 	var news []entity.News
-	result := r.BaseRepository.db.Find(&news)
+	result := r.BaseRepository.conn(ctx).Find(&news)
 	if result.Error != nil {
 		return nil, result.Error
 	}
 	return news, nil
 }
+
+// List возвращает страницу новостей с фильтрацией и сортировкой
+func (r *NewsRepository) List(ctx context.Context, q ListQuery) ([]entity.News, int64, error) {
+	return r.BaseRepository.Query(ctx, newsFields, q)
+}
+
+// ListAfter возвращает до limit новостей, следующих за курсором в порядке (created, id).
+// Второе значение — курсор следующей страницы или nil, если страниц больше нет.
+func (r *NewsRepository) ListAfter(ctx context.Context, after *Cursor, limit int, filter map[string]string) ([]entity.News, *Cursor, error) {
+	conditions, err := newsFields.filter(filter)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	query := r.BaseRepository.conn(ctx).Model(&entity.News{}).Where(conditions)
+	if after != nil {
+		query = query.Where("(created, id) > (?, ?)", after.Created, after.ID)
+	}
+
+	// One extra row tells whether another page exists
+	var news []entity.News
+	if err := query.Order("created ASC, id ASC").Limit(limit + 1).Find(&news).Error; err != nil {
+		return nil, nil, err
+	}
+
+	if len(news) <= limit {
+		return news, nil, nil
+	}
+	news = news[:limit]
+	last := news[limit-1]
+	return news, &Cursor{Created: last.Created, ID: last.ID}, nil
+}
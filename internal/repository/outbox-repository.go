@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"RESTAPI/internal/entity"
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// outboxLockKey identifies the advisory lock of the outbox relay
+const outboxLockKey int64 = 0x6f7574626f78 // "outbox"
+
+// OutboxRepository stores events waiting to be published
+type OutboxRepository struct {
+	BaseRepository *BaseRepository[entity.OutboxEvent]
+}
+
+func NewOutboxRepository(db *gorm.DB) *OutboxRepository {
+	return &OutboxRepository{
+		BaseRepository: NewBaseRepository[entity.OutboxEvent](db),
+	}
+}
+
+// Add stores an event; call it with the ctx of the transaction that makes the change
+func (r *OutboxRepository) Add(ctx context.Context, event *entity.OutboxEvent) error {
+	return r.BaseRepository.Create(ctx, event)
+}
+
+// WithRelayLock runs fn in a transaction holding the relay lock, so that only
+// one instance publishes at a time. It reports false without running fn if
+// another instance holds the lock; the lock is released when the transaction ends.
+func (r *OutboxRepository) WithRelayLock(ctx context.Context, fn func(ctx context.Context) error) (bool, error) {
+	acquired := false
+	err := r.BaseRepository.conn(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Raw(`SELECT pg_try_advisory_xact_lock(?)`, outboxLockKey).Scan(&acquired).Error; err != nil {
+			return err
+		}
+		if !acquired {
+			return nil
+		}
+		return fn(context.WithValue(ctx, txKey{}, tx))
+	})
+	return acquired, err
+}
+
+// Pending returns up to limit unsent events in the order they were written
+func (r *OutboxRepository) Pending(ctx context.Context, limit int) ([]entity.OutboxEvent, error) {
+	var events []entity.OutboxEvent
+	err := r.BaseRepository.conn(ctx).
+		Where("sent_at IS NULL").
+		Order("id ASC").
+		Limit(limit).
+		Find(&events).Error
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// OldestPending returns when the oldest unsent event was written, or found
+// false when every event has been sent
+func (r *OutboxRepository) OldestPending(ctx context.Context) (created time.Time, found bool, err error) {
+	var events []entity.OutboxEvent
+	err = r.BaseRepository.conn(ctx).
+		Select("created").
+		Where("sent_at IS NULL").
+		Order("id ASC").
+		Limit(1).
+		Find(&events).Error
+	if err != nil || len(events) == 0 {
+		return time.Time{}, false, err
+	}
+	return events[0].Created, true, nil
+}
+
+// MarkSent records that the events have been published
+func (r *OutboxRepository) MarkSent(ctx context.Context, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return r.BaseRepository.conn(ctx).
+		Model(&entity.OutboxEvent{}).
+		Where("id IN ?", ids).
+		Update("sent_at", time.Now()).Error
+}
+
+// DeleteSentBefore removes events published before t and returns how many were removed
+func (r *OutboxRepository) DeleteSentBefore(ctx context.Context, t time.Time) (int64, error) {
+	result := r.BaseRepository.conn(ctx).
+		Where("sent_at IS NOT NULL AND sent_at < ?", t).
+		Delete(&entity.OutboxEvent{})
+	return result.RowsAffected, result.Error
+}
@@ -2,12 +2,17 @@
 package repository
 
 import (
+	"RESTAPI/internal/apperr"
+	"context"
 	"errors"
 	"fmt"
 
 	"gorm.io/gorm"
 )
 
+// ErrMissingReference is returned when a write references a record that does not exist
+var ErrMissingReference = apperr.Validation("referenced record does not exist")
+
 type BaseRepository[T any] struct {
 	db *gorm.DB
 }
@@ -17,16 +22,16 @@ func NewBaseRepository[T any](db *gorm.DB) *BaseRepository[T] {
 }
 
 // Create creates a new record and populates its ID
-func (r *BaseRepository[T]) Create(entity *T) error {
-	return r.db.Create(entity).Error
+func (r *BaseRepository[T]) Create(ctx context.Context, entity *T) error {
+	return translate(r.conn(ctx).Create(entity).Error)
 }
 
 // GetById gets a record by ID
-func (r *BaseRepository[T]) GetById(id int64) (T, error) {
+func (r *BaseRepository[T]) GetById(ctx context.Context, id int64) (T, error) {
 	var result T
-	if err := r.db.First(&result, id).Error; err != nil {
+	if err := r.conn(ctx).First(&result, id).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return result, fmt.Errorf("record not found")
+			return result, apperr.NotFound("record not found")
 		}
 		return result, err
 	}
@@ -34,21 +39,49 @@ func (r *BaseRepository[T]) GetById(id int64) (T, error) {
 }
 
 // Update updates an existing record
-func (r *BaseRepository[T]) Update(entity *T) error {
-	return r.db.Save(entity).Error
+func (r *BaseRepository[T]) Update(ctx context.Context, entity *T) error {
+	return translate(r.conn(ctx).Save(entity).Error)
+}
+
+// Exists reports whether a record with the given ID exists
+func (r *BaseRepository[T]) Exists(ctx context.Context, id int64) (bool, error) {
+	var count int64
+	if err := r.conn(ctx).Model(new(T)).Where("id = ?", id).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// translate maps constraint violations reported by the database to typed errors
+func translate(err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrForeignKeyViolated):
+		return fmt.Errorf("%w: %v", ErrMissingReference, err)
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		return apperr.Conflict("record already exists").Wrap(err)
+	}
+	return err
 }
 
 // Delete deletes a record by ID
-func (r *BaseRepository[T]) Delete(id int64) error {
-	return r.db.Delete(new(T), id).Error
+func (r *BaseRepository[T]) Delete(ctx context.Context, id int64) error {
+	result := r.conn(ctx).Delete(new(T), id)
+	if result.Error != nil {
+		return translate(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return apperr.NotFound("record not found")
+	}
+	return nil
 }
 
-// List returns a list of records with filtering, sorting and pagination
-func (r *BaseRepository[T]) List(page, pageSize int, filter map[string]interface{}, sort string) ([]T, int64, error) {
+// List returns a list of records with filtering, sorting and pagination.
+// A non-positive pageSize returns every matching record.
+func (r *BaseRepository[T]) List(ctx context.Context, page, pageSize int, filter map[string]interface{}, sort string) ([]T, int64, error) {
 	var entities []T
 	var total int64
 
-	query := r.db.Model(new(T)).Where(filter)
+	query := r.conn(ctx).Model(new(T)).Where(filter)
 
 	// Count total records
 	if err := query.Count(&total).Error; err != nil {
@@ -56,7 +89,14 @@ func (r *BaseRepository[T]) List(page, pageSize int, filter map[string]interface
 	}
 
 	// Apply sorting and pagination
-	if err := query.Order(sort).Offset((page - 1) * pageSize).Limit(pageSize).Find(&entities).Error; err != nil {
+	query = query.Order(sort)
+	if pageSize > 0 {
+		if page < 1 {
+			page = 1
+		}
+		query = query.Offset((page - 1) * pageSize).Limit(pageSize)
+	}
+	if err := query.Find(&entities).Error; err != nil {
 		return nil, 0, err
 	}
 
@@ -2,7 +2,10 @@
 package repository
 
 import (
+	"RESTAPI/internal/apperr"
 	"RESTAPI/internal/entity"
+	"context"
+	"errors"
 
 	"gorm.io/gorm"
 )
@@ -11,6 +14,14 @@ type WriterRepository struct {
 	BaseRepository *BaseRepository[entity.Writer]
 }
 
+// writerFields lists the Writer fields exposed to collection filters and sorting
+var writerFields = ListFields{
+	"id":        {Column: "id", Kind: FieldInt},
+	"login":     {Column: "login", Kind: FieldString},
+	"firstname": {Column: "firstname", Kind: FieldString},
+	"lastname":  {Column: "lastname", Kind: FieldString},
+}
+
 func NewWriterRepository(db *gorm.DB) *WriterRepository {
 	return &WriterRepository{
 		BaseRepository: NewBaseRepository[entity.Writer](db),
@@ -18,41 +29,59 @@ func NewWriterRepository(db *gorm.DB) *WriterRepository {
 }
 
 // Create creates a new writer and populates its ID
-func (r *WriterRepository) Create(writer *entity.Writer) error {
-	return r.BaseRepository.Create(writer)
+func (r *WriterRepository) Create(ctx context.Context, writer *entity.Writer) error {
+	return r.BaseRepository.Create(ctx, writer)
+}
+
+// Exists reports whether a writer with the given ID exists
+func (r *WriterRepository) Exists(ctx context.Context, id int64) (bool, error) {
+	return r.BaseRepository.Exists(ctx, id)
 }
 
 // GetById gets a writer by ID
-func (r *WriterRepository) GetById(id int64) (entity.Writer, error) {
-	return r.BaseRepository.GetById(id)
+func (r *WriterRepository) GetById(ctx context.Context, id int64) (entity.Writer, error) {
+	return r.BaseRepository.GetById(ctx, id)
 }
 
 // Update updates an existing writer
-func (r *WriterRepository) Update(writer *entity.Writer) error {
-	return r.BaseRepository.Update(writer)
+func (r *WriterRepository) Update(ctx context.Context, writer *entity.Writer) error {
+	return r.BaseRepository.Update(ctx, writer)
 }
 
 // Delete deletes a writer by ID
-func (r *WriterRepository) Delete(id int64) error {
-	return r.BaseRepository.Delete(id)
+func (r *WriterRepository) Delete(ctx context.Context, id int64) error {
+	return r.BaseRepository.Delete(ctx, id)
 }
 
 // GetAll returns all writers
-func (r *WriterRepository) GetAll() ([]entity.Writer, error) {
+func (r *WriterRepository) GetAll(ctx context.Context) ([]entity.Writer, error) {
 	var writers []entity.Writer
-	result := r.BaseRepository.db.Find(&writers)
+	result := r.BaseRepository.conn(ctx).Find(&writers)
 	if result.Error != nil {
 		return nil, result.Error
 	}
 	return writers, nil
 }
 
-// This would be in your repository/writer-repository.go file
-func (r *WriterRepository) GetByLogin(login string) (*entity.Writer, error) {
+// List returns a filtered, sorted page of writers
+func (r *WriterRepository) List(ctx context.Context, q ListQuery) ([]entity.Writer, int64, error) {
+	return r.BaseRepository.Query(ctx, writerFields, q)
+}
+
+// GetByLogin gets a writer by login
+func (r *WriterRepository) GetByLogin(ctx context.Context, login string) (*entity.Writer, error) {
 	var writer entity.Writer
-	result := r.BaseRepository.db.Where("login = ?", login).First(&writer)
+	result := r.BaseRepository.conn(ctx).Where("login = ?", login).First(&writer)
 	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, apperr.NotFound("record not found")
+		}
 		return nil, result.Error
 	}
 	return &writer, nil
 }
+
+// UpdatePassword replaces only the stored password hash of a writer
+func (r *WriterRepository) UpdatePassword(ctx context.Context, id int64, hash string) error {
+	return r.BaseRepository.conn(ctx).Model(&entity.Writer{}).Where("id = ?", id).Update("password", hash).Error
+}
@@ -0,0 +1,29 @@
+package service
+
+import "RESTAPI/internal/apperr"
+
+var (
+	// ErrUnknownWriter is returned when news references a writer that does not exist
+	ErrUnknownWriter = apperr.Validation("writer not found",
+		apperr.FieldError{Field: "writerId", Rule: "exists", Message: "writer does not exist"})
+	// ErrUnknownNews is returned when a message references news that does not exist
+	ErrUnknownNews = apperr.Validation("news not found",
+		apperr.FieldError{Field: "newsId", Rule: "exists", Message: "news does not exist"})
+	// ErrUnknownMark is returned when news of a non-admin reference a mark that does not exist
+	ErrUnknownMark = apperr.Validation("mark not found",
+		apperr.FieldError{Field: "marks", Rule: "exists", Message: "mark does not exist; only ADMIN can create marks"})
+	// ErrInvalidCredentials is returned when a login/password pair does not match a writer
+	ErrInvalidCredentials = apperr.Unauthorized("invalid login or password")
+	// ErrDiscussionUnavailable is returned when the discussion service or Kafka cannot be reached;
+	// the cause is logged rather than shown to the client
+	ErrDiscussionUnavailable = apperr.Unavailable("discussion service unavailable")
+)
+
+// notFound replaces a repository NotFound error with an entity-specific one
+// and passes any other error through unchanged
+func notFound(err error, entity string) error {
+	if apperr.IsNotFound(err) {
+		return apperr.NotFound("%s not found", entity)
+	}
+	return err
+}
@@ -3,23 +3,39 @@ package service
 import (
 	"RESTAPI/internal/dto"
 	"RESTAPI/internal/entity"
+	"RESTAPI/internal/outbox"
 	"RESTAPI/internal/repository"
-	"errors"
+	"context"
 )
 
+// MarkService changes marks; every change is recorded in the outbox in the same transaction
 type MarkService struct {
-	repo *repository.MarkRepository
+	repo   *repository.MarkRepository
+	tx     *repository.Transactor
+	events *outbox.Recorder
 }
 
-func NewMarkService(repo *repository.MarkRepository) *MarkService {
-	return &MarkService{repo: repo}
+func NewMarkService(repo *repository.MarkRepository, tx *repository.Transactor, events *outbox.Recorder) *MarkService {
+	return &MarkService{repo: repo, tx: tx, events: events}
 }
 
-func (s *MarkService) Create(req dto.MarkRequestTo) (*dto.MarkResponseTo, error) {
+func markResponse(mark *entity.Mark) *dto.MarkResponseTo {
+	return &dto.MarkResponseTo{
+		ID:   mark.ID,
+		Name: mark.Name,
+	}
+}
+
+func (s *MarkService) Create(ctx context.Context, req dto.MarkRequestTo) (*dto.MarkResponseTo, error) {
 	mark := &entity.Mark{
 		Name: req.Name,
 	}
-	err := s.repo.Create(mark)
+	err := s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.repo.Create(ctx, mark); err != nil {
+			return err
+		}
+		return s.events.Record(ctx, outbox.Mark, mark.ID, outbox.Created, markResponse(mark))
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -29,10 +45,10 @@ func (s *MarkService) Create(req dto.MarkRequestTo) (*dto.MarkResponseTo, error)
 	}, nil
 }
 
-func (s *MarkService) GetById(id int64) (*dto.MarkResponseTo, error) {
-	mark, err := s.repo.GetById(id)
+func (s *MarkService) GetById(ctx context.Context, id int64) (*dto.MarkResponseTo, error) {
+	mark, err := s.repo.GetById(ctx, id)
 	if err != nil {
-		return nil, errors.New("mark not found")
+		return nil, notFound(err, "mark")
 	}
 	return &dto.MarkResponseTo{
 		ID:   mark.ID,
@@ -40,14 +56,23 @@ func (s *MarkService) GetById(id int64) (*dto.MarkResponseTo, error) {
 	}, nil
 }
 
-func (s *MarkService) Update(req dto.MarkUpdateRequestTo) (*dto.MarkResponseTo, error) {
+func (s *MarkService) Update(ctx context.Context, req dto.MarkUpdateRequestTo) (*dto.MarkResponseTo, error) {
+	if _, err := s.repo.GetById(ctx, req.ID); err != nil {
+		return nil, notFound(err, "mark")
+	}
+
 	mark := &entity.Mark{
 		Name: req.Name,
 		ID:   req.ID,
 	}
-	err := s.repo.Update(mark)
+	err := s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.repo.Update(ctx, mark); err != nil {
+			return err
+		}
+		return s.events.Record(ctx, outbox.Mark, mark.ID, outbox.Updated, markResponse(mark))
+	})
 	if err != nil {
-		return nil, errors.New("failed to update mark")
+		return nil, err
 	}
 	return &dto.MarkResponseTo{
 		ID:   mark.ID,
@@ -55,16 +80,21 @@ func (s *MarkService) Update(req dto.MarkUpdateRequestTo) (*dto.MarkResponseTo,
 	}, nil
 }
 
-func (s *MarkService) Delete(id int64) error {
-	err := s.repo.Delete(id)
+func (s *MarkService) Delete(ctx context.Context, id int64) error {
+	err := s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.repo.Delete(ctx, id); err != nil {
+			return err
+		}
+		return s.events.RecordDeleted(ctx, outbox.Mark, id)
+	})
 	if err != nil {
-		return err
+		return notFound(err, "mark")
 	}
 	return nil
 }
 
-func (s *MarkService) GetAll() ([]*dto.MarkResponseTo, error) {
-	marks, err := s.repo.GetAll()
+func (s *MarkService) GetAll(ctx context.Context) ([]*dto.MarkResponseTo, error) {
+	marks, err := s.repo.GetAll(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -78,3 +108,20 @@ func (s *MarkService) GetAll() ([]*dto.MarkResponseTo, error) {
 	}
 	return response, nil
 }
+
+// List returns a page of marks together with the total number of matches
+func (s *MarkService) List(ctx context.Context, q repository.ListQuery) ([]*dto.MarkResponseTo, int64, error) {
+	marks, total, err := s.repo.List(ctx, q)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	response := make([]*dto.MarkResponseTo, len(marks))
+	for i, mark := range marks {
+		response[i] = &dto.MarkResponseTo{
+			ID:   mark.ID,
+			Name: mark.Name,
+		}
+	}
+	return response, total, nil
+}
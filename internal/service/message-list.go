@@ -0,0 +1,45 @@
+package service
+
+import (
+	"RESTAPI/internal/repository"
+	"strconv"
+	"strings"
+)
+
+// messageFields are the fields messages can be filtered and sorted by
+var messageFields = map[string]bool{
+	"id":     true,
+	"newsId": true,
+}
+
+// maxMessageOffset is the deepest offset the discussion service pages to,
+// see its MaxListOffset
+const maxMessageOffset = 1000
+
+// checkMessageQuery validates a list query before it is passed on to the
+// discussion service. Messages are stored by news, so they can only be
+// sorted within a news item, i.e. with a newsId filter. The discussion
+// service reads every page before the requested one, so deep pages are refused.
+func checkMessageQuery(q repository.ListQuery) error {
+	if q.PageSize > 0 && q.Page-1 > maxMessageOffset/q.PageSize {
+		return repository.InvalidQuery("page too deep: at most %d messages can be skipped", maxMessageOffset)
+	}
+	for name, raw := range q.Filter {
+		if !messageFields[name] {
+			return repository.InvalidQuery("unknown filter field %q", name)
+		}
+		if _, err := strconv.ParseInt(raw, 10, 64); err != nil {
+			return repository.InvalidQuery("%s must be an integer", name)
+		}
+	}
+	for _, name := range q.Sort {
+		field := strings.TrimPrefix(name, "-")
+		if !messageFields[field] {
+			return repository.InvalidQuery("unknown sort field %q", field)
+		}
+		if _, ok := q.Filter["newsId"]; !ok {
+			return repository.InvalidQuery("messages can only be sorted with a newsId filter")
+		}
+	}
+	return nil
+}
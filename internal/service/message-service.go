@@ -1,91 +1,285 @@
 package service
 
 import (
+	"RESTAPI/internal/apperr"
+	"RESTAPI/internal/discussion/client"
+	"RESTAPI/internal/discussion/model"
 	"RESTAPI/internal/dto"
 	"RESTAPI/internal/entity"
 	"RESTAPI/internal/repository"
+	"context"
 	"errors"
+	"log/slog"
 )
 
+// MessageService proxies messages to the discussion service, which owns them.
+// New messages go through Kafka for moderation; reads and edits use its HTTP API.
+// The local table keeps the author (for access checks) and the last known state.
 type MessageService struct {
-	repo *repository.MessageRepository
+	repo       *repository.MessageRepository
+	newsRepo   *repository.NewsRepository
+	discussion *client.Client
+	publisher  *client.Publisher
+	requester  *client.Requester
 }
 
-func NewMessageService(repo *repository.MessageRepository) *MessageService {
-	return &MessageService{repo: repo}
+func NewMessageService(repo *repository.MessageRepository, newsRepo *repository.NewsRepository, discussion *client.Client, publisher *client.Publisher, requester *client.Requester) *MessageService {
+	return &MessageService{repo: repo, newsRepo: newsRepo, discussion: discussion, publisher: publisher, requester: requester}
 }
 
-func (s *MessageService) Create(req dto.MessageRequestTo) (*dto.MessageResponseTo, error) {
+// checkNews verifies that the referenced news exists
+func (s *MessageService) checkNews(ctx context.Context, newsID int64) error {
+	exists, err := s.newsRepo.Exists(ctx, newsID)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrUnknownNews
+	}
+	return nil
+}
+
+// unavailable logs why the discussion service could not be used and hides the cause from the client
+func unavailable(ctx context.Context, err error) error {
+	slog.ErrorContext(ctx, "discussion service call failed", slog.Any("error", err))
+	return ErrDiscussionUnavailable
+}
+
+// discussionError maps a discussion client error to a service error
+func discussionError(ctx context.Context, err error) error {
+	switch {
+	case errors.Is(err, client.ErrNotFound):
+		return apperr.NotFound("message not found")
+	case errors.Is(err, client.ErrUnavailable):
+		return unavailable(ctx, err)
+	}
+	return err
+}
+
+func messageResponse(message *model.Message) *dto.MessageResponseTo {
+	return &dto.MessageResponseTo{
+		ID:          message.ID,
+		NewsID:      message.NewsID,
+		Content:     message.Content,
+		State:       string(message.State),
+		DeclineRule: message.DeclineRule,
+	}
+}
+
+// Create stores the message as PENDING and sends it to message-in for moderation.
+// The verdict arrives later on message-out, see ApplyResult.
+func (s *MessageService) Create(ctx context.Context, req dto.MessageRequestTo) (*dto.MessageResponseTo, error) {
+	pending, err := s.store(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.publisher.Publish(ctx, pending); err != nil {
+		return nil, s.unpublished(ctx, pending.ID, err)
+	}
+	return messageResponse(pending), nil
+}
+
+// CreateAndWait works like Create but waits for the moderation verdict.
+// If it does not arrive in time the message stays PENDING and is returned as
+// such; the verdict is then applied later from message-out.
+func (s *MessageService) CreateAndWait(ctx context.Context, req dto.MessageRequestTo) (*dto.MessageResponseTo, error) {
+	pending, err := s.store(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	result, err := s.requester.Request(ctx, pending)
+	if errors.Is(err, client.ErrNoReply) {
+		slog.WarnContext(ctx, "moderation verdict not received in time", slog.Int64("message_id", pending.ID), slog.Any("error", err))
+		return messageResponse(pending), nil
+	}
+	if err != nil {
+		return nil, s.unpublished(ctx, pending.ID, err)
+	}
+
+	// The results consumer stores the verdict too; doing it here makes it
+	// visible to the next read right away
+	if err := s.ApplyResult(ctx, result); err != nil {
+		return nil, err
+	}
+	return messageResponse(result), nil
+}
 
-	if req.NewsID > 1000000 { // Simplistic check for large writer IDs that likely don't exist
-		return nil, errors.New("writer not found")
+// store saves the local copy of a new message as PENDING.
+// The local ID becomes the message ID in the discussion service.
+func (s *MessageService) store(ctx context.Context, req dto.MessageRequestTo) (*model.Message, error) {
+	if err := s.checkNews(ctx, req.NewsID); err != nil {
+		return nil, err
 	}
 
 	message := &entity.Message{
-		NewsID:  req.NewsID,
-		Content: req.Content,
+		NewsID:   req.NewsID,
+		WriterID: req.WriterID,
+		Content:  req.Content,
+		State:    entity.MessagePending,
 	}
-	err := s.repo.Create(message) // Вызываем метод из репозитория
+	err := s.repo.Create(ctx, message) // Вызываем метод из репозитория
 	if err != nil {
+		// The news may have been deleted after the check above
+		if errors.Is(err, repository.ErrMissingReference) {
+			return nil, ErrUnknownNews
+		}
 		return nil, err
 	}
-	return &dto.MessageResponseTo{
-		ID:      message.ID,
-		NewsID:  message.NewsID,
-		Content: message.Content,
+
+	return &model.Message{
+		ID:       message.ID,
+		NewsID:   message.NewsID,
+		Content:  message.Content,
+		State:    model.StatePending,
+		WriterID: message.WriterID,
 	}, nil
 }
 
-func (s *MessageService) GetById(id int64) (*dto.MessageResponseTo, error) {
-	message, err := s.repo.GetById(id)
+// unpublished removes the local copy of a message that could not be sent to message-in
+func (s *MessageService) unpublished(ctx context.Context, id int64, err error) error {
+	if delErr := s.repo.Delete(ctx, id); delErr != nil {
+		slog.ErrorContext(ctx, "failed to remove unpublished message", slog.Int64("message_id", id), slog.Any("error", delErr))
+	}
+	return unavailable(ctx, err)
+}
+
+func (s *MessageService) GetById(ctx context.Context, id int64) (*dto.MessageResponseTo, error) {
+	message, err := s.discussion.Get(ctx, id)
+	if errors.Is(err, client.ErrNotFound) {
+		// Not consumed from message-in yet
+		return s.local(ctx, id)
+	}
+	if err != nil {
+		return nil, discussionError(ctx, err)
+	}
+	return messageResponse(message), nil
+}
+
+// local returns the locally stored copy of a message
+func (s *MessageService) local(ctx context.Context, id int64) (*dto.MessageResponseTo, error) {
+	message, err := s.repo.GetById(ctx, id)
 	if err != nil {
-		return nil, errors.New("message not found")
+		return nil, notFound(err, "message")
 	}
 	return &dto.MessageResponseTo{
 		ID:      message.ID,
 		NewsID:  message.NewsID,
 		Content: message.Content,
+		State:   string(message.State),
 	}, nil
 }
 
-func (s *MessageService) Update(req dto.MessageUpdateRequestTo) (*dto.MessageResponseTo, error) {
-	message := &entity.Message{
+// Update changes the message in the discussion service and the local copy.
+// A message created directly in the discussion service has no local copy and
+// is only changed there; access.MessageService lets only ADMIN do so.
+func (s *MessageService) Update(ctx context.Context, req dto.MessageUpdateRequestTo) (*dto.MessageResponseTo, error) {
+	existing, err := s.repo.GetById(ctx, req.ID)
+	if err != nil && !apperr.IsNotFound(err) {
+		return nil, err
+	}
+	hasLocal := err == nil
+	if err := s.checkNews(ctx, req.NewsID); err != nil {
+		return nil, err
+	}
+
+	// The state is left empty so the discussion service keeps its verdict
+	updated, err := s.discussion.Update(ctx, &model.Message{
 		ID:      req.ID,
 		NewsID:  req.NewsID,
 		Content: req.Content,
+	})
+	if err != nil {
+		return nil, discussionError(ctx, err)
+	}
+	if !hasLocal {
+		return messageResponse(updated), nil
 	}
-	err := s.repo.Update(message)
+
+	message := &entity.Message{
+		ID:       updated.ID,
+		NewsID:   updated.NewsID,
+		WriterID: existing.WriterID,
+		Content:  updated.Content,
+		State:    entity.MessageState(updated.State),
+	}
+	err = s.repo.Update(ctx, message)
 	if err != nil {
-		return nil, errors.New("failed to update message")
+		if errors.Is(err, repository.ErrMissingReference) {
+			return nil, ErrUnknownNews
+		}
+		return nil, err
 	}
-	return &dto.MessageResponseTo{
-		ID:      message.ID,
-		NewsID:  message.NewsID,
-		Content: message.Content,
-	}, nil
+	return messageResponse(updated), nil
 }
 
-func (s *MessageService) Delete(id int64) error {
-	err := s.repo.Delete(id)
+// WriterOf returns the ID of the writer who posted the message
+func (s *MessageService) WriterOf(ctx context.Context, id int64) (int64, error) {
+	message, err := s.repo.GetById(ctx, id)
 	if err != nil {
-		return err
+		return 0, notFound(err, "message")
 	}
-	return nil
+	return message.WriterID, nil
 }
 
-func (s *MessageService) GetAll() ([]*dto.MessageResponseTo, error) {
-	messages, err := s.repo.GetAll()
+// Delete removes the message from the discussion service and the local copy.
+// A message that never reached the discussion service is only removed locally.
+func (s *MessageService) Delete(ctx context.Context, id int64) error {
+	remoteErr := s.discussion.Delete(ctx, id)
+	if remoteErr != nil && !errors.Is(remoteErr, client.ErrNotFound) {
+		return discussionError(ctx, remoteErr)
+	}
+
+	err := s.repo.Delete(ctx, id)
+	if apperr.IsNotFound(err) && remoteErr == nil {
+		// Created directly in the discussion service, there is no local copy
+		return nil
+	}
+	return notFound(err, "message")
+}
+
+func (s *MessageService) GetAll(ctx context.Context) ([]*dto.MessageResponseTo, error) {
+	messages, err := s.discussion.List(ctx)
 	if err != nil {
-		return nil, err
+		return nil, discussionError(ctx, err)
 	}
 
 	response := make([]*dto.MessageResponseTo, len(messages))
 	for i, message := range messages {
-		response[i] = &dto.MessageResponseTo{
-			ID:      message.ID,
-			NewsID:  message.NewsID,
-			Content: message.Content,
-		}
+		response[i] = messageResponse(message)
 	}
 	return response, nil
 }
+
+// List returns a page of messages together with the total number of matches,
+// or -1 when the discussion service does not count them. Paging, filters and
+// sorting are done by the discussion service.
+func (s *MessageService) List(ctx context.Context, q repository.ListQuery) ([]*dto.MessageResponseTo, int64, error) {
+	if err := checkMessageQuery(q); err != nil {
+		return nil, 0, err
+	}
+	messages, total, err := s.discussion.ListPage(ctx, q.Page, q.PageSize, q.Filter, q.Sort)
+	if err != nil {
+		return nil, 0, discussionError(ctx, err)
+	}
+
+	response := make([]*dto.MessageResponseTo, len(messages))
+	for i, message := range messages {
+		response[i] = messageResponse(message)
+	}
+	return response, total, nil
+}
+
+// ApplyResult stores the moderation verdict received from message-out.
+// Messages created directly in the discussion service have no local copy and are skipped.
+func (s *MessageService) ApplyResult(ctx context.Context, result *model.Message) error {
+	found, err := s.repo.UpdateState(ctx, result.ID, entity.MessageState(result.State))
+	if err != nil {
+		return err
+	}
+	if !found {
+		slog.DebugContext(ctx, "moderation result for unknown message", slog.Int64("message_id", result.ID))
+		return nil
+	}
+	slog.InfoContext(ctx, "message moderated", slog.Int64("message_id", result.ID), slog.String("state", string(result.State)))
+	return nil
+}
@@ -1,78 +1,118 @@
 package service
 
 import (
+	"RESTAPI/internal/apperr"
 	"RESTAPI/internal/dto"
 	"RESTAPI/internal/entity"
+	"RESTAPI/internal/outbox"
 	"RESTAPI/internal/repository"
+	"context"
 	"errors"
 	"time"
 )
 
+// NewsService changes news together with their marks; every change is
+// recorded in the outbox in the same transaction
 type NewsService struct {
-	repo     *repository.NewsRepository
-	markRepo *repository.MarkRepository
+	repo       *repository.NewsRepository
+	markRepo   *repository.MarkRepository
+	writerRepo *repository.WriterRepository
+	tx         *repository.Transactor
+	events     *outbox.Recorder
 }
 
-func NewNewsService(repo *repository.NewsRepository, markRepo *repository.MarkRepository) *NewsService {
-	return &NewsService{repo: repo, markRepo: markRepo}
+func NewNewsService(repo *repository.NewsRepository, markRepo *repository.MarkRepository, writerRepo *repository.WriterRepository, tx *repository.Transactor, events *outbox.Recorder) *NewsService {
+	return &NewsService{repo: repo, markRepo: markRepo, writerRepo: writerRepo, tx: tx, events: events}
 }
 
-func (s *NewsService) Create(req dto.NewsRequestTo) (*dto.NewsResponseTo, error) {
+// checkWriter verifies that the referenced writer exists
+func (s *NewsService) checkWriter(ctx context.Context, writerID int64) error {
+	exists, err := s.writerRepo.Exists(ctx, writerID)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrUnknownWriter
+	}
+	return nil
+}
 
-	marks := []entity.Mark{}
-	for _, markName := range req.Marks {
-		// Try to find existing mark
-		existingMarks, err := s.markRepo.GetByName(markName)
+// Create publishes news with the marks named in req. Marks that do not exist
+// are created when createMarks is set and rejected with ErrUnknownMark otherwise.
+func (s *NewsService) Create(ctx context.Context, req dto.NewsRequestTo, createMarks bool) (*dto.NewsResponseTo, error) {
+	if err := s.checkWriter(ctx, req.WriterID); err != nil {
+		return nil, err
+	}
 
-		var mark entity.Mark
-		if err != nil || len(existingMarks) == 0 {
-			// Create new mark if not found
-			mark = entity.Mark{Name: markName}
-			if err := s.markRepo.Create(&mark); err != nil {
-				return nil, err
+	var news *entity.News
+	err := s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		marks := []entity.Mark{}
+		for _, markName := range req.Marks {
+			// Try to find existing mark
+			existingMarks, err := s.markRepo.GetByName(ctx, markName)
+			if err != nil {
+				return err
 			}
-		} else {
-			mark = existingMarks[0]
-		}
 
-		marks = append(marks, mark)
-	}
-	// Check if writer with this ID exists (example validation)
-	// In a real scenario, you would query the writer repository
-	if req.WriterID > 1000000 { // Simplistic check for large writer IDs that likely don't exist
-		return nil, errors.New("writer not found")
-	}
+			var mark entity.Mark
+			if len(existingMarks) == 0 {
+				if !createMarks {
+					return ErrUnknownMark
+				}
+				// Create new mark if not found
+				mark = entity.Mark{Name: markName}
+				if err := s.markRepo.Create(ctx, &mark); err != nil {
+					return err
+				}
+				if err := s.events.Record(ctx, outbox.Mark, mark.ID, outbox.Created, markResponse(&mark)); err != nil {
+					return err
+				}
+			} else {
+				mark = existingMarks[0]
+			}
 
-	// Check for duplicate title
-	existingNews, err := s.repo.GetAll()
-	if err == nil { // Only check if we successfully got the news list
-		for _, news := range existingNews {
-			if news.Title == req.Title {
-				return nil, errors.New("news with this title already exists")
+			marks = append(marks, mark)
+		}
+		// Check for duplicate title
+		existingNews, err := s.repo.GetAll(ctx)
+		if err == nil { // Only check if we successfully got the news list
+			for _, news := range existingNews {
+				if news.Title == req.Title {
+					return apperr.Conflict("news with this title already exists")
+				}
 			}
 		}
-	}
 
-	news := &entity.News{
-		WriterID: req.WriterID,
-		Title:    req.Title,
-		Content:  req.Content,
-		Created:  time.Now(),
-		Modified: time.Now(),
-		Marks:    marks,
-	}
+		news = &entity.News{
+			WriterID: req.WriterID,
+			Title:    req.Title,
+			Content:  req.Content,
+			Created:  time.Now(),
+			Modified: time.Now(),
+			Marks:    marks,
+		}
 
-	err = s.repo.Create(news)
+		err = s.repo.Create(ctx, news)
+		if err != nil {
+			// The writer may have been deleted after the check above
+			if errors.Is(err, repository.ErrMissingReference) {
+				return ErrUnknownWriter
+			}
+			return err
+		}
+		return s.events.Record(ctx, outbox.News, news.ID, outbox.Created, newsResponse(news))
+	})
 	if err != nil {
 		return nil, err
 	}
 
+	return newsResponse(news), nil
+}
+
+func newsResponse(news *entity.News) *dto.NewsResponseTo {
 	markResponses := make([]dto.MarkResponseTo, len(news.Marks))
 	for i, mark := range news.Marks {
-		markResponses[i] = dto.MarkResponseTo{
-			ID:   mark.ID,
-			Name: mark.Name,
-		}
+		markResponses[i] = *markResponse(&mark)
 	}
 
 	return &dto.NewsResponseTo{
@@ -83,13 +123,13 @@ func (s *NewsService) Create(req dto.NewsRequestTo) (*dto.NewsResponseTo, error)
 		Created:  news.Created,
 		Modified: news.Modified,
 		Marks:    markResponses,
-	}, nil
+	}
 }
 
-func (s *NewsService) GetById(id int64) (*dto.NewsResponseTo, error) {
-	news, err := s.repo.GetById(id)
+func (s *NewsService) GetById(ctx context.Context, id int64) (*dto.NewsResponseTo, error) {
+	news, err := s.repo.GetById(ctx, id)
 	if err != nil {
-		return nil, errors.New("news not found")
+		return nil, notFound(err, "news")
 	}
 	return &dto.NewsResponseTo{
 		ID:       news.ID,
@@ -101,16 +141,31 @@ func (s *NewsService) GetById(id int64) (*dto.NewsResponseTo, error) {
 	}, nil
 }
 
-func (s *NewsService) Update(req dto.NewsUpdateRequestTo) (*dto.NewsResponseTo, error) {
+func (s *NewsService) Update(ctx context.Context, req dto.NewsUpdateRequestTo) (*dto.NewsResponseTo, error) {
+	if _, err := s.repo.GetById(ctx, req.ID); err != nil {
+		return nil, notFound(err, "news")
+	}
+	if err := s.checkWriter(ctx, req.WriterID); err != nil {
+		return nil, err
+	}
+
 	news := &entity.News{
 		WriterID: req.WriterID,
 		Title:    req.Title,
 		Content:  req.Content,
 		ID:       req.ID,
 	}
-	err := s.repo.Update(news)
+	err := s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.repo.Update(ctx, news); err != nil {
+			return err
+		}
+		return s.events.Record(ctx, outbox.News, news.ID, outbox.Updated, newsResponse(news))
+	})
 	if err != nil {
-		return nil, errors.New("failed to update news")
+		if errors.Is(err, repository.ErrMissingReference) {
+			return nil, ErrUnknownWriter
+		}
+		return nil, err
 	}
 	return &dto.NewsResponseTo{
 		ID:       news.ID,
@@ -124,11 +179,11 @@ func (s *NewsService) Update(req dto.NewsUpdateRequestTo) (*dto.NewsResponseTo,
 
 // Delete deletes a news article by ID
 // Delete deletes a news article by ID and its associated marks
-func (s *NewsService) Delete(id int64) error {
+func (s *NewsService) Delete(ctx context.Context, id int64) error {
 	// First get the news with its marks to know which marks to potentially delete
-	news, err := s.repo.GetById(id)
+	news, err := s.repo.GetById(ctx, id)
 	if err != nil {
-		return err
+		return notFound(err, "news")
 	}
 
 	// Extract mark names to delete them later if needed
@@ -137,28 +192,41 @@ func (s *NewsService) Delete(id int64) error {
 		markNames[i] = mark.Name
 	}
 
-	// Delete the news with its mark associations
-	err = s.repo.Delete(id)
-	if err != nil {
-		return err
-	}
+	err = s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		// Delete the news with its mark associations
+		if err := s.repo.Delete(ctx, id); err != nil {
+			return err
+		}
+		if err := s.events.RecordDeleted(ctx, outbox.News, id); err != nil {
+			return err
+		}
 
-	// Now delete the marks if they're no longer used
-	// Either use DeleteOrphaned to delete all orphaned marks
-	err = s.markRepo.DeleteOrphaned()
+		// Now delete the marks if they're no longer used
+		// Either use DeleteOrphaned to delete all orphaned marks
+		orphaned, err := s.markRepo.DeleteOrphaned(ctx)
+		if err != nil {
+			return err
+		}
+		for _, mark := range orphaned {
+			if err := s.events.RecordDeleted(ctx, outbox.Mark, mark.ID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 	if err != nil {
 		return err
 	}
 
 	// Or directly delete these specific marks if they should always be removed
 	// (uncomment if needed)
-	// return s.markRepo.DeleteMarks(markNames)
+	// return s.markRepo.DeleteMarks(ctx, markNames)
 
 	return nil
 }
 
-func (s *NewsService) GetAll() ([]*dto.NewsResponseTo, error) {
-	newsList, err := s.repo.GetAll()
+func (s *NewsService) GetAll(ctx context.Context) ([]*dto.NewsResponseTo, error) {
+	newsList, err := s.repo.GetAll(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -176,3 +244,59 @@ func (s *NewsService) GetAll() ([]*dto.NewsResponseTo, error) {
 	}
 	return response, nil
 }
+
+// List returns a page of news together with the total number of matches
+func (s *NewsService) List(ctx context.Context, q repository.ListQuery) ([]*dto.NewsResponseTo, int64, error) {
+	newsList, total, err := s.repo.List(ctx, q)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	response := make([]*dto.NewsResponseTo, len(newsList))
+	for i, news := range newsList {
+		response[i] = &dto.NewsResponseTo{
+			ID:       news.ID,
+			WriterID: news.WriterID,
+			Title:    news.Title,
+			Content:  news.Content,
+			Created:  news.Created,
+			Modified: news.Modified,
+		}
+	}
+	return response, total, nil
+}
+
+// ListAfter returns up to limit news following the opaque cursor token.
+// An empty token starts from the beginning; the returned token is empty on the last page.
+func (s *NewsService) ListAfter(ctx context.Context, after string, limit int, filter map[string]string) ([]*dto.NewsResponseTo, string, error) {
+	var cursor *repository.Cursor
+	if after != "" {
+		c, err := repository.DecodeCursor(after)
+		if err != nil {
+			return nil, "", err
+		}
+		cursor = &c
+	}
+
+	newsList, next, err := s.repo.ListAfter(ctx, cursor, limit, filter)
+	if err != nil {
+		return nil, "", err
+	}
+
+	response := make([]*dto.NewsResponseTo, len(newsList))
+	for i, news := range newsList {
+		response[i] = &dto.NewsResponseTo{
+			ID:       news.ID,
+			WriterID: news.WriterID,
+			Title:    news.Title,
+			Content:  news.Content,
+			Created:  news.Created,
+			Modified: news.Modified,
+		}
+	}
+
+	if next == nil {
+		return response, "", nil
+	}
+	return response, next.Encode(), nil
+}
@@ -2,110 +2,263 @@
 package service
 
 import (
+	"RESTAPI/internal/apperr"
 	"RESTAPI/internal/dto"
 	"RESTAPI/internal/entity"
+	"RESTAPI/internal/outbox"
+	"RESTAPI/internal/password"
 	"RESTAPI/internal/repository"
+	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 )
 
+// WriterService changes writers; every change is recorded in the outbox in the same transaction
 type WriterService struct {
-	repo *repository.WriterRepository
+	repo     *repository.WriterRepository
+	newsRepo *repository.NewsRepository
+	hasher   *password.Hasher
+	tx       *repository.Transactor
+	events   *outbox.Recorder
+	// dummyHash is verified against when the login does not exist
+	dummyHash string
 }
 
-func NewWriterService(repo *repository.WriterRepository) *WriterService {
-	return &WriterService{repo: repo}
+func NewWriterService(repo *repository.WriterRepository, newsRepo *repository.NewsRepository, hasher *password.Hasher, tx *repository.Transactor, events *outbox.Recorder) *WriterService {
+	dummyHash, _ := hasher.Hash("dummy-password")
+	return &WriterService{repo: repo, newsRepo: newsRepo, hasher: hasher, tx: tx, events: events, dummyHash: dummyHash}
+}
+
+func writerResponse(writer *entity.Writer) *dto.WriterResponseTo {
+	return &dto.WriterResponseTo{
+		ID:        writer.ID,
+		Login:     writer.Login,
+		FirstName: writer.FirstName,
+		LastName:  writer.LastName,
+		Role:      string(writer.Role),
+	}
 }
 
 // Create creates a new writer
-func (s *WriterService) Create(req dto.WriterRequestTo) (*dto.WriterResponseTo, error) {
+func (s *WriterService) Create(ctx context.Context, req dto.WriterRequestTo) (*dto.WriterResponseTo, error) {
 	// Check if the login already exists
-	existingWriter, err := s.repo.GetByLogin(req.Login)
+	existingWriter, err := s.repo.GetByLogin(ctx, req.Login)
 	if err == nil && existingWriter != nil {
-		return nil, fmt.Errorf("login_already_exists")
+		return nil, apperr.Conflict("login already exists")
+	}
+
+	hash, err := s.hasher.Hash(req.Password)
+	if err != nil {
+		return nil, err
 	}
 
 	writer := &entity.Writer{
 		Login:     req.Login,
-		Password:  req.Password,
+		Password:  hash,
 		FirstName: req.FirstName,
 		LastName:  req.LastName,
+		Role:      entity.RoleCustomer,
 	}
 
-	err = s.repo.Create(writer)
+	err = s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.repo.Create(ctx, writer); err != nil {
+			return err
+		}
+		return s.events.Record(ctx, outbox.Writer, writer.ID, outbox.Created, writerResponse(writer))
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	return &dto.WriterResponseTo{
-		ID:        writer.ID,
-		Login:     writer.Login,
-		FirstName: writer.FirstName,
-		LastName:  writer.LastName,
-	}, nil
+	return writerResponse(writer), nil
+}
+
+// EnsureAdmin creates the ADMIN writer login with the given password unless it
+// exists. An existing writer is promoted to ADMIN only if the password matches,
+// so a login registered by someone else is not taken over.
+func (s *WriterService) EnsureAdmin(ctx context.Context, login, pass string) error {
+	if pass == "" {
+		return fmt.Errorf("no password for the administrator %q", login)
+	}
+	existing, err := s.repo.GetByLogin(ctx, login)
+	if err == nil {
+		if existing.Role == entity.RoleAdmin {
+			return nil
+		}
+		if err := s.hasher.Verify(existing.Password, pass); err != nil {
+			return fmt.Errorf("writer %q exists and its password does not match: %w", login, err)
+		}
+		existing.Role = entity.RoleAdmin
+		err = s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+			if err := s.repo.Update(ctx, existing); err != nil {
+				return err
+			}
+			return s.events.Record(ctx, outbox.Writer, existing.ID, outbox.Updated, writerResponse(existing))
+		})
+		if err == nil {
+			slog.InfoContext(ctx, "writer promoted to ADMIN", slog.String("login", login))
+		}
+		return err
+	}
+	if !apperr.IsNotFound(err) {
+		return err
+	}
+
+	hash, err := s.hasher.Hash(pass)
+	if err != nil {
+		return err
+	}
+	writer := &entity.Writer{
+		Login:     login,
+		Password:  hash,
+		FirstName: "Admin",
+		LastName:  "Admin",
+		Role:      entity.RoleAdmin,
+	}
+	err = s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.repo.Create(ctx, writer); err != nil {
+			return err
+		}
+		return s.events.Record(ctx, outbox.Writer, writer.ID, outbox.Created, writerResponse(writer))
+	})
+	// Another instance starting at the same time may have created it
+	if apperr.KindOf(err) == apperr.KindConflict {
+		return nil
+	}
+	if err == nil {
+		slog.InfoContext(ctx, "administrator created", slog.String("login", login))
+	}
+	return err
 }
 
 // GetById gets a writer by ID
-func (s *WriterService) GetById(id int64) (*dto.WriterResponseTo, error) {
+func (s *WriterService) GetById(ctx context.Context, id int64) (*dto.WriterResponseTo, error) {
 	if id < 0 {
-		return nil, fmt.Errorf("invalid ID: %d", id)
+		return nil, apperr.Validation(fmt.Sprintf("invalid ID: %d", id))
 	}
 
-	writer, err := s.repo.GetById(id)
+	writer, err := s.repo.GetById(ctx, id)
 	if err != nil {
-		return nil, fmt.Errorf("writer not found")
+		return nil, notFound(err, "writer")
 	}
 
-	return &dto.WriterResponseTo{
-		ID:        writer.ID,
-		Login:     writer.Login,
-		FirstName: writer.FirstName,
-		LastName:  writer.LastName,
-	}, nil
+	return writerResponse(&writer), nil
 }
 
 // Update updates a writer
-func (s *WriterService) Update(req dto.WriterUpdateRequestTo) (*dto.WriterResponseTo, error) {
+func (s *WriterService) Update(ctx context.Context, req dto.WriterUpdateRequestTo) (*dto.WriterResponseTo, error) {
+	existing, err := s.repo.GetById(ctx, req.ID)
+	if err != nil {
+		return nil, notFound(err, "writer")
+	}
+
+	hash, err := s.hasher.Hash(req.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	role := existing.Role
+	if req.Role != "" {
+		role = entity.Role(req.Role)
+	}
+
 	writer := &entity.Writer{
 		Login:     req.Login,
-		Password:  req.Password,
+		Password:  hash,
 		FirstName: req.FirstName,
 		LastName:  req.LastName,
+		Role:      role,
 		ID:        req.ID,
 	}
 
-	err := s.repo.Update(writer)
+	err = s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.repo.Update(ctx, writer); err != nil {
+			return err
+		}
+		return s.events.Record(ctx, outbox.Writer, writer.ID, outbox.Updated, writerResponse(writer))
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	return &dto.WriterResponseTo{
-		ID:        writer.ID,
-		Login:     writer.Login,
-		FirstName: writer.FirstName,
-		LastName:  writer.LastName,
-	}, nil
+	return writerResponse(writer), nil
+}
+
+// VerifyCredentials checks a login/password pair and returns the matching writer.
+// Hashes produced with an outdated algorithm or cost are upgraded on success.
+func (s *WriterService) VerifyCredentials(ctx context.Context, login, pass string) (*dto.WriterResponseTo, error) {
+	writer, err := s.repo.GetByLogin(ctx, login)
+	if err != nil {
+		// Spend the same time as a real check so unknown logins can't be told apart
+		s.hasher.Verify(s.dummyHash, pass)
+		return nil, ErrInvalidCredentials
+	}
+
+	if err := s.hasher.Verify(writer.Password, pass); err != nil {
+		if errors.Is(err, password.ErrMismatch) {
+			return nil, ErrInvalidCredentials
+		}
+		return nil, err
+	}
+
+	if s.hasher.NeedsRehash(writer.Password) {
+		if hash, err := s.hasher.Hash(pass); err == nil {
+			if err := s.repo.UpdatePassword(ctx, writer.ID, hash); err != nil {
+				slog.WarnContext(ctx, "failed to upgrade password hash", slog.Int64("writer_id", writer.ID), slog.Any("error", err))
+			}
+		}
+	}
+
+	return writerResponse(writer), nil
 }
 
 // Delete deletes a writer
-func (s *WriterService) Delete(id int64) error {
-	return s.repo.Delete(id)
+func (s *WriterService) Delete(ctx context.Context, id int64) error {
+	err := s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		// The news of the writer are deleted by the foreign key cascade,
+		// so their events are recorded here
+		newsIDs, err := s.newsRepo.IDsByWriter(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := s.repo.Delete(ctx, id); err != nil {
+			return err
+		}
+		for _, newsID := range newsIDs {
+			if err := s.events.RecordDeleted(ctx, outbox.News, newsID); err != nil {
+				return err
+			}
+		}
+		return s.events.RecordDeleted(ctx, outbox.Writer, id)
+	})
+	return notFound(err, "writer")
 }
 
 // GetAll returns all writers
-func (s *WriterService) GetAll() ([]*dto.WriterResponseTo, error) {
-	writers, err := s.repo.GetAll()
+func (s *WriterService) GetAll(ctx context.Context) ([]*dto.WriterResponseTo, error) {
+	writers, err := s.repo.GetAll(ctx)
 	if err != nil {
 		return nil, err
 	}
 
 	response := make([]*dto.WriterResponseTo, len(writers))
-	for i, writer := range writers {
-		response[i] = &dto.WriterResponseTo{
-			ID:        writer.ID,
-			Login:     writer.Login,
-			FirstName: writer.FirstName,
-			LastName:  writer.LastName,
-		}
+	for i := range writers {
+		response[i] = writerResponse(&writers[i])
 	}
 	return response, nil
 }
+
+// List returns a page of writers together with the total number of matches
+func (s *WriterService) List(ctx context.Context, q repository.ListQuery) ([]*dto.WriterResponseTo, int64, error) {
+	writers, total, err := s.repo.List(ctx, q)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	response := make([]*dto.WriterResponseTo, len(writers))
+	for i := range writers {
+		response[i] = writerResponse(&writers[i])
+	}
+	return response, total, nil
+}
@@ -0,0 +1,126 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/gocql/gocql"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+const gormSpanKey = "tracing:span"
+
+// GormPlugin creates a span for every gorm operation that runs with a traced
+// context (db.WithContext(ctx)). Operations without a parent span are not
+// traced, so background queries do not produce stray root traces.
+type GormPlugin struct{}
+
+func (GormPlugin) Name() string {
+	return "tracing"
+}
+
+// Initialize registers before/after callbacks around each gorm processor
+func (GormPlugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	steps := []struct {
+		operation string
+		before    func(string, func(*gorm.DB)) error
+		after     func(string, func(*gorm.DB)) error
+	}{
+		{"create", cb.Create().Before("gorm:create").Register, cb.Create().After("gorm:create").Register},
+		{"query", cb.Query().Before("gorm:query").Register, cb.Query().After("gorm:query").Register},
+		{"update", cb.Update().Before("gorm:update").Register, cb.Update().After("gorm:update").Register},
+		{"delete", cb.Delete().Before("gorm:delete").Register, cb.Delete().After("gorm:delete").Register},
+		{"row", cb.Row().Before("gorm:row").Register, cb.Row().After("gorm:row").Register},
+		{"raw", cb.Raw().Before("gorm:raw").Register, cb.Raw().After("gorm:raw").Register},
+	}
+	for _, step := range steps {
+		if err := step.before("tracing:before_"+step.operation, startGorm(step.operation)); err != nil {
+			return err
+		}
+		if err := step.after("tracing:after_"+step.operation, finishGorm); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func startGorm(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		ctx := db.Statement.Context
+		if ctx == nil || !trace.SpanContextFromContext(ctx).IsValid() {
+			return
+		}
+
+		name := "postgres " + operation
+		if db.Statement.Table != "" {
+			name += " " + db.Statement.Table
+		}
+		ctx, span := Start(ctx, name,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				semconv.DBSystemPostgreSQL,
+				semconv.DBOperationName(operation),
+				semconv.DBCollectionName(db.Statement.Table),
+			))
+		db.Statement.Context = ctx
+		db.InstanceSet(gormSpanKey, span)
+	}
+}
+
+func finishGorm(db *gorm.DB) {
+	value, ok := db.InstanceGet(gormSpanKey)
+	if !ok {
+		return
+	}
+	span := value.(trace.Span)
+
+	// The statement text has placeholders only, never the bound values
+	span.SetAttributes(semconv.DBQueryText(db.Statement.SQL.String()))
+	if db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+		span.RecordError(db.Error)
+		span.SetStatus(codes.Error, db.Error.Error())
+	}
+	span.End()
+}
+
+// CassandraObserver records every gocql query that runs with a traced context
+// as a span; set it as ClusterConfig.QueryObserver. Next, if set, observes
+// the query as well, because gocql accepts a single observer.
+type CassandraObserver struct {
+	Next gocql.QueryObserver
+}
+
+func (o CassandraObserver) ObserveQuery(ctx context.Context, q gocql.ObservedQuery) {
+	if o.Next != nil {
+		o.Next.ObserveQuery(ctx, q)
+	}
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return
+	}
+
+	operation := "query"
+	if words := strings.Fields(q.Statement); len(words) > 0 {
+		operation = strings.ToLower(words[0])
+	}
+
+	// The query has already finished, so the span is backdated
+	_, span := Start(ctx, "cassandra "+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithTimestamp(q.Start),
+		trace.WithAttributes(
+			semconv.DBSystemCassandra,
+			semconv.DBOperationName(operation),
+			semconv.DBQueryText(q.Statement),
+			semconv.DBNamespace(q.Keyspace),
+		))
+	if q.Err != nil {
+		span.RecordError(q.Err)
+		span.SetStatus(codes.Error, q.Err.Error())
+	}
+	span.End(trace.WithTimestamp(q.End))
+}
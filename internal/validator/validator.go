@@ -1,9 +1,111 @@
 package validator
 
 import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"RESTAPI/internal/apperr"
+
 	"github.com/go-playground/validator/v10"
 )
 
-func NewValidator() *validator.Validate {
-	return validator.New()
+var (
+	// markNamePattern: letters and digits, optionally joined by '-', '_' or '.'
+	markNamePattern = regexp.MustCompile(`^[\p{L}\p{N}]+([-_.][\p{L}\p{N}]+)*$`)
+	// loginPattern: latin letters, digits and . _ - @
+	loginPattern = regexp.MustCompile(`^[A-Za-z0-9._@-]+$`)
+)
+
+// Validator validates request DTOs and reports failures by JSON field name.
+// It implements echo.Validator.
+type Validator struct {
+	validate *validator.Validate
+}
+
+// NewValidator creates the shared validator with the custom rules registered
+func NewValidator() *Validator {
+	v := validator.New(validator.WithRequiredStructEnabled())
+
+	// Report fields by their JSON name so clients see "title", not "Title"
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return field.Name
+		}
+		return name
+	})
+
+	v.RegisterValidation("markname", func(fl validator.FieldLevel) bool {
+		return markNamePattern.MatchString(fl.Field().String())
+	})
+	v.RegisterValidation("login", func(fl validator.FieldLevel) bool {
+		return loginPattern.MatchString(fl.Field().String())
+	})
+
+	return &Validator{validate: v}
+}
+
+// Validate checks i and returns an apperr Validation error listing every invalid field
+func (v *Validator) Validate(i interface{}) error {
+	err := v.validate.Struct(i)
+	if err == nil {
+		return nil
+	}
+
+	var errs validator.ValidationErrors
+	if !errors.As(err, &errs) {
+		return apperr.Validation(err.Error())
+	}
+
+	fields := make([]apperr.FieldError, len(errs))
+	for i, fe := range errs {
+		fields[i] = apperr.FieldError{
+			Field:   fieldPath(fe),
+			Rule:    fe.Tag(),
+			Param:   fe.Param(),
+			Message: message(fe),
+		}
+	}
+	return apperr.Validation("request validation failed", fields...)
+}
+
+// fieldPath returns the JSON path of the field without the struct name, e.g. "marks[0]"
+func fieldPath(fe validator.FieldError) string {
+	_, path, found := strings.Cut(fe.Namespace(), ".")
+	if !found {
+		return fe.Field()
+	}
+	return path
+}
+
+// message describes the violated rule in plain words
+func message(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "min":
+		if fe.Kind() == reflect.String {
+			return fmt.Sprintf("must be at least %s characters long", fe.Param())
+		}
+		return fmt.Sprintf("must be at least %s", fe.Param())
+	case "max":
+		if fe.Kind() == reflect.String {
+			return fmt.Sprintf("must be at most %s characters long", fe.Param())
+		}
+		return fmt.Sprintf("must be at most %s", fe.Param())
+	case "oneof":
+		return fmt.Sprintf("must be one of: %s", fe.Param())
+	case "markname":
+		return "may contain only letters and digits separated by '-', '_' or '.'"
+	case "login":
+		return "may contain only latin letters, digits and the characters . _ - @"
+	default:
+		return fmt.Sprintf("failed the %q rule", fe.Tag())
+	}
 }